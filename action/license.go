@@ -0,0 +1,259 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type License struct {
+	Expression string   `json:"expression" hcl:"expression"`
+	Files      []string `json:"files,omitempty" hcl:"files,optional"`
+}
+
+func NewLicense() *License {
+	return &License{}
+}
+
+func (l *License) Key() string {
+	return l.Expression
+}
+
+func (l *License) Id() string {
+	return fmt.Sprint(l.Type(), ".", l.Key())
+}
+
+func (l *License) Type() string {
+	return "License"
+}
+
+func (l *License) Columns() string {
+	return strings.Join([]string{
+		strings.ToUpper(l.Type()),
+		l.Expression,
+		strings.Join(l.Files, ","),
+	}, "|")
+}
+
+func (l *License) Condition() *bool {
+	return nil
+}
+
+func (l *License) MayFail() bool {
+	return false
+}
+
+func (l *License) IsValid() bool {
+	err := l.validate()
+	if err != nil {
+		return false
+	}
+
+	return true
+}
+
+func (l *License) validate() error {
+	if l.Expression == "" {
+		return errors.New("action license.expression required")
+	}
+
+	_, err := ParseLicense(l.Expression)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range l.Files {
+		if file == "" || strings.HasPrefix(file, "/") {
+			return errors.New("action license.files must be relative paths")
+		}
+	}
+
+	return nil
+}
+
+// Parsed SPDX license expression, leaves carry a license id
+type LicenseExpr struct {
+	Op string
+
+	Id        string
+	Exception string
+
+	Left  *LicenseExpr
+	Right *LicenseExpr
+}
+
+var licenseId = regexp.MustCompile(`^[A-Za-z0-9.\-]+\+?$`)
+
+// Parse an SPDX license expression, AND binds tighter than OR
+func ParseLicense(expression string) (*LicenseExpr, error) {
+	p := &licenseParser{tokens: tokenizeLicense(expression)}
+
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("action license.expression unexpected %q", p.tokens[p.pos])
+	}
+
+	return expr, nil
+}
+
+// License ids referenced by the expression
+func (e *LicenseExpr) Ids() []string {
+	if e.Op == "" {
+		return []string{e.Id}
+	}
+
+	return append(e.Left.Ids(), e.Right.Ids()...)
+}
+
+// Reports whether a choice of licenses exists that only uses permitted ids
+func (e *LicenseExpr) Satisfiable(permitted func(id string) bool) bool {
+	switch e.Op {
+	case "AND":
+		return e.Left.Satisfiable(permitted) && e.Right.Satisfiable(permitted)
+	case "OR":
+		return e.Left.Satisfiable(permitted) || e.Right.Satisfiable(permitted)
+	default:
+		return permitted(e.Id)
+	}
+}
+
+func (e *LicenseExpr) String() string {
+	switch e.Op {
+	case "AND", "OR":
+		return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+	default:
+		if e.Exception != "" {
+			return e.Id + " WITH " + e.Exception
+		}
+
+		return e.Id
+	}
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenizeLicense(expression string) []string {
+	expression = strings.Replace(expression, "(", " ( ", -1)
+	expression = strings.Replace(expression, ")", " ) ", -1)
+
+	return strings.Fields(expression)
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+func (p *licenseParser) next() string {
+	token := p.peek()
+	p.pos++
+
+	return token
+}
+
+func (p *licenseParser) or() (*LicenseExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for strings.ToUpper(p.peek()) == "OR" {
+		p.next()
+
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &LicenseExpr{Op: "OR", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *licenseParser) and() (*LicenseExpr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for strings.ToUpper(p.peek()) == "AND" {
+		p.next()
+
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &LicenseExpr{Op: "AND", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *licenseParser) term() (*LicenseExpr, error) {
+	token := p.next()
+
+	switch {
+	case token == "":
+		return nil, errors.New("action license.expression incomplete")
+	case token == "(":
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next() != ")" {
+			return nil, errors.New("action license.expression missing )")
+		}
+
+		return expr, nil
+	case isLicenseOperator(token) || !licenseId.MatchString(token):
+		return nil, fmt.Errorf("action license.expression invalid license id %q", token)
+	}
+
+	expr := &LicenseExpr{Id: token}
+
+	if strings.ToUpper(p.peek()) == "WITH" {
+		p.next()
+
+		exception := p.next()
+		if isLicenseOperator(exception) || !licenseId.MatchString(exception) {
+			return nil, fmt.Errorf("action license.expression invalid exception %q", exception)
+		}
+
+		expr.Exception = exception
+	}
+
+	return expr, nil
+}
+
+func isLicenseOperator(token string) bool {
+	switch strings.ToUpper(token) {
+	case "AND", "OR", "WITH", "(", ")":
+		return true
+	}
+
+	return false
+}
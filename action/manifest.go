@@ -25,6 +25,9 @@ type Manifest struct {
 
 	Tags []*Tag `hcl:"Tag,block" json:"tag,omitempty"`
 
+	License    *License    `hcl:"License,block" json:"license,omitempty"`
+	Provenance *Provenance `hcl:"Provenance,block" json:"provenance,omitempty"`
+
 	Requirements []*Requirement `hcl:"Requirement,block" json:"requirement,omitempty"`
 
 	Dirs     []*Dir     `hcl:"Dir,block" json:"dir,omitempty"`
@@ -37,6 +40,8 @@ type Manifest struct {
 
 	Signatures []*Signature `hcl:"Signature,block" json:"signature,omitempty"`
 
+	Excludes []*Exclude `hcl:"Exclude,block" json:"-"`
+
 	index map[string]int
 }
 
@@ -57,6 +62,12 @@ func (m *Manifest) Add(action Action) {
 			m.Tags = append(m.Tags, action.(*Tag))
 			m.index[action.Id()] = len(m.Tags) - 1
 		}
+	case "License":
+		m.License = action.(*License)
+		m.index[action.Id()] = 0
+	case "Provenance":
+		m.Provenance = action.(*Provenance)
+		m.index[action.Id()] = 0
 	case "Requirement":
 		if m.Exists(action) {
 			m.Requirements[m.index[action.Id()]] = action.(*Requirement)
@@ -109,6 +120,40 @@ func (m *Manifest) Add(action Action) {
 	}
 }
 
+// Remove FS actions
+func (m *Manifest) Remove(actions ...Action) {
+	remove := make(map[string]bool)
+	for _, act := range actions {
+		remove[act.Id()] = true
+	}
+
+	var dirs []*Dir
+	for _, dir := range m.Dirs {
+		if !remove[dir.Id()] {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	var files []*File
+	for _, file := range m.Files {
+		if !remove[file.Id()] {
+			files = append(files, file)
+		}
+	}
+
+	var symlinks []*SymLink
+	for _, symlink := range m.SymLinks {
+		if !remove[symlink.Id()] {
+			symlinks = append(symlinks, symlink)
+		}
+	}
+
+	m.Dirs, m.Files, m.SymLinks = dirs, files, symlinks
+
+	m.index = make(map[string]int)
+	m.Index()
+}
+
 func (m *Manifest) Exists(action Action) bool {
 	if _, ok := m.index[action.Id()]; ok {
 		return true
@@ -117,6 +162,37 @@ func (m *Manifest) Exists(action Action) bool {
 	return false
 }
 
+// Removes Dir and File actions with glob paths, returning them in
+// definition order for the builder to expand
+func (m *Manifest) Globs() ([]*Dir, []*File) {
+	var dirs, globDirs []*Dir
+	var files, globFiles []*File
+
+	for _, dir := range m.Dirs {
+		if IsGlob(dir.Path) {
+			globDirs = append(globDirs, dir)
+		} else {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, file := range m.Files {
+		if IsGlob(file.Path) {
+			globFiles = append(globFiles, file)
+		} else {
+			files = append(files, file)
+		}
+	}
+
+	m.Dirs = dirs
+	m.Files = files
+
+	m.index = make(map[string]int)
+	m.Index()
+
+	return globDirs, globFiles
+}
+
 func (m *Manifest) Section(filters ...string) Actions {
 	var items []Action
 
@@ -126,6 +202,14 @@ func (m *Manifest) Section(filters ...string) Actions {
 			for _, item := range m.Tags {
 				items = append(items, item)
 			}
+		case "License":
+			if m.License != nil {
+				items = append(items, m.License)
+			}
+		case "Provenance":
+			if m.Provenance != nil {
+				items = append(items, m.Provenance)
+			}
 		case "Requirement":
 			for _, item := range m.Requirements {
 				items = append(items, item)
@@ -165,6 +249,14 @@ func (m *Manifest) Index() {
 		m.index[act.Id()] = index
 	}
 
+	if m.License != nil {
+		m.index[m.License.Id()] = 0
+	}
+
+	if m.Provenance != nil {
+		m.index[m.Provenance.Id()] = 0
+	}
+
 	for index, act := range m.Requirements {
 		m.index[act.Id()] = index
 	}
@@ -202,6 +294,7 @@ func (m *Manifest) Actions() Actions {
 
 	actions = append(actions, m.Zpkg)
 	actions = append(actions, m.Section("Tag")...)
+	actions = append(actions, m.Section("License", "Provenance")...)
 	actions = append(actions, m.Section("Requirement")...)
 	actions = append(actions, m.Section("Template")...)
 	actions = append(actions, m.Section("Service")...)
@@ -214,6 +307,15 @@ func (m *Manifest) Actions() Actions {
 func (m *Manifest) Validate() error {
 	var actions Actions
 
+	if m.Zpkg == nil {
+		return errors.New("Action Zpkg: required")
+	}
+
+	err := m.Zpkg.validate()
+	if err != nil {
+		return err
+	}
+
 	// Do not allow a requirement to name itself
 	reqs := m.Section("Requirement")
 	for _, req := range reqs {
@@ -248,6 +350,27 @@ func (m *Manifest) Validate() error {
 		}
 	}
 
+	// Ensure license and provenance are well formed, bundled license files must be packaged
+	if m.License != nil {
+		err := m.License.validate()
+		if err != nil {
+			return err
+		}
+
+		for _, file := range m.License.Files {
+			if _, ok := m.index["File."+file]; !ok {
+				return errors.New("Action License: license file " + file + " is not a packaged file")
+			}
+		}
+	}
+
+	if m.Provenance != nil {
+		err := m.Provenance.validate()
+		if err != nil {
+			return err
+		}
+	}
+
 	// TODO add a check to ensure that service includes the systemd unit
 
 	return nil
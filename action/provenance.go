@@ -0,0 +1,102 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Provenance struct {
+	VcsUri    string `json:"vcs_uri,omitempty" hcl:"vcs_uri,optional"`
+	Commit    string `json:"commit,omitempty" hcl:"commit,optional"`
+	Builder   string `json:"builder,omitempty" hcl:"builder,optional"`
+	BuildTime string `json:"build_time,omitempty" hcl:"build_time,optional"`
+}
+
+func NewProvenance() *Provenance {
+	return &Provenance{}
+}
+
+func (p *Provenance) Key() string {
+	return p.VcsUri
+}
+
+func (p *Provenance) Id() string {
+	return fmt.Sprint(p.Type(), ".", p.Key())
+}
+
+func (p *Provenance) Type() string {
+	return "Provenance"
+}
+
+func (p *Provenance) Columns() string {
+	return strings.Join([]string{
+		strings.ToUpper(p.Type()),
+		p.VcsUri,
+		p.Commit,
+	}, "|")
+}
+
+func (p *Provenance) Condition() *bool {
+	return nil
+}
+
+func (p *Provenance) MayFail() bool {
+	return false
+}
+
+func (p *Provenance) IsValid() bool {
+	err := p.validate()
+	if err != nil {
+		return false
+	}
+
+	return true
+}
+
+// Source reference in the form uri@commit
+func (p *Provenance) Source() string {
+	if p.Commit == "" {
+		return p.VcsUri
+	}
+
+	return p.VcsUri + "@" + p.Commit
+}
+
+func (p *Provenance) validate() error {
+	if p.VcsUri == "" && p.Commit == "" && p.Builder == "" {
+		return errors.New("action provenance requires vcs_uri, commit or builder")
+	}
+
+	if p.VcsUri != "" {
+		uri, err := url.Parse(p.VcsUri)
+		if err != nil || uri.Scheme == "" {
+			return errors.New("action provenance.vcs_uri must be an absolute uri")
+		}
+	}
+
+	if p.Commit != "" && p.VcsUri == "" {
+		return errors.New("action provenance.commit requires vcs_uri")
+	}
+
+	if p.BuildTime != "" {
+		_, err := time.Parse(time.RFC3339, p.BuildTime)
+		if err != nil {
+			return errors.New("action provenance.build_time must be RFC3339")
+		}
+	}
+
+	return nil
+}
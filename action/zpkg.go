@@ -18,12 +18,15 @@ import (
 
 type Zpkg struct {
 	Name        string `json:"name" hcl:"name,label"`
-	Version     string `json:"version" hcl:"version"`
-	Publisher   string `json:"publisher" hcl:"publisher"`
-	Arch        string `json:"arch" hcl:"arch"`
-	Os          string `json:"os" hcl:"os"`
-	Summary     string `json:"summary" hcl:"summary"`
-	Description string `json:"description" hcl:"description"`
+	Version     string `json:"version" hcl:"version,optional"`
+	Publisher   string `json:"publisher" hcl:"publisher,optional"`
+	Arch        string `json:"arch" hcl:"arch,optional"`
+	Os          string `json:"os" hcl:"os,optional"`
+	Summary     string `json:"summary" hcl:"summary,optional"`
+	Description string `json:"description" hcl:"description,optional"`
+
+	// Split packages claim target paths by glob
+	Paths []string `json:"-" hcl:"paths,optional"`
 }
 
 func NewZpkg() *Zpkg {
@@ -118,7 +121,7 @@ func (z *Zpkg) validateName() error {
 
 func (z *Zpkg) validatePublisher() error {
 	if z.Publisher == "" {
-		return errors.New("action zpkg.name required")
+		return errors.New("action zpkg.publisher required")
 	}
 
 	return nil
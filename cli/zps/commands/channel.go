@@ -17,12 +17,15 @@ func NewZpsChannelCommand() *ZpsChannelCommand {
 	cmd := &ZpsChannelCommand{}
 	cmd.Command = &cobra.Command{}
 	cmd.Ui = cli.NewUi()
-	cmd.Use = "channel [REPO_NAME] [CHANNEL]"
+	cmd.Use = "channel [REPO_NAME] [PKG] [CHANNEL]"
 	cmd.Short = "Add a package to a channel within a repository"
 	cmd.Long = "Add a package to a channel within a repository"
 	cmd.PreRunE = cmd.setup
 	cmd.RunE = cmd.run
 
+	cmd.AddCommand(NewZpsChannelListCommand().Command)
+	cmd.AddCommand(NewZpsChannelRemoveCommand().Command)
+	cmd.AddCommand(NewZpsChannelSyncCommand().Command)
 	return cmd
 }
 
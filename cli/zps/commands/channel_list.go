@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+type ZpsChannelListCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsChannelListCommand() *ZpsChannelListCommand {
+	cmd := &ZpsChannelListCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "list [REPO_NAME] [CHANNEL]"
+	cmd.Short = "List channels within a repository"
+	cmd.Long = "List channels within a repository, or the packages in a channel"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsChannelListCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsChannelListCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Repo name required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	contents, err := mgr.ChannelList(cmd.Flags().Arg(0), cmd.Flags().Arg(1))
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+	if contents == nil {
+		z.Warn("No channels found")
+		return nil
+	}
+
+	z.Out(columnize.SimpleFormat(contents))
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsChannelRemoveCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsChannelRemoveCommand() *ZpsChannelRemoveCommand {
+	cmd := &ZpsChannelRemoveCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "remove [REPO_NAME] [PKG] [CHANNEL]"
+	cmd.Short = "Remove a package from a channel within a repository"
+	cmd.Long = "Remove a package from a channel within a repository, packages matching a channel query are added back by the next sync"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsChannelRemoveCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsChannelRemoveCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Repo name required")
+	}
+
+	if cmd.Flags().Arg(1) == "" {
+		return errors.New("Must specify a zpkg to remove from a channel")
+	}
+
+	if cmd.Flags().Arg(2) == "" {
+		return errors.New("Must specify a channel")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.ChannelRemove(cmd.Flags().Arg(0), cmd.Flags().Arg(1), cmd.Flags().Arg(2))
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
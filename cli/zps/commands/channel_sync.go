@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsChannelSyncCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsChannelSyncCommand() *ZpsChannelSyncCommand {
+	cmd := &ZpsChannelSyncCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "sync [REPO_NAME]"
+	cmd.Short = "Apply channel rules to a repository"
+	cmd.Long = "Add packages matching the channel queries of a repository publish config to their channels"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsChannelSyncCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsChannelSyncCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Repo name required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.ChannelSync(cmd.Flags().Arg(0))
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -42,6 +42,10 @@ func SetupEventHandlers(emitter *emission.Emitter, ui *cli.Ui) {
 		ui.Warn(fmt.Sprint("=> ", message))
 	})
 
+	emitter.On("builder.matrix", func(message string) {
+		ui.Blue(fmt.Sprint("* matrix ", message))
+	})
+
 	emitter.On("manager.error", func(message string) {
 		ui.Error(fmt.Sprint("x ", message))
 	})
@@ -114,8 +118,24 @@ func SetupEventHandlers(emitter *emission.Emitter, ui *cli.Ui) {
 		ui.Info(fmt.Sprint("* published ", message))
 	})
 
+	emitter.On("publisher.remove", func(message string) {
+		ui.Info(fmt.Sprint("* removed ", message))
+	})
+
+	emitter.On("publisher.check", func(message string) {
+		ui.Warn(fmt.Sprint("~ ", message))
+	})
+
+	emitter.On("publisher.restore", func(message string) {
+		ui.Info(fmt.Sprint("* restored ", message))
+	})
+
 	emitter.On("publisher.channel", func(message string) {
-		ui.Info(fmt.Sprint("* channel", message))
+		ui.Info(fmt.Sprint("* channel ", message))
+	})
+
+	emitter.On("publisher.unchannel", func(message string) {
+		ui.Info(fmt.Sprint("* unchannel ", message))
 	})
 
 	emitter.On("transaction.noop", func(message string) {
@@ -47,6 +47,7 @@ func (z *ZpsConfigureCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsConfigureCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 	profile, _ := cmd.Flags().GetString("profile")
 
 	// Load manager
@@ -56,6 +57,7 @@ func (z *ZpsConfigureCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	err = mgr.Configure(cmd.Flags().Args(), profile)
 	if err != nil {
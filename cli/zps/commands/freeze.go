@@ -47,6 +47,7 @@ func (z *ZpsFreezeCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsFreezeCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 
 	if cmd.Flags().NArg() == 0 {
 		return errors.New("Must provide at least one package uri to freeze")
@@ -59,6 +60,7 @@ func (z *ZpsFreezeCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	mgr.On("error", func(error string) {
 		z.Error(error)
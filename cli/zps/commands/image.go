@@ -34,7 +34,9 @@ func NewZpsImageCommand() *ZpsImageCommand {
 	cmd.AddCommand(NewZpsImageInitCommand().Command)
 	cmd.AddCommand(NewZpsImageCurrentCommand().Command)
 	cmd.AddCommand(NewZpsImageDeleteCommand().Command)
+	cmd.AddCommand(NewZpsImageExportCommand().Command)
 	cmd.AddCommand(NewZpsImageListCommand().Command)
+	cmd.AddCommand(NewZpsImageSbomCommand().Command)
 	return cmd
 }
 
@@ -0,0 +1,77 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsImageExportCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsImageExportCommand() *ZpsImageExportCommand {
+	cmd := &ZpsImageExportCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "export [OUTPUT_PATH]"
+	cmd.Short = "Export the current ZPS image to another format"
+	cmd.Long = "Export the current ZPS image to another format"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().Bool("oci", false, "Export as an OCI image layout, one layer per package")
+	cmd.Flags().String("name", "", "Reference name for the exported image")
+
+	return cmd
+}
+
+func (z *ZpsImageExportCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsImageExportCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	ociFormat, _ := cmd.Flags().GetBool("oci")
+	name, _ := cmd.Flags().GetString("name")
+
+	if cmd.Flags().NArg() == 0 {
+		return errors.New("Output path required")
+	}
+
+	if !ociFormat {
+		return errors.New("Export format required (--oci)")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.ImageExport(cmd.Flags().Arg(0), name)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsImageSbomCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsImageSbomCommand() *ZpsImageSbomCommand {
+	cmd := &ZpsImageSbomCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "sbom"
+	cmd.Short = "Generate an SBOM for the current ZPS image"
+	cmd.Long = "Generate an SBOM for the current ZPS image in SPDX or CycloneDX JSON"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("format", "spdx", "SBOM format (spdx, cyclonedx)")
+
+	return cmd
+}
+
+func (z *ZpsImageSbomCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsImageSbomCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	format, _ := cmd.Flags().GetString("format")
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	output, err := mgr.ImageSbom(format)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	z.Out(output)
+
+	return err
+}
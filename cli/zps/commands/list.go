@@ -47,6 +47,7 @@ func (z *ZpsListCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsListCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 	var err error
 
 	// Load manager
@@ -56,6 +57,7 @@ func (z *ZpsListCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	list, err := mgr.List()
 	if err != nil {
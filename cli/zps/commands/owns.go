@@ -0,0 +1,71 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+type ZpsOwnsCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsOwnsCommand() *ZpsOwnsCommand {
+	cmd := &ZpsOwnsCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "owns [PATH]..."
+	cmd.Short = "Show which installed packages own image paths"
+	cmd.Long = "Show which installed packages own image paths, paths may be absolute, relative to the image or globs"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsOwnsCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsOwnsCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+
+	if cmd.Flags().NArg() == 0 {
+		return errors.New("Must provide paths")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	output, err := mgr.Owns(cmd.Flags().Args())
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	z.Out(columnize.SimpleFormat(output) + "\n")
+
+	return nil
+}
@@ -30,6 +30,8 @@ func NewZpsPkiCommand() *ZpsPkiCommand {
 	cmd.PreRunE = cmd.setup
 	cmd.RunE = cmd.run
 
+	cmd.AddCommand(NewZpsPkiCaCommand().Command)
+	cmd.AddCommand(NewZpsPkiCrlCommand().Command)
 	cmd.AddCommand(NewZpsPkiKeyPairCommand().Command)
 	cmd.AddCommand(NewZpsPkiTrustCommand().Command)
 
@@ -0,0 +1,80 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/sec"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsPkiCaInitCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsPkiCaInitCommand() *ZpsPkiCaInitCommand {
+	cmd := &ZpsPkiCaInitCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "init"
+	cmd.Short = "Create a publisher CA and intermediate"
+	cmd.Long = "Create a self signed publisher CA and an intermediate issued by it, for signing publisher keypairs"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("publisher", "", "publisher organization")
+	cmd.Flags().String("out", ".", "directory for the generated pem and key files")
+	cmd.Flags().String("algo", sec.AlgoEcdsaP256Sha256, "key algorithm [rsa|ecdsa-p256-sha256|ed25519]")
+	cmd.Flags().Int("days", 3650, "CA validity in days, the intermediate is valid for half")
+	cmd.Flags().Bool("import", false, "trust the CA and intermediate in the pki store")
+
+	return cmd
+}
+
+func (z *ZpsPkiCaInitCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsPkiCaInitCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	publisher, _ := cmd.Flags().GetString("publisher")
+	out, _ := cmd.Flags().GetString("out")
+	algo, _ := cmd.Flags().GetString("algo")
+	days, _ := cmd.Flags().GetInt("days")
+	trust, _ := cmd.Flags().GetBool("import")
+
+	if publisher == "" {
+		return errors.New("publisher required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.PkiCaInit(publisher, out, algo, days, trust)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"github.com/fezz-io/zps/cli"
+	"github.com/spf13/cobra"
+)
+
+type ZpsPkiCrlCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsPkiCrlCommand() *ZpsPkiCrlCommand {
+	cmd := &ZpsPkiCrlCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "crl"
+	cmd.Short = "Manage certificate revocation lists in ZPS pki store"
+	cmd.Long = "Manage certificate revocation lists in ZPS pki store"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.AddCommand(NewZpsPkiCrlImportCommand().Command)
+	cmd.AddCommand(NewZpsPkiCrlListCommand().Command)
+	cmd.AddCommand(NewZpsPkiCrlRemoveCommand().Command)
+
+	return cmd
+}
+
+func (z *ZpsPkiCrlCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsPkiCrlCommand) run(cmd *cobra.Command, args []string) error {
+	cmd.Help()
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsPkiCrlImportCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsPkiCrlImportCommand() *ZpsPkiCrlImportCommand {
+	cmd := &ZpsPkiCrlImportCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "import [CRL_FILE]"
+	cmd.Short = "Import certificate revocation list into the pki store"
+	cmd.Long = "Import certificate revocation list into the pki store"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsPkiCrlImportCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsPkiCrlImportCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("crl file name required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.PkiCrlImport(cmd.Flags().Arg(0))
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
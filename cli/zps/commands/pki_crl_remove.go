@@ -0,0 +1,62 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsPkiCrlRemoveCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsPkiCrlRemoveCommand() *ZpsPkiCrlRemoveCommand {
+	cmd := &ZpsPkiCrlRemoveCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "remove [ISSUER_FINGERPRINT]"
+	cmd.Short = "Remove certificate revocation list from ZPS pki store"
+	cmd.Long = "Remove certificate revocation list from ZPS pki store"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsPkiCrlRemoveCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsPkiCrlRemoveCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.PkiCrlRemove(cmd.Flags().Arg(0))
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
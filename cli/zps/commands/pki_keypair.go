@@ -30,9 +30,11 @@ func NewZpsPkiKeyPairCommand() *ZpsPkiKeyPairCommand {
 	cmd.PreRunE = cmd.setup
 	cmd.RunE = cmd.run
 
+	cmd.AddCommand(NewZpsPkiKeyPairCreateCommand().Command)
 	cmd.AddCommand(NewZpsPkiKeyPairImportCommand().Command)
 	cmd.AddCommand(NewZpsPkiKeyPairListCommand().Command)
 	cmd.AddCommand(NewZpsPkiKeyPairRemoveCommand().Command)
+	cmd.AddCommand(NewZpsPkiKeyPairSignCommand().Command)
 
 	return cmd
 }
@@ -0,0 +1,86 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/sec"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsPkiKeyPairCreateCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsPkiKeyPairCreateCommand() *ZpsPkiKeyPairCreateCommand {
+	cmd := &ZpsPkiKeyPairCreateCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "create"
+	cmd.Short = "Create a publisher signing key pair"
+	cmd.Long = "Create a publisher signing key pair issued by --ca-cert, or a certificate request for keypair sign"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("publisher", "", "publisher organization")
+	cmd.Flags().String("name", "", "certificate subject and file name, defaults to the publisher")
+	cmd.Flags().String("out", ".", "directory for the generated files")
+	cmd.Flags().String("algo", sec.AlgoEcdsaP256Sha256, "key algorithm [rsa|ecdsa-p256-sha256|ed25519]")
+	cmd.Flags().String("ca-cert", "", "issuing intermediate certificate")
+	cmd.Flags().String("ca-key", "", "issuing intermediate key")
+	cmd.Flags().Int("days", 365, "certificate validity in days")
+	cmd.Flags().Bool("import", false, "import the key pair and trust its certificate in the pki store")
+
+	return cmd
+}
+
+func (z *ZpsPkiKeyPairCreateCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsPkiKeyPairCreateCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	publisher, _ := cmd.Flags().GetString("publisher")
+	name, _ := cmd.Flags().GetString("name")
+	out, _ := cmd.Flags().GetString("out")
+	algo, _ := cmd.Flags().GetString("algo")
+	caCert, _ := cmd.Flags().GetString("ca-cert")
+	caKey, _ := cmd.Flags().GetString("ca-key")
+	days, _ := cmd.Flags().GetInt("days")
+	doImport, _ := cmd.Flags().GetBool("import")
+
+	if publisher == "" {
+		return errors.New("publisher required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.PkiKeyPairCreate(publisher, name, out, algo, caCert, caKey, days, doImport)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsPkiKeyPairSignCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsPkiKeyPairSignCommand() *ZpsPkiKeyPairSignCommand {
+	cmd := &ZpsPkiKeyPairSignCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "sign [CSR_FILE]"
+	cmd.Short = "Issue a publisher certificate for a certificate request"
+	cmd.Long = "Issue a publisher certificate for a certificate request, signed by an intermediate"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("ca-cert", "", "issuing intermediate certificate")
+	cmd.Flags().String("ca-key", "", "issuing intermediate key")
+	cmd.Flags().String("out", "", "certificate file, defaults to the request path with a .pem extension")
+	cmd.Flags().Int("days", 365, "certificate validity in days")
+
+	return cmd
+}
+
+func (z *ZpsPkiKeyPairSignCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsPkiKeyPairSignCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	caCert, _ := cmd.Flags().GetString("ca-cert")
+	caKey, _ := cmd.Flags().GetString("ca-key")
+	out, _ := cmd.Flags().GetString("out")
+	days, _ := cmd.Flags().GetInt("days")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("certificate request file name required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.PkiKeyPairSign(cmd.Flags().Arg(0), caCert, caKey, out, days)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -46,6 +46,7 @@ func (z *ZpsPlanCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsPlanCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 
 	// Load manager
 	mgr, err := zpm.NewManager(image)
@@ -54,6 +55,7 @@ func (z *ZpsPlanCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	if cmd.Flags().Arg(0) == "" {
 		return errors.New("plan action required")
@@ -0,0 +1,78 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+type ZpsProvidesCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsProvidesCommand() *ZpsProvidesCommand {
+	cmd := &ZpsProvidesCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "provides [PATH|GLOB]..."
+	cmd.Short = "Find repository packages that ship a path"
+	cmd.Long = "Find packages in the refreshed metadata of configured repositories that ship a path or glob, patterns without a slash match file names. Requires repositories publishing file lists"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	return cmd
+}
+
+func (z *ZpsProvidesCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsProvidesCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
+
+	if cmd.Flags().NArg() == 0 {
+		return errors.New("Must provide paths or globs")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
+
+	output, err := mgr.Provides(cmd.Flags().Args())
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	if len(output) == 0 {
+		z.Warn("No packages found")
+		return nil
+	}
+
+	z.Out(columnize.SimpleFormat(output) + "\n")
+
+	return nil
+}
@@ -44,6 +44,7 @@ func (z *ZpsRefreshCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsRefreshCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 
 	// Load manager
 	mgr, err := zpm.NewManager(image)
@@ -52,6 +53,7 @@ func (z *ZpsRefreshCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	err = mgr.Refresh()
 	if err != nil {
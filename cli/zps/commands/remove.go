@@ -46,6 +46,7 @@ func (z *ZpsRemoveCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsRemoveCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 
 	if cmd.Flags().NArg() == 0 {
 		return errors.New("Must provide at least one package uri to remove")
@@ -58,6 +59,7 @@ func (z *ZpsRemoveCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	err = mgr.Remove(args)
 	if err != nil {
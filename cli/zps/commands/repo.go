@@ -30,10 +30,15 @@ func NewZpsRepoCommand() *ZpsRepoCommand {
 	cmd.PreRunE = cmd.setup
 	cmd.RunE = cmd.run
 
+	cmd.AddCommand(NewZpsRepoCheckCommand().Command)
 	cmd.AddCommand(NewZpsRepoInitCommand().Command)
 	cmd.AddCommand(NewZpsRepoContentsCommand().Command)
 	cmd.AddCommand(NewZpsRepoListCommand().Command)
+	cmd.AddCommand(NewZpsRepoMirrorCommand().Command)
+	cmd.AddCommand(NewZpsRepoRemoveCommand().Command)
+	cmd.AddCommand(NewZpsRepoServeCommand().Command)
 	cmd.AddCommand(NewZpsRepoUpdateCommand().Command)
+	cmd.AddCommand(NewZpsRepoYankCommand().Command)
 	return cmd
 }
 
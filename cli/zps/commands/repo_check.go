@@ -0,0 +1,71 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsRepoCheckCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsRepoCheckCommand() *ZpsRepoCheckCommand {
+	cmd := &ZpsRepoCheckCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "check [REPO_NAME]"
+	cmd.Short = "Check the integrity of a ZPS repository"
+	cmd.Long = "Check package files, metadata and signatures of a ZPS repository"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().Bool("fix", false, "Drop missing packages, restore or delete orphaned files and re-sign metadata")
+
+	return cmd
+}
+
+func (z *ZpsRepoCheckCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsRepoCheckCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	fix, _ := cmd.Flags().GetBool("fix")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Repo name required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.RepoCheck(cmd.Flags().Arg(0), fix)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
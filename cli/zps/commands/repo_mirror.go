@@ -0,0 +1,85 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsRepoMirrorCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsRepoMirrorCommand() *ZpsRepoMirrorCommand {
+	cmd := &ZpsRepoMirrorCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "mirror [SRC_URI] [DST_REPO_NAME]"
+	cmd.Short = "Mirror a ZPS repository into a configured repository"
+	cmd.Long = "Mirror a ZPS repository into a configured repository"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().StringArray("channel", nil, "Only mirror packages in channel")
+	cmd.Flags().StringArray("name", nil, "Only mirror packages named")
+	cmd.Flags().Int("versions", 0, "Newest versions of each package to mirror, 0 for all")
+	cmd.Flags().Bool("resign", false, "Sign packages with the destination publisher key")
+
+	return cmd
+}
+
+func (z *ZpsRepoMirrorCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsRepoMirrorCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	channels, _ := cmd.Flags().GetStringArray("channel")
+	names, _ := cmd.Flags().GetStringArray("name")
+	versions, _ := cmd.Flags().GetInt("versions")
+	resign, _ := cmd.Flags().GetBool("resign")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Source repo uri required")
+	}
+
+	if cmd.Flags().Arg(1) == "" {
+		return errors.New("Destination repo name required")
+	}
+
+	if versions < 0 {
+		return errors.New("Versions must not be negative")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.RepoMirror(cmd.Flags().Arg(0), cmd.Flags().Arg(1), channels, names, versions, resign)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsRepoServeCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsRepoServeCommand() *ZpsRepoServeCommand {
+	cmd := &ZpsRepoServeCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "serve [REPO_NAME]"
+	cmd.Short = "Serve a file ZPS repository over HTTP(S)"
+	cmd.Long = "Serve a file ZPS repository over HTTP(S)"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("listen", ":8080", "Address to listen on")
+	cmd.Flags().String("user", "", "Basic auth user")
+	cmd.Flags().String("password", "", "Basic auth password, defaults to $ZPS_REPO_PASSWORD")
+	cmd.Flags().String("tls-cert", "", "TLS certificate PEM")
+	cmd.Flags().String("tls-key", "", "TLS private key PEM")
+	cmd.Flags().Bool("upload", false, "Accept uploads for remote publishing")
+	cmd.Flags().Int64("max-upload", 1<<30, "Maximum upload size in bytes")
+
+	return cmd
+}
+
+func (z *ZpsRepoServeCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsRepoServeCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	listen, _ := cmd.Flags().GetString("listen")
+	user, _ := cmd.Flags().GetString("user")
+	password, _ := cmd.Flags().GetString("password")
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+	upload, _ := cmd.Flags().GetBool("upload")
+	maxUpload, _ := cmd.Flags().GetInt64("max-upload")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Repo name required")
+	}
+
+	if password == "" {
+		password = os.Getenv("ZPS_REPO_PASSWORD")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.RepoServe(cmd.Flags().Arg(0), listen, user, password, tlsCert, tlsKey, upload, maxUpload)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
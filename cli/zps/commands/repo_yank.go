@@ -0,0 +1,75 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsRepoYankCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsRepoYankCommand() *ZpsRepoYankCommand {
+	cmd := &ZpsRepoYankCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "yank [REPO_NAME] [PKG@VERSION]..."
+	cmd.Short = "Yank packages in a ZPS repository"
+	cmd.Long = "Yank packages in a ZPS repository, yanked versions stay published but are not picked for new installs"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().Bool("undo", false, "Unyank packages")
+
+	return cmd
+}
+
+func (z *ZpsRepoYankCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsRepoYankCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	undo, _ := cmd.Flags().GetBool("undo")
+
+	if cmd.Flags().Arg(0) == "" {
+		return errors.New("Repo name required")
+	}
+
+	if cmd.Flags().NArg() < 2 {
+		return errors.New("Package version required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.RepoYank(cmd.Flags().Arg(0), cmd.Flags().Args()[1:], undo)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -106,6 +106,7 @@ func NewZpsRootCommand() *ZpsRootCommand {
 
 	cmd.PersistentFlags().Bool("no-color", false, "Disable color")
 	cmd.PersistentFlags().String("image", "", "ZPS image name/id")
+	cmd.PersistentFlags().Bool("allow-stale-metadata", false, "Use expired or rolled back repo metadata")
 
 	cmd.AddCommand(NewZpsCacheCommand().Command)
 	cmd.AddCommand(NewZpsChannelCommand().Command)
@@ -117,12 +118,15 @@ func NewZpsRootCommand() *ZpsRootCommand {
 	cmd.AddCommand(NewZpsInfoCommand().Command)
 	cmd.AddCommand(NewZpsInstallCommand().Command)
 	cmd.AddCommand(NewZpsListCommand().Command)
+	cmd.AddCommand(NewZpsOwnsCommand().Command)
 	cmd.AddCommand(NewZpsPkiCommand().Command)
 	cmd.AddCommand(NewZpsPlanCommand().Command)
 	cmd.AddCommand(NewZpsPublishCommand().Command)
+	cmd.AddCommand(NewZpsProvidesCommand().Command)
 	cmd.AddCommand(NewZpsRefreshCommand().Command)
 	cmd.AddCommand(NewZpsRemoveCommand().Command)
 	cmd.AddCommand(NewZpsRepoCommand().Command)
+	cmd.AddCommand(NewZpsSearchCommand().Command)
 	cmd.AddCommand(NewZpsStatusCommand().Command)
 	cmd.AddCommand(NewZpsThawCommand().Command)
 	cmd.AddCommand(NewZpsTplCommand().Command)
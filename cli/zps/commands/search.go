@@ -0,0 +1,117 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+type ZpsSearchCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsSearchCommand() *ZpsSearchCommand {
+	cmd := &ZpsSearchCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "search [TERM]..."
+	cmd.Short = "Search packages in configured repositories"
+	cmd.Long = "Search package names, summaries, descriptions, tags and publishers in the refreshed metadata of configured repositories"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().StringArray("channel", nil, "Only packages in channel, may be repeated")
+	cmd.Flags().String("os", "", "Only packages for os")
+	cmd.Flags().String("arch", "", "Only packages for arch")
+	cmd.Flags().String("publisher", "", "Only packages from publisher")
+	cmd.Flags().Bool("installed", false, "Only installed packages")
+	cmd.Flags().Bool("available", false, "Only packages not installed")
+	cmd.Flags().String("format", "text", "Output format (text, json)")
+
+	return cmd
+}
+
+func (z *ZpsSearchCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsSearchCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
+	channels, _ := cmd.Flags().GetStringArray("channel")
+	osName, _ := cmd.Flags().GetString("os")
+	arch, _ := cmd.Flags().GetString("arch")
+	publisher, _ := cmd.Flags().GetString("publisher")
+	installed, _ := cmd.Flags().GetBool("installed")
+	available, _ := cmd.Flags().GetBool("available")
+	format, _ := cmd.Flags().GetString("format")
+
+	if cmd.Flags().NArg() == 0 {
+		return errors.New("Must provide search terms")
+	}
+
+	if installed && available {
+		return errors.New("--installed and --available are exclusive")
+	}
+
+	if format != "text" && format != "json" {
+		return errors.New("--format must be text or json")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
+
+	results, err := mgr.Search(cmd.Flags().Args(), channels, osName, arch, publisher, installed, available)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	if format == "json" {
+		z.Out(results.ToJson() + "\n")
+		return nil
+	}
+
+	if len(results) == 0 {
+		z.Warn("No packages found")
+		return nil
+	}
+
+	var rows []string
+	for _, result := range results {
+		status := "[white]~"
+		if result.Installed != "" {
+			status = "[green]*"
+		}
+
+		rows = append(rows, strings.Join([]string{status, result.Name, result.Id, result.Os + "-" + result.Arch, result.Repo, result.Summary}, "|"))
+	}
+
+	z.Out(columnize.SimpleFormat(z.Colorize(rows)) + "\n")
+
+	return nil
+}
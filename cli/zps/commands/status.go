@@ -49,6 +49,7 @@ func (z *ZpsStatusCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsStatusCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 	var err error
 
 	if cmd.Flags().NArg() == 0 {
@@ -62,6 +63,7 @@ func (z *ZpsStatusCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	status, versions, err := mgr.Status(cmd.Flags().Arg(0))
 	if err != nil {
@@ -45,6 +45,7 @@ func (z *ZpsUpdateCommand) setup(cmd *cobra.Command, args []string) error {
 
 func (z *ZpsUpdateCommand) run(cmd *cobra.Command, args []string) error {
 	image, _ := cmd.Flags().GetString("image")
+	allowStale, _ := cmd.Flags().GetBool("allow-stale-metadata")
 
 	// Load manager
 	mgr, err := zpm.NewManager(image)
@@ -53,6 +54,7 @@ func (z *ZpsUpdateCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
+	mgr.AllowStaleMetadata(allowStale)
 
 	err = mgr.Update(cmd.Flags().Args())
 	if err != nil {
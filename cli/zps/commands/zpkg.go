@@ -32,9 +32,14 @@ func NewZpsZpkgCommand() *ZpsZpkgCommand {
 
 	cmd.AddCommand(NewZpsZpkgBuildCommand().Command)
 	cmd.AddCommand(NewZpsZpkgContentsCommand().Command)
+	cmd.AddCommand(NewZpsZpkgDiffCommand().Command)
+	cmd.AddCommand(NewZpsZpkgExportCommand().Command)
 	cmd.AddCommand(NewZpsZpkgExtractCommand().Command)
+	cmd.AddCommand(NewZpsZpkgImportCommand().Command)
 	cmd.AddCommand(NewZpsZpkgInfoCommand().Command)
+	cmd.AddCommand(NewZpsZpkgLintCommand().Command)
 	cmd.AddCommand(NewZpsZpkgManifestCommand().Command)
+	cmd.AddCommand(NewZpsZpkgSbomCommand().Command)
 	cmd.AddCommand(NewZpsZpkgSignCommand().Command)
 	cmd.AddCommand(NewZpsZpkgValidateCommand().Command)
 
@@ -11,6 +11,9 @@
 package commands
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/fezz-io/zps/cli"
 	"github.com/fezz-io/zps/zpm"
 	"github.com/spf13/cobra"
@@ -36,6 +39,8 @@ func NewZpsZpkgBuildCommand() *ZpsZpkgBuildCommand {
 	cmd.Flags().String("output-path", "", "Output path for ZPKG")
 	cmd.Flags().Bool("restrict", false, "Restrict included filesystem objects to those present in Zpkgfile")
 	cmd.Flags().Bool("secure", false, "Ensure filesystem objects are super user owned")
+	cmd.Flags().StringArray("var", nil, "Set a Zpkgfile variable, NAME=VALUE")
+	cmd.Flags().String("soname-depends", "suggest", "Depends on sonames needed by ELF files: add, suggest or off")
 
 	return cmd
 }
@@ -55,6 +60,18 @@ func (z *ZpsZpkgBuildCommand) run(cmd *cobra.Command, args []string) error {
 	workPath, _ := cmd.Flags().GetString("work-path")
 	restrict, _ := cmd.Flags().GetBool("restrict")
 	secure, _ := cmd.Flags().GetBool("secure")
+	varFlags, _ := cmd.Flags().GetStringArray("var")
+	sonameDepends, _ := cmd.Flags().GetString("soname-depends")
+
+	vars := make(map[string]string)
+	for _, v := range varFlags {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return errors.New("--var must be NAME=VALUE")
+		}
+
+		vars[kv[0]] = kv[1]
+	}
 
 	// Load manager
 	mgr, err := zpm.NewManager(image)
@@ -64,7 +81,7 @@ func (z *ZpsZpkgBuildCommand) run(cmd *cobra.Command, args []string) error {
 
 	SetupEventHandlers(mgr.Emitter, z.Ui)
 
-	err = mgr.ZpkgBuild(cmd.Flags().Arg(0), targetPath, workPath, outputPath, restrict, secure)
+	err = mgr.ZpkgBuild(cmd.Flags().Arg(0), targetPath, workPath, outputPath, restrict, secure, vars, sonameDepends)
 	if err != nil {
 		z.Fatal(err.Error())
 	}
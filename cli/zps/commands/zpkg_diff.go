@@ -0,0 +1,116 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpkg"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+type ZpsZpkgDiffCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsZpkgDiffCommand() *ZpsZpkgDiffCommand {
+	cmd := &ZpsZpkgDiffCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "diff [FROM_ZPKG_PATH] [TO_ZPKG_PATH]"
+	cmd.Short = "Compare two ZPKGs"
+	cmd.Long = "Compare the manifests of two ZPKGs, optionally with unified diffs of changed text files"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("format", "text", "Output format (text, json)")
+	cmd.Flags().Bool("content", false, "Show unified diffs of changed text files")
+	cmd.Flags().String("work-path", "", "Work path for staging payload content")
+
+	return cmd
+}
+
+func (z *ZpsZpkgDiffCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsZpkgDiffCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	format, _ := cmd.Flags().GetString("format")
+	content, _ := cmd.Flags().GetBool("content")
+	workPath, _ := cmd.Flags().GetString("work-path")
+
+	if cmd.Flags().NArg() != 2 {
+		return errors.New("two ZPKG Filenames required")
+	}
+
+	if format != "text" && format != "json" {
+		return errors.New("--format must be text or json")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	diff, err := mgr.ZpkgDiff(cmd.Flags().Arg(0), cmd.Flags().Arg(1), workPath, content)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	if format == "json" {
+		z.Out(diff.ToJson() + "\n")
+		return nil
+	}
+
+	z.Blue(diff.From + " -> " + diff.To)
+
+	if len(diff.Changes) == 0 {
+		return nil
+	}
+
+	var rows []string
+	for _, change := range diff.Changes {
+		var status string
+		switch change.Status {
+		case zpkg.DiffAdded:
+			status = "[green]+"
+		case zpkg.DiffRemoved:
+			status = "[red]-"
+		default:
+			status = "[yellow]~"
+		}
+
+		rows = append(rows, strings.Join([]string{status, change.Type, change.Key, strings.Join(change.Details, ", ")}, "|"))
+	}
+
+	z.Out(columnize.SimpleFormat(z.Colorize(rows)) + "\n")
+
+	for _, change := range diff.Changes {
+		if change.Content != "" {
+			z.Out("\n" + change.Content)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsZpkgExportCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsZpkgExportCommand() *ZpsZpkgExportCommand {
+	cmd := &ZpsZpkgExportCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "export [ZPKG_PATH]"
+	cmd.Short = "Export a ZPKG to another format"
+	cmd.Long = "Export a ZPKG to another format"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().Bool("oci", false, "Export as an OCI image layer tarball")
+	cmd.Flags().String("work-path", "", "Work path for export")
+	cmd.Flags().String("output-path", "", "Output path for export")
+
+	return cmd
+}
+
+func (z *ZpsZpkgExportCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsZpkgExportCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	ociFormat, _ := cmd.Flags().GetBool("oci")
+	workPath, _ := cmd.Flags().GetString("work-path")
+	outputPath, _ := cmd.Flags().GetString("output-path")
+
+	if cmd.Flags().NArg() == 0 {
+		return errors.New("ZPKG Filename required")
+	}
+
+	if !ociFormat {
+		return errors.New("Export format required (--oci)")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.ZpkgExport(cmd.Flags().Arg(0), outputPath, workPath)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsZpkgImportCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsZpkgImportCommand() *ZpsZpkgImportCommand {
+	cmd := &ZpsZpkgImportCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "import [FILE]"
+	cmd.Short = "Import a deb, rpm or tar archive as a ZPKG"
+	cmd.Long = "Import a deb, rpm or tar archive as a ZPKG"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("format", "", "Archive format (deb, rpm, tar), detected if omitted")
+	cmd.Flags().String("name", "", "Override package name")
+	cmd.Flags().String("version", "", "Override package version")
+	cmd.Flags().String("publisher", "", "Package publisher")
+	cmd.Flags().String("os", "", "Override package os")
+	cmd.Flags().String("arch", "", "Override package arch")
+	cmd.Flags().String("work-path", "", "Work path for ZPKG creation")
+	cmd.Flags().String("output-path", "", "Output path for ZPKG")
+	cmd.Flags().Bool("secure", false, "Ensure filesystem objects are super user owned")
+
+	return cmd
+}
+
+func (z *ZpsZpkgImportCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsZpkgImportCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	format, _ := cmd.Flags().GetString("format")
+	name, _ := cmd.Flags().GetString("name")
+	version, _ := cmd.Flags().GetString("version")
+	publisher, _ := cmd.Flags().GetString("publisher")
+	pkgOs, _ := cmd.Flags().GetString("os")
+	arch, _ := cmd.Flags().GetString("arch")
+	workPath, _ := cmd.Flags().GetString("work-path")
+	outputPath, _ := cmd.Flags().GetString("output-path")
+	secure, _ := cmd.Flags().GetBool("secure")
+
+	if cmd.Flags().NArg() == 0 {
+		return errors.New("Must provide an archive to import")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	err = mgr.ZpkgImport(cmd.Flags().Arg(0), format, name, version, publisher, pkgOs, arch, workPath, outputPath, secure)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	return nil
+}
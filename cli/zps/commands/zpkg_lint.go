@@ -0,0 +1,130 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/lint"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+)
+
+type ZpsZpkgLintCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsZpkgLintCommand() *ZpsZpkgLintCommand {
+	cmd := &ZpsZpkgLintCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "lint [ZPKG_PATH]"
+	cmd.Short = "Lint a ZPKG against policy rules"
+	cmd.Long = "Lint a ZPKG against policy rules, exits non zero when a finding reaches --fail-on"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	var rules []string
+	for _, rule := range lint.DefaultLinter().Rules() {
+		rules = append(rules, fmt.Sprintf("%s (%s)", rule.Name(), rule.Severity()))
+	}
+
+	cmd.Flags().String("format", "text", "Report format (text, json)")
+	cmd.Flags().String("fail-on", "error", "Lowest severity that fails the lint (info, warning, error, off)")
+	cmd.Flags().StringArray("severity", nil, "Override a rule severity, RULE=SEVERITY, rules: "+strings.Join(rules, ", "))
+	cmd.Flags().Bool("secure", false, "Require file system objects to be super user owned")
+	cmd.Flags().String("work-path", "", "Work path for staging payload content")
+
+	return cmd
+}
+
+func (z *ZpsZpkgLintCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsZpkgLintCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	format, _ := cmd.Flags().GetString("format")
+	failOn, _ := cmd.Flags().GetString("fail-on")
+	severityFlags, _ := cmd.Flags().GetStringArray("severity")
+	secure, _ := cmd.Flags().GetBool("secure")
+	workPath, _ := cmd.Flags().GetString("work-path")
+
+	if cmd.Flags().NArg() != 1 {
+		return errors.New("ZPKG Filename required")
+	}
+
+	if format != "text" && format != "json" {
+		return errors.New("--format must be text or json")
+	}
+
+	threshold, err := lint.ParseSeverity(failOn)
+	if err != nil {
+		return err
+	}
+
+	severities := make(map[string]string)
+	for _, flag := range severityFlags {
+		kv := strings.SplitN(flag, "=", 2)
+		if len(kv) != 2 {
+			return errors.New("--severity must be RULE=SEVERITY")
+		}
+
+		severities[kv[0]] = kv[1]
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	report, err := mgr.ZpkgLint(cmd.Flags().Arg(0), workPath, secure, severities)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	if format == "json" {
+		z.Out(report.ToJson() + "\n")
+	} else if len(report.Findings) > 0 {
+		var rows []string
+		for _, finding := range report.Findings {
+			color := "[blue]"
+			switch finding.Severity {
+			case lint.SeverityError:
+				color = "[red]"
+			case lint.SeverityWarning:
+				color = "[yellow]"
+			}
+
+			rows = append(rows, strings.Join([]string{color + finding.Severity.String(), finding.Rule, finding.Path, finding.Message}, "|"))
+		}
+
+		z.Out(columnize.SimpleFormat(z.Colorize(rows)) + "\n")
+	}
+
+	if threshold != lint.SeverityOff && report.Fails(threshold) {
+		z.Fatal(fmt.Sprintf("lint failed: %d errors, %d warnings", report.Errors, report.Warnings))
+	}
+
+	return nil
+}
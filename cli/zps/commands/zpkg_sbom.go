@@ -0,0 +1,73 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package commands
+
+import (
+	"errors"
+
+	"github.com/fezz-io/zps/cli"
+	"github.com/fezz-io/zps/zpm"
+	"github.com/spf13/cobra"
+)
+
+type ZpsZpkgSbomCommand struct {
+	*cobra.Command
+	*cli.Ui
+}
+
+func NewZpsZpkgSbomCommand() *ZpsZpkgSbomCommand {
+	cmd := &ZpsZpkgSbomCommand{}
+	cmd.Command = &cobra.Command{}
+	cmd.Ui = cli.NewUi()
+	cmd.Use = "sbom [ZPKG_PATH]"
+	cmd.Short = "Generate an SBOM for a ZPKG"
+	cmd.Long = "Generate an SBOM for a ZPKG in SPDX or CycloneDX JSON"
+	cmd.PreRunE = cmd.setup
+	cmd.RunE = cmd.run
+
+	cmd.Flags().String("format", "spdx", "SBOM format (spdx, cyclonedx)")
+
+	return cmd
+}
+
+func (z *ZpsZpkgSbomCommand) setup(cmd *cobra.Command, args []string) error {
+	color, err := cmd.Flags().GetBool("no-color")
+
+	z.NoColor(color)
+
+	return err
+}
+
+func (z *ZpsZpkgSbomCommand) run(cmd *cobra.Command, args []string) error {
+	image, _ := cmd.Flags().GetString("image")
+	format, _ := cmd.Flags().GetString("format")
+
+	if cmd.Flags().NArg() != 1 {
+		return errors.New("ZPKG Filename required")
+	}
+
+	// Load manager
+	mgr, err := zpm.NewManager(image)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	SetupEventHandlers(mgr.Emitter, z.Ui)
+
+	output, err := mgr.ZpkgSbom(cmd.Flags().Arg(0), format)
+	if err != nil {
+		z.Fatal(err.Error())
+	}
+
+	z.Out(output)
+
+	return err
+}
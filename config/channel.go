@@ -0,0 +1,32 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package config
+
+import (
+	"github.com/fezz-io/zps/zps"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Packages matching the query are added to the channel when publishing
+// and by zps channel sync
+type ChannelConfig struct {
+	Name string `hcl:"name,label"`
+
+	Query       *zps.Query
+	QueryString string `hcl:"query"`
+}
+
+func (c *ChannelConfig) appendHcl(body *hclwrite.Body) {
+	channel := body.AppendNewBlock("channel", []string{c.Name})
+
+	channel.Body().SetAttributeValue("query", cty.StringVal(c.QueryString))
+}
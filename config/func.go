@@ -5,9 +5,22 @@ import (
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/convert"
 	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
 )
 
-func (z *ZpsConfig) configDefault() function.Function {
+// Function library shared by configs, templates and Zpkgfiles
+func Functions() map[string]function.Function {
+	return map[string]function.Function{
+		"upper":          stdlib.UpperFunc,
+		"lower":          stdlib.LowerFunc,
+		"length":         stdlib.LengthFunc,
+		"lookup":         stdlib.LookupFunc,
+		"config_default": configDefault(),
+		"coalesce":       coalesce(),
+	}
+}
+
+func configDefault() function.Function {
 	return function.New(
 		&function.Spec{
 			Params: []function.Parameter{
@@ -32,7 +45,7 @@ func (z *ZpsConfig) configDefault() function.Function {
 	)
 }
 
-func (z *ZpsConfig) coalesce() function.Function {
+func coalesce() function.Function {
 	return function.New(&function.Spec{
 		Params: []function.Parameter{},
 		VarParam: &function.Parameter{
@@ -0,0 +1,68 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package config
+
+import (
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// License and provenance rules, in main.conf they apply to installs,
+// in a repo publish block to publishing and channel changes
+type PolicyConfig struct {
+	Channels []string `hcl:"channels,optional"`
+
+	DenyLicenses      []string `hcl:"deny_licenses,optional"`
+	AllowLicenses     []string `hcl:"allow_licenses,optional"`
+	RequireProvenance bool     `hcl:"require_provenance,optional"`
+}
+
+// Policies without channels apply to everything
+func (p *PolicyConfig) Applies(channel string) bool {
+	if len(p.Channels) == 0 {
+		return true
+	}
+
+	for _, ch := range p.Channels {
+		if ch == channel {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *PolicyConfig) appendHcl(body *hclwrite.Body) {
+	policy := body.AppendNewBlock("policy", nil)
+
+	if len(p.Channels) > 0 {
+		policy.Body().SetAttributeValue("channels", stringList(p.Channels))
+	}
+	if len(p.DenyLicenses) > 0 {
+		policy.Body().SetAttributeValue("deny_licenses", stringList(p.DenyLicenses))
+	}
+	if len(p.AllowLicenses) > 0 {
+		policy.Body().SetAttributeValue("allow_licenses", stringList(p.AllowLicenses))
+	}
+	if p.RequireProvenance {
+		policy.Body().SetAttributeValue("require_provenance", cty.True)
+	}
+}
+
+func stringList(values []string) cty.Value {
+	var list []cty.Value
+
+	for _, value := range values {
+		list = append(list, cty.StringVal(value))
+	}
+
+	return cty.ListVal(list)
+}
@@ -12,11 +12,15 @@ package config
 
 import (
 	"net/url"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
 )
 
+// Metadata lifetime for publish blocks without expires
+const DefaultMetadataExpiry = 90 * 24 * time.Hour
+
 type RepoConfig struct {
 	// Used only for Imagefile
 	Name string `hcl:"name,label"`
@@ -40,6 +44,16 @@ type PublishConfig struct {
 	UriString string `hcl:"uri"`
 	Name      string `hcl:"name"`
 	Prune     int    `hcl:"prune"`
+
+	// Lifetime of signed metadata, clients refuse expired metadata
+	Expires       time.Duration
+	ExpiresString string `hcl:"expires,optional"`
+
+	// Record the files of published packages in metadata for zps provides
+	Files bool `hcl:"files,optional"`
+
+	Policies []*PolicyConfig  `hcl:"policy,block"`
+	Channels []*ChannelConfig `hcl:"channel,block"`
 }
 
 // Sadly there is no way yet to dump a struct to HCL
@@ -75,6 +89,22 @@ func (r *RepoConfig) ToHclFile() *hclwrite.File {
 		publish.Body().SetAttributeValue("uri", cty.StringVal(r.Publish.UriString))
 		publish.Body().SetAttributeValue("name", cty.StringVal(r.Publish.Name))
 		publish.Body().SetAttributeValue("prune", cty.NumberIntVal(int64(r.Publish.Prune)))
+
+		if r.Publish.ExpiresString != "" {
+			publish.Body().SetAttributeValue("expires", cty.StringVal(r.Publish.ExpiresString))
+		}
+
+		if r.Publish.Files {
+			publish.Body().SetAttributeValue("files", cty.True)
+		}
+
+		for _, policy := range r.Publish.Policies {
+			policy.appendHcl(publish.Body())
+		}
+
+		for _, channel := range r.Publish.Channels {
+			channel.appendHcl(publish.Body())
+		}
 	}
 
 	return file
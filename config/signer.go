@@ -0,0 +1,29 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package config
+
+// Signing key held outside the pki store for a publisher, type is
+// external or pkcs11, certificate is the path to the publisher cert
+type SignerConfig struct {
+	Type        string `hcl:"type,label"`
+	Publisher   string `hcl:"publisher"`
+	Certificate string `hcl:"certificate"`
+
+	// external
+	Command []string `hcl:"command,optional"`
+
+	// pkcs11
+	Module string `hcl:"module,optional"`
+	KeyId  string `hcl:"key_id,optional"`
+	Slot   string `hcl:"slot,optional"`
+	PinEnv string `hcl:"pin_env,optional"`
+	Tool   string `hcl:"tool,optional"`
+}
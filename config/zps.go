@@ -12,6 +12,7 @@ package config
 
 import (
 	"github.com/fezz-io/zps/cloud"
+	"github.com/fezz-io/zps/zps"
 	"io/ioutil"
 	"os"
 	"path"
@@ -20,8 +21,6 @@ import (
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
-	"github.com/zclconf/go-cty/cty/function"
-	"github.com/zclconf/go-cty/cty/function/stdlib"
 
 	"github.com/hashicorp/hcl/v2/hclparse"
 
@@ -31,6 +30,7 @@ import (
 	"net/url"
 
 	"runtime"
+	"time"
 
 	"github.com/hashicorp/hcl/v2/gohcl"
 )
@@ -46,6 +46,9 @@ type ZpsConfig struct {
 	Mode     string `hcl:"mode"`
 	Security string `hcl:"security"`
 
+	Policies []*PolicyConfig `hcl:"policy,block"`
+	Signers  []*SignerConfig `hcl:"signer,block"`
+
 	Root         string
 	CurrentImage *ImageConfig
 
@@ -372,6 +375,21 @@ func (z *ZpsConfig) LoadRepos() error {
 			} else {
 				return errors.New(fmt.Sprint("config: repo publish.uri required in ", rconfig))
 			}
+
+			repo.Publish.Expires = DefaultMetadataExpiry
+			if repo.Publish.ExpiresString != "" {
+				repo.Publish.Expires, err = time.ParseDuration(repo.Publish.ExpiresString)
+				if err != nil || repo.Publish.Expires < 0 {
+					return errors.New(fmt.Sprint("config: invalid repo publish.expires in ", rconfig))
+				}
+			}
+
+			for _, channel := range repo.Publish.Channels {
+				channel.Query, err = zps.ParseQuery(channel.QueryString)
+				if err != nil {
+					return errors.New(fmt.Sprint("config: invalid repo publish.channel ", channel.Name, " query in ", rconfig, ": ", err.Error()))
+				}
+			}
 		}
 
 		z.Repos = append(z.Repos, repo)
@@ -478,14 +496,7 @@ func (z *ZpsConfig) LoadHclContext() error {
 	}
 
 	z.hclCtx.Variables["env"] = cty.ObjectVal(envs)
-	z.hclCtx.Functions = map[string]function.Function{
-		"upper":          stdlib.UpperFunc,
-		"lower":          stdlib.LowerFunc,
-		"length":         stdlib.LengthFunc,
-		"lookup":         stdlib.LookupFunc,
-		"config_default": z.configDefault(),
-		"coalesce":       z.coalesce(),
-	}
+	z.hclCtx.Functions = Functions()
 
 	z.hclCtx.Variables["cloud"] = cloud.MetaFetch()
 
@@ -18,6 +18,7 @@ require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/hashicorp/hcl/v2 v2.3.0
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0
+	github.com/klauspost/compress v1.11.7
 	github.com/lunixbochs/struc v0.0.0-20190916212049-a5c72983bc42
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db
 	github.com/naegelejd/go-acl v0.0.0-20190510140445-686b8e62cbee
@@ -27,6 +28,7 @@ require (
 	github.com/segmentio/ksuid v1.0.2
 	github.com/spf13/cobra v0.0.5
 	github.com/tombuildsstuff/giovanni v0.15.1
+	github.com/ulikunitz/xz v0.5.10
 	github.com/zclconf/go-cty v1.3.1
 	go.etcd.io/bbolt v1.3.3
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
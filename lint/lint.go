@@ -0,0 +1,217 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fezz-io/zps/action"
+)
+
+type Severity int
+
+const (
+	SeverityOff Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+func ParseSeverity(severity string) (Severity, error) {
+	switch strings.ToLower(severity) {
+	case "off":
+		return SeverityOff, nil
+	case "info":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return SeverityOff, errors.New("lint: unknown severity " + severity)
+	}
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "off"
+	}
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Package under inspection, Source returns payload content for a file
+type Package struct {
+	Manifest *action.Manifest
+
+	Source func(file *action.File) (io.ReadCloser, error)
+}
+
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path,omitempty"`
+	Message  string   `json:"message"`
+}
+
+type Report struct {
+	Package  string     `json:"package"`
+	Findings []*Finding `json:"findings"`
+
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+}
+
+// Reports whether any finding is at or above a severity
+func (r *Report) Fails(severity Severity) bool {
+	for _, finding := range r.Findings {
+		if finding.Severity >= severity {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Report) ToJson() string {
+	out, _ := json.MarshalIndent(r, "", "    ")
+
+	return string(out)
+}
+
+type Rule interface {
+	Name() string
+	Description() string
+
+	// Severity unless overridden
+	Severity() Severity
+
+	Check(pkg *Package) ([]*Finding, error)
+}
+
+type Linter struct {
+	rules      []Rule
+	severities map[string]Severity
+}
+
+func New() *Linter {
+	return &Linter{severities: make(map[string]Severity)}
+}
+
+func DefaultLinter() *Linter {
+	linter := New()
+
+	linter.
+		Register(&NonRootOwner{}).
+		Register(&WorldWritable{}).
+		Register(&SetUid{}).
+		Register(&MissingVcsUri{}).
+		Register(&DanglingSymLink{}).
+		Register(&UnboundedDependency{}).
+		Register(&ElfArch{})
+
+	return linter
+}
+
+// Register Rule
+func (l *Linter) Register(rule Rule) *Linter {
+	l.rules = append(l.rules, rule)
+
+	return l
+}
+
+func (l *Linter) Rules() []Rule {
+	return l.rules
+}
+
+// Override the severity of a rule
+func (l *Linter) Severity(name string, severity Severity) error {
+	for _, rule := range l.rules {
+		if rule.Name() == name {
+			l.severities[name] = severity
+			return nil
+		}
+	}
+
+	return errors.New("lint: unknown rule " + name)
+}
+
+func (l *Linter) severity(rule Rule) Severity {
+	if severity, ok := l.severities[rule.Name()]; ok {
+		return severity
+	}
+
+	return rule.Severity()
+}
+
+// Run every enabled rule, findings are sorted by severity then rule and path
+func (l *Linter) Lint(pkg *Package) (*Report, error) {
+	report := &Report{Findings: []*Finding{}}
+
+	if pkg.Manifest.Zpkg != nil {
+		report.Package = pkg.Manifest.Zpkg.Name + "@" + pkg.Manifest.Zpkg.Version
+	}
+
+	for _, rule := range l.rules {
+		severity := l.severity(rule)
+		if severity == SeverityOff {
+			continue
+		}
+
+		findings, err := rule.Check(pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, finding := range findings {
+			finding.Rule = rule.Name()
+			finding.Severity = severity
+
+			switch severity {
+			case SeverityError:
+				report.Errors++
+			case SeverityWarning:
+				report.Warnings++
+			}
+		}
+
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+
+		return a.Path < b.Path
+	})
+
+	return report, nil
+}
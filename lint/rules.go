@@ -0,0 +1,321 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package lint
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/fezz-io/zps/action"
+)
+
+const TagVcsUri = "zps.vcs.uri"
+
+// Non root owned objects, only enabled for secure builds by default
+type NonRootOwner struct{}
+
+func (r *NonRootOwner) Name() string {
+	return "non-root-owner"
+}
+
+func (r *NonRootOwner) Description() string {
+	return "file system objects owned by a user or group other than root"
+}
+
+func (r *NonRootOwner) Severity() Severity {
+	return SeverityOff
+}
+
+func (r *NonRootOwner) Check(pkg *Package) ([]*Finding, error) {
+	var findings []*Finding
+
+	for _, act := range pkg.Manifest.Section("Dir", "File", "SymLink") {
+		owner, group := owners(act)
+
+		if (owner != "" && owner != "root") || (group != "" && group != "root") {
+			findings = append(findings, &Finding{
+				Path:    act.Key(),
+				Message: fmt.Sprintf("owned by %s:%s", owner, group),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+type WorldWritable struct{}
+
+func (r *WorldWritable) Name() string {
+	return "world-writable"
+}
+
+func (r *WorldWritable) Description() string {
+	return "world writable files, or dirs without the sticky bit"
+}
+
+func (r *WorldWritable) Severity() Severity {
+	return SeverityWarning
+}
+
+func (r *WorldWritable) Check(pkg *Package) ([]*Finding, error) {
+	var findings []*Finding
+
+	for _, dir := range pkg.Manifest.Dirs {
+		if mode, ok := parseMode(dir.Mode); ok && mode&0002 != 0 && mode&01000 == 0 {
+			findings = append(findings, &Finding{Path: dir.Path, Message: "dir mode " + dir.Mode})
+		}
+	}
+
+	for _, file := range pkg.Manifest.Files {
+		if mode, ok := parseMode(file.Mode); ok && mode&0002 != 0 {
+			findings = append(findings, &Finding{Path: file.Path, Message: "file mode " + file.Mode})
+		}
+	}
+
+	return findings, nil
+}
+
+type SetUid struct{}
+
+func (r *SetUid) Name() string {
+	return "setuid"
+}
+
+func (r *SetUid) Description() string {
+	return "files with the setuid or setgid bit"
+}
+
+func (r *SetUid) Severity() Severity {
+	return SeverityWarning
+}
+
+func (r *SetUid) Check(pkg *Package) ([]*Finding, error) {
+	var findings []*Finding
+
+	for _, file := range pkg.Manifest.Files {
+		mode, ok := parseMode(file.Mode)
+		if !ok {
+			continue
+		}
+
+		if mode&04000 != 0 {
+			findings = append(findings, &Finding{Path: file.Path, Message: "setuid, mode " + file.Mode})
+		} else if mode&02000 != 0 {
+			findings = append(findings, &Finding{Path: file.Path, Message: "setgid, mode " + file.Mode})
+		}
+	}
+
+	return findings, nil
+}
+
+type MissingVcsUri struct{}
+
+func (r *MissingVcsUri) Name() string {
+	return "missing-vcs-uri"
+}
+
+func (r *MissingVcsUri) Description() string {
+	return "no " + TagVcsUri + " tag or provenance vcs_uri"
+}
+
+func (r *MissingVcsUri) Severity() Severity {
+	return SeverityWarning
+}
+
+func (r *MissingVcsUri) Check(pkg *Package) ([]*Finding, error) {
+	if pkg.Manifest.Provenance != nil && pkg.Manifest.Provenance.VcsUri != "" {
+		return nil, nil
+	}
+
+	for _, tag := range pkg.Manifest.Tags {
+		if tag.Name == TagVcsUri && tag.Value != "" {
+			return nil, nil
+		}
+	}
+
+	return []*Finding{{Message: "package does not declare its source repository"}}, nil
+}
+
+// Symlinks whose target is not part of the package
+type DanglingSymLink struct{}
+
+func (r *DanglingSymLink) Name() string {
+	return "dangling-symlink"
+}
+
+func (r *DanglingSymLink) Description() string {
+	return "symlinks pointing outside of the package contents"
+}
+
+func (r *DanglingSymLink) Severity() Severity {
+	return SeverityWarning
+}
+
+func (r *DanglingSymLink) Check(pkg *Package) ([]*Finding, error) {
+	var findings []*Finding
+
+	contents := make(map[string]bool)
+	for _, act := range pkg.Manifest.Section("Dir", "File", "SymLink") {
+		contents[act.Key()] = true
+	}
+
+	for _, symlink := range pkg.Manifest.SymLinks {
+		target := symlink.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(path.Clean(target), "/")
+		} else {
+			target = path.Join(path.Dir(symlink.Path), target)
+		}
+
+		if !contents[target] {
+			findings = append(findings, &Finding{Path: symlink.Path, Message: "target " + symlink.Target + " is not packaged"})
+		}
+	}
+
+	return findings, nil
+}
+
+type UnboundedDependency struct{}
+
+func (r *UnboundedDependency) Name() string {
+	return "unbounded-dependency"
+}
+
+func (r *UnboundedDependency) Description() string {
+	return "dependencies without a version bound"
+}
+
+func (r *UnboundedDependency) Severity() Severity {
+	return SeverityInfo
+}
+
+func (r *UnboundedDependency) Check(pkg *Package) ([]*Finding, error) {
+	var findings []*Finding
+
+	for _, req := range pkg.Manifest.Requirements {
+		if req.Method == "depends" && (req.Operation == "ANY" || req.Operation == "" || req.Version == "") {
+			findings = append(findings, &Finding{Message: "depends on " + req.Name + " without a version"})
+		}
+	}
+
+	return findings, nil
+}
+
+// ELF objects built for another architecture than the package
+type ElfArch struct{}
+
+func (r *ElfArch) Name() string {
+	return "elf-arch-mismatch"
+}
+
+func (r *ElfArch) Description() string {
+	return "ELF binaries whose machine does not match zpkg.arch"
+}
+
+func (r *ElfArch) Severity() Severity {
+	return SeverityError
+}
+
+func (r *ElfArch) Check(pkg *Package) ([]*Finding, error) {
+	var findings []*Finding
+
+	if pkg.Source == nil || pkg.Manifest.Zpkg == nil {
+		return nil, nil
+	}
+
+	machines := map[string]elf.Machine{
+		"x86_64": elf.EM_X86_64,
+		"arm64":  elf.EM_AARCH64,
+	}
+
+	expected, ok := machines[pkg.Manifest.Zpkg.Arch]
+	if !ok {
+		return nil, nil
+	}
+
+	for _, file := range pkg.Manifest.Files {
+		if file.Size < 20 {
+			continue
+		}
+
+		machine, ok, err := elfMachine(pkg, file)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok && machine != expected {
+			findings = append(findings, &Finding{
+				Path:    file.Path,
+				Message: fmt.Sprintf("ELF machine %s, zpkg arch %s", machine, pkg.Manifest.Zpkg.Arch),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// Reads e_machine from the ELF identification and header
+func elfMachine(pkg *Package, file *action.File) (elf.Machine, bool, error) {
+	content, err := pkg.Source(file)
+	if err != nil {
+		return 0, false, err
+	}
+	defer content.Close()
+
+	header := make([]byte, 20)
+	_, err = io.ReadFull(content, header)
+	if err != nil {
+		return 0, false, nil
+	}
+
+	if !bytes.Equal(header[:4], []byte(elf.ELFMAG)) {
+		return 0, false, nil
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if elf.Data(header[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+
+	return elf.Machine(order.Uint16(header[18:20])), true, nil
+}
+
+func owners(act action.Action) (string, string) {
+	switch obj := act.(type) {
+	case *action.Dir:
+		return obj.Owner, obj.Group
+	case *action.File:
+		return obj.Owner, obj.Group
+	case *action.SymLink:
+		return obj.Owner, obj.Group
+	}
+
+	return "", ""
+}
+
+func parseMode(mode string) (uint64, bool) {
+	if mode == "" {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
@@ -0,0 +1,118 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package oci
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Maps owner and group names onto numeric ids for tar headers
+type IdMap struct {
+	users  map[string]int
+	groups map[string]int
+
+	unknown map[string]bool
+}
+
+// Load ids from the passwd and group files below root, names not
+// found there fall back to the host databases and finally to root
+func NewIdMap(root string) *IdMap {
+	ids := &IdMap{
+		users:   map[string]int{"root": 0},
+		groups:  map[string]int{"root": 0},
+		unknown: make(map[string]bool),
+	}
+
+	if root != "" {
+		loadIds(filepath.Join(root, "etc", "passwd"), ids.users)
+		loadIds(filepath.Join(root, "etc", "group"), ids.groups)
+	}
+
+	return ids
+}
+
+func (i *IdMap) Uid(name string) int {
+	if id, ok := i.users[name]; ok {
+		return id
+	}
+
+	if usr, err := user.Lookup(name); err == nil {
+		if id, err := strconv.Atoi(usr.Uid); err == nil {
+			i.users[name] = id
+			return id
+		}
+	}
+
+	i.unknown["user "+name] = true
+	i.users[name] = 0
+
+	return 0
+}
+
+func (i *IdMap) Gid(name string) int {
+	if id, ok := i.groups[name]; ok {
+		return id
+	}
+
+	if grp, err := user.LookupGroup(name); err == nil {
+		if id, err := strconv.Atoi(grp.Gid); err == nil {
+			i.groups[name] = id
+			return id
+		}
+	}
+
+	i.unknown["group "+name] = true
+	i.groups[name] = 0
+
+	return 0
+}
+
+// Names that could not be resolved and were mapped to root
+func (i *IdMap) Unknown() []string {
+	var unknown []string
+
+	for name := range i.unknown {
+		unknown = append(unknown, name)
+	}
+
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+func loadIds(path string, ids map[string]int) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+
+		ids[fields[0]] = id
+	}
+}
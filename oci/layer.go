@@ -0,0 +1,203 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fezz-io/zps/action"
+)
+
+const (
+	MediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+)
+
+// Source supplies the content of a packaged file
+type Source func(file *action.File) (io.ReadCloser, int64, error)
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	Os           string `json:"os"`
+}
+
+type Layer struct {
+	Descriptor
+
+	DiffId string
+}
+
+// Write the Dir, File and SymLink actions of a manifest as a gzip compressed
+// layer tarball, entries are ordered by path so output is reproducible
+func WriteLayer(writer io.Writer, manifest *action.Manifest, source Source, ids *IdMap, modTime time.Time) (*Layer, error) {
+	compressed := &counter{hash: sha256.New()}
+	gz := gzip.NewWriter(io.MultiWriter(writer, compressed))
+
+	uncompressed := &counter{hash: sha256.New()}
+	tw := tar.NewWriter(io.MultiWriter(gz, uncompressed))
+
+	contents := manifest.Section("Dir", "File", "SymLink")
+	sort.Sort(contents)
+
+	for _, fsObject := range contents {
+		var err error
+
+		switch object := fsObject.(type) {
+		case *action.Dir:
+			err = writeDir(tw, object, ids, modTime)
+		case *action.File:
+			err = writeFile(tw, object, source, ids, modTime)
+		case *action.SymLink:
+			err = writeSymLink(tw, object, ids, modTime)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	layer := &Layer{}
+	layer.MediaType = MediaTypeLayer
+	layer.Digest = compressed.Digest()
+	layer.Size = compressed.size
+	layer.DiffId = uncompressed.Digest()
+
+	return layer, nil
+}
+
+func writeDir(tw *tar.Writer, dir *action.Dir, ids *IdMap, modTime time.Time) error {
+	mode, err := parseMode(dir.Mode)
+	if err != nil {
+		return fmt.Errorf("oci: dir %s: %s", dir.Path, err.Error())
+	}
+
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir.Path + "/",
+		Mode:     mode,
+		Uid:      ids.Uid(dir.Owner),
+		Gid:      ids.Gid(dir.Group),
+		Uname:    dir.Owner,
+		Gname:    dir.Group,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+}
+
+func writeFile(tw *tar.Writer, file *action.File, source Source, ids *IdMap, modTime time.Time) error {
+	mode, err := parseMode(file.Mode)
+	if err != nil {
+		return fmt.Errorf("oci: file %s: %s", file.Path, err.Error())
+	}
+
+	var content io.ReadCloser
+	var size int64
+
+	if file.Size != 0 {
+		content, size, err = source(file)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     file.Path,
+		Mode:     mode,
+		Size:     size,
+		Uid:      ids.Uid(file.Owner),
+		Gid:      ids.Gid(file.Group),
+		Uname:    file.Owner,
+		Gname:    file.Group,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+
+	if content != nil {
+		_, err = io.CopyN(tw, content, size)
+	}
+
+	return err
+}
+
+func writeSymLink(tw *tar.Writer, symlink *action.SymLink, ids *IdMap, modTime time.Time) error {
+	return tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     symlink.Path,
+		Linkname: symlink.Target,
+		Mode:     0777,
+		Uid:      ids.Uid(symlink.Owner),
+		Gid:      ids.Gid(symlink.Group),
+		Uname:    symlink.Owner,
+		Gname:    symlink.Group,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+}
+
+func parseMode(mode string) (int64, error) {
+	parsed, err := strconv.ParseUint(mode, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %s", mode)
+	}
+
+	return int64(parsed), nil
+}
+
+// Tracks size and digest of written content
+type counter struct {
+	hash interface {
+		io.Writer
+		Sum([]byte) []byte
+	}
+	size int64
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	return c.hash.Write(p)
+}
+
+func (c *counter) Digest() string {
+	return "sha256:" + hex.EncodeToString(c.hash.Sum(nil))
+}
@@ -0,0 +1,217 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fezz-io/zps/action"
+)
+
+const (
+	AnnotationPkg      = "io.zps.pkg"
+	AnnotationManifest = "io.zps.manifest"
+	AnnotationCreated  = "org.opencontainers.image.created"
+	AnnotationRefName  = "org.opencontainers.image.ref.name"
+)
+
+type imageConfig struct {
+	Created      string         `json:"created"`
+	Architecture string         `json:"architecture"`
+	Os           string         `json:"os"`
+	Config       struct{}       `json:"config"`
+	RootFs       imageRootFs    `json:"rootfs"`
+	History      []imageHistory `json:"history"`
+}
+
+type imageRootFs struct {
+	Type    string   `json:"type"`
+	DiffIds []string `json:"diff_ids"`
+}
+
+type imageHistory struct {
+	Created   string `json:"created"`
+	CreatedBy string `json:"created_by"`
+}
+
+type imageManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []*Descriptor     `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type imageIndex struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	MediaType     string        `json:"mediaType"`
+	Manifests     []*Descriptor `json:"manifests"`
+}
+
+// Writes an OCI image layout directory with one layer per package
+type ImageLayout struct {
+	path string
+
+	platform *Platform
+	created  time.Time
+
+	layers []*Layer
+}
+
+func NewImageLayout(path string, os string, arch string) *ImageLayout {
+	return &ImageLayout{
+		path:     path,
+		platform: &Platform{Architecture: Arch(arch), Os: os},
+		created:  time.Now().UTC(),
+	}
+}
+
+// Add a package as a layer, the zps manifest is kept as a layer annotation
+func (l *ImageLayout) AddLayer(manifest *action.Manifest, source Source, ids *IdMap, modTime time.Time) (*Layer, error) {
+	err := os.MkdirAll(l.blobPath(), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(l.blobPath(), "layer")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	layer, err := WriteLayer(tmp, manifest, source, ids, modTime)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Rename(tmp.Name(), l.blob(layer.Digest))
+	if err != nil {
+		return nil, err
+	}
+
+	layer.Annotations = map[string]string{
+		AnnotationPkg:      manifest.Zpkg.Name + "@" + manifest.Zpkg.Version,
+		AnnotationManifest: manifest.ToJson(),
+	}
+
+	l.layers = append(l.layers, layer)
+
+	return layer, nil
+}
+
+// Write config, manifest, index and layout marker
+func (l *ImageLayout) Write(name string) error {
+	config := &imageConfig{
+		Created:      l.created.Format(time.RFC3339),
+		Architecture: l.platform.Architecture,
+		Os:           l.platform.Os,
+		RootFs:       imageRootFs{Type: "layers"},
+	}
+
+	manifest := &imageManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		Layers:        []*Descriptor{},
+		Annotations:   map[string]string{AnnotationCreated: config.Created},
+	}
+
+	for _, layer := range l.layers {
+		config.RootFs.DiffIds = append(config.RootFs.DiffIds, layer.DiffId)
+		config.History = append(config.History, imageHistory{
+			Created:   config.Created,
+			CreatedBy: "zps " + layer.Annotations[AnnotationPkg],
+		})
+
+		descriptor := layer.Descriptor
+		manifest.Layers = append(manifest.Layers, &descriptor)
+	}
+
+	configDesc, err := l.writeJson(config, MediaTypeConfig)
+	if err != nil {
+		return err
+	}
+	manifest.Config = *configDesc
+
+	manifestDesc, err := l.writeJson(manifest, MediaTypeManifest)
+	if err != nil {
+		return err
+	}
+	manifestDesc.Platform = l.platform
+	if name != "" {
+		manifestDesc.Annotations = map[string]string{AnnotationRefName: name}
+	}
+
+	index := &imageIndex{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeIndex,
+		Manifests:     []*Descriptor{manifestDesc},
+	}
+
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(l.path, "index.json"), content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(l.path, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+}
+
+func (l *ImageLayout) writeJson(value interface{}, mediaType string) (*Descriptor, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	err = os.MkdirAll(l.blobPath(), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(l.blob(digest), content, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}, nil
+}
+
+func (l *ImageLayout) blobPath() string {
+	return filepath.Join(l.path, "blobs", "sha256")
+}
+
+func (l *ImageLayout) blob(digest string) string {
+	return filepath.Join(l.blobPath(), strings.TrimPrefix(digest, "sha256:"))
+}
+
+// Translate zps arch names to OCI platform names
+func Arch(arch string) string {
+	switch arch {
+	case "x86_64":
+		return "amd64"
+	default:
+		return arch
+	}
+}
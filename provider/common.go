@@ -91,6 +91,8 @@ func DefaultFactory(emitter *emission.Emitter) *Factory {
 	factory.
 		Register("Dir", NewDirUnix).
 		Register("File", NewFileUnix).
+		Register("License", NewLicenseDefault).
+		Register("Provenance", NewProvenanceDefault).
 		Register("Requirement", NewRequirementDefault).
 		Register("SymLink", NewSymLinkUnix).
 		Register("Tag", NewTagDefault).
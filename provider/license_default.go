@@ -0,0 +1,39 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chuckpreslar/emission"
+
+	"github.com/fezz-io/zps/action"
+)
+
+type LicenseDefault struct {
+	*emission.Emitter
+	license *action.License
+
+	phaseMap map[string]string
+}
+
+func NewLicenseDefault(license action.Action, phaseMap map[string]string, emitter *emission.Emitter) Provider {
+	return &LicenseDefault{emitter, license.(*action.License), phaseMap}
+}
+
+func (l *LicenseDefault) Realize(ctx context.Context) error {
+	switch l.phaseMap[Phase(ctx)] {
+	default:
+		l.Emit("action.info", fmt.Sprintf("%s %s", l.license.Type(), l.license.Key()))
+		return nil
+	}
+}
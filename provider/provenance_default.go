@@ -0,0 +1,39 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chuckpreslar/emission"
+
+	"github.com/fezz-io/zps/action"
+)
+
+type ProvenanceDefault struct {
+	*emission.Emitter
+	provenance *action.Provenance
+
+	phaseMap map[string]string
+}
+
+func NewProvenanceDefault(provenance action.Action, phaseMap map[string]string, emitter *emission.Emitter) Provider {
+	return &ProvenanceDefault{emitter, provenance.(*action.Provenance), phaseMap}
+}
+
+func (p *ProvenanceDefault) Realize(ctx context.Context) error {
+	switch p.phaseMap[Phase(ctx)] {
+	default:
+		p.Emit("action.info", fmt.Sprintf("%s %s", p.provenance.Type(), p.provenance.Key()))
+		return nil
+	}
+}
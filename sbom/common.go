@@ -0,0 +1,158 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package sbom
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/fezz-io/zps/action"
+	"github.com/fezz-io/zps/zps"
+)
+
+const (
+	FormatSpdx      = "spdx"
+	FormatCycloneDx = "cyclonedx"
+
+	TagVcsUri = "zps.vcs.uri"
+
+	// Empty files carry no digest in the manifest
+	emptyDigest = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Generate an SBOM document in the given format for a set of manifests
+func Generate(format string, name string, manifests []*action.Manifest) ([]byte, error) {
+	pkgs, err := load(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatSpdx, "":
+		return spdx(name, pkgs)
+	case FormatCycloneDx:
+		return cycloneDx(name, pkgs)
+	default:
+		return nil, errors.New("sbom: unsupported format " + format)
+	}
+}
+
+type pkg struct {
+	*zps.Pkg
+
+	manifest *action.Manifest
+}
+
+func (p *pkg) tag(name string) string {
+	for _, tag := range p.manifest.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+
+	return ""
+}
+
+// Provenance takes precedence over the legacy vcs tag
+func (p *pkg) vcsUri() string {
+	if prov := p.Provenance(); prov != nil && prov.VcsUri != "" {
+		return prov.Source()
+	}
+
+	return p.tag(TagVcsUri)
+}
+
+func (p *pkg) files() []*action.File {
+	files := append([]*action.File{}, p.manifest.Files...)
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files
+}
+
+func (p *pkg) purl() string {
+	return fmt.Sprintf("pkg:generic/%s@%s?arch=%s&os=%s&publisher=%s",
+		url.PathEscape(p.Name()), url.PathEscape(p.Version().Semver.String()),
+		url.QueryEscape(p.Arch()), url.QueryEscape(p.Os()), url.QueryEscape(p.Publisher()))
+}
+
+// Sorted by name for stable output
+func load(manifests []*action.Manifest) ([]*pkg, error) {
+	var pkgs []*pkg
+
+	for _, manifest := range manifests {
+		zpkg, err := zps.NewPkgFromManifest(manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, &pkg{zpkg, manifest})
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Name() < pkgs[j].Name()
+	})
+
+	return pkgs, nil
+}
+
+// Find the package in the document satisfying a requirement
+func resolve(pkgs []*pkg, req *zps.Requirement) *pkg {
+	for _, candidate := range pkgs {
+		if candidate.Name() == req.Name && candidate.Satisfies(req) {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+var unsafeId = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func sanitizeId(id string) string {
+	return unsafeId.ReplaceAllString(id, "-")
+}
+
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func uuid() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Indented JSON without HTML escaping
+func marshal(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+
+	err := encoder.Encode(value)
+
+	return bytes.TrimSpace(buffer.Bytes()), err
+}
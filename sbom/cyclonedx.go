@@ -0,0 +1,158 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package sbom
+
+type cdxBom struct {
+	BomFormat    string           `json:"bomFormat"`
+	SpecVersion  string           `json:"specVersion"`
+	SerialNumber string           `json:"serialNumber"`
+	Version      int              `json:"version"`
+	Metadata     cdxMetadata      `json:"metadata"`
+	Components   []*cdxComponent  `json:"components"`
+	Dependencies []*cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp"`
+	Tools     []*cdxTool    `json:"tools"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxTool struct {
+	Name string `json:"name"`
+}
+
+type cdxComponent struct {
+	Type        string          `json:"type"`
+	BomRef      string          `json:"bom-ref,omitempty"`
+	Name        string          `json:"name"`
+	Version     string          `json:"version,omitempty"`
+	Publisher   string          `json:"publisher,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Purl        string          `json:"purl,omitempty"`
+	Hashes      []*cdxHash      `json:"hashes,omitempty"`
+	Licenses    []*cdxLicense   `json:"licenses,omitempty"`
+	ExternalRef []*cdxReference `json:"externalReferences,omitempty"`
+	Properties  []*cdxProperty  `json:"properties,omitempty"`
+	Components  []*cdxComponent `json:"components,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	Expression string `json:"expression"`
+}
+
+type cdxReference struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// CycloneDX 1.4 JSON
+func cycloneDx(name string, pkgs []*pkg) ([]byte, error) {
+	bom := &cdxBom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + uuid(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: timestamp(),
+			Tools:     []*cdxTool{{Name: "zps"}},
+		},
+		Components:   []*cdxComponent{},
+		Dependencies: []*cdxDependency{},
+	}
+
+	// A single package describes itself, several make up an image
+	if len(pkgs) != 1 {
+		bom.Metadata.Component = &cdxComponent{Type: "container", Name: name}
+	}
+
+	for _, p := range pkgs {
+		component := &cdxComponent{
+			Type:        "application",
+			BomRef:      p.purl(),
+			Name:        p.Name(),
+			Version:     p.Version().String(),
+			Publisher:   p.Publisher(),
+			Description: p.Summary(),
+			Purl:        p.purl(),
+		}
+
+		if uri := p.vcsUri(); uri != "" {
+			component.ExternalRef = append(component.ExternalRef, &cdxReference{"vcs", uri})
+		}
+
+		if p.License() != "" {
+			component.Licenses = []*cdxLicense{{p.License()}}
+		}
+
+		for _, tag := range p.manifest.Tags {
+			component.Properties = append(component.Properties, &cdxProperty{tag.Name, tag.Value})
+		}
+
+		for _, sig := range p.manifest.Signatures {
+			component.Properties = append(component.Properties,
+				&cdxProperty{"zps.signature." + sig.FingerPrint, sig.Algo + ":" + sig.Value})
+		}
+
+		for _, file := range p.files() {
+			digest := file.Digest
+			if digest == "" {
+				digest = emptyDigest
+			}
+
+			component.Components = append(component.Components, &cdxComponent{
+				Type:   "file",
+				Name:   file.Path,
+				Hashes: []*cdxHash{{"SHA-256", digest}},
+			})
+		}
+
+		dependency := &cdxDependency{Ref: component.BomRef, DependsOn: []string{}}
+
+		for _, req := range p.Requirements() {
+			if req.Method != "depends" {
+				continue
+			}
+
+			if target := resolve(pkgs, req); target != nil {
+				dependency.DependsOn = append(dependency.DependsOn, target.purl())
+				continue
+			}
+
+			component.Properties = append(component.Properties, &cdxProperty{"zps.requirement", req.String()})
+		}
+
+		if len(pkgs) == 1 {
+			bom.Metadata.Component = component
+		} else {
+			bom.Components = append(bom.Components, component)
+		}
+
+		bom.Dependencies = append(bom.Dependencies, dependency)
+	}
+
+	return marshal(bom)
+}
@@ -0,0 +1,181 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package sbom
+
+import (
+	"fmt"
+)
+
+type spdxDocument struct {
+	SpdxVersion       string              `json:"spdxVersion"`
+	DataLicense       string              `json:"dataLicense"`
+	SpdxId            string              `json:"SPDXID"`
+	Name              string              `json:"name"`
+	DocumentNamespace string              `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo    `json:"creationInfo"`
+	Packages          []*spdxPackage      `json:"packages"`
+	Files             []*spdxFile         `json:"files,omitempty"`
+	Relationships     []*spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SpdxId           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	Supplier         string            `json:"supplier,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	LicenseDeclared  string            `json:"licenseDeclared,omitempty"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Summary          string            `json:"summary,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	ExternalRefs     []*spdxRef        `json:"externalRefs,omitempty"`
+	Annotations      []*spdxAnnotation `json:"annotations,omitempty"`
+}
+
+type spdxRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxAnnotation struct {
+	AnnotationDate string `json:"annotationDate"`
+	AnnotationType string `json:"annotationType"`
+	Annotator      string `json:"annotator"`
+	Comment        string `json:"comment"`
+}
+
+type spdxFile struct {
+	SpdxId    string          `json:"SPDXID"`
+	FileName  string          `json:"fileName"`
+	Checksums []*spdxChecksum `json:"checksums"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	SpdxElementId      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX 2.3 JSON
+func spdx(name string, pkgs []*pkg) ([]byte, error) {
+	created := timestamp()
+
+	doc := &spdxDocument{
+		SpdxVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SpdxId:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://zps.io/spdx/%s-%s", sanitizeId(name), uuid()),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{"Tool: zps"},
+		},
+	}
+
+	annotation := func(comment string) *spdxAnnotation {
+		return &spdxAnnotation{created, "OTHER", "Tool: zps", comment}
+	}
+
+	relate := func(element string, typ string, related string) {
+		doc.Relationships = append(doc.Relationships, &spdxRelationship{element, typ, related})
+	}
+
+	external := make(map[string]bool)
+
+	for _, p := range pkgs {
+		id := "SPDXRef-Package-" + sanitizeId(p.Name())
+
+		sp := &spdxPackage{
+			SpdxId:           id,
+			Name:             p.Name(),
+			VersionInfo:      p.Version().String(),
+			Supplier:         "Organization: " + p.Publisher(),
+			DownloadLocation: "NOASSERTION",
+			Summary:          p.Summary(),
+			Description:      p.Description(),
+			ExternalRefs:     []*spdxRef{{"PACKAGE-MANAGER", "purl", p.purl()}},
+		}
+
+		if uri := p.vcsUri(); uri != "" {
+			sp.DownloadLocation = uri
+		}
+
+		sp.LicenseDeclared = "NOASSERTION"
+		if p.License() != "" {
+			sp.LicenseDeclared = p.License()
+		}
+
+		for _, tag := range p.manifest.Tags {
+			sp.Annotations = append(sp.Annotations, annotation(fmt.Sprintf("tag %s=%s", tag.Name, tag.Value)))
+		}
+
+		for _, sig := range p.manifest.Signatures {
+			sp.Annotations = append(sp.Annotations, annotation(fmt.Sprintf("signature fingerprint=%s algo=%s value=%s", sig.FingerPrint, sig.Algo, sig.Value)))
+		}
+
+		doc.Packages = append(doc.Packages, sp)
+		relate("SPDXRef-DOCUMENT", "DESCRIBES", id)
+
+		// Sanitized paths can collide, files are numbered in path order instead
+		for index, file := range p.files() {
+			fileId := fmt.Sprintf("SPDXRef-File-%s-%d", sanitizeId(p.Name()), index)
+
+			sf := &spdxFile{SpdxId: fileId, FileName: "./" + file.Path}
+			if file.Digest != "" {
+				sf.Checksums = append(sf.Checksums, &spdxChecksum{"SHA256", file.Digest})
+			} else {
+				// Empty files carry no digest
+				sf.Checksums = append(sf.Checksums, &spdxChecksum{"SHA256", emptyDigest})
+			}
+
+			doc.Files = append(doc.Files, sf)
+			relate(id, "CONTAINS", fileId)
+		}
+
+		for _, req := range p.Requirements() {
+			if req.Method != "depends" {
+				continue
+			}
+
+			if target := resolve(pkgs, req); target != nil {
+				relate(id, "DEPENDS_ON", "SPDXRef-Package-"+sanitizeId(target.Name()))
+				continue
+			}
+
+			// Dependencies outside the document are described by name only
+			depId := "SPDXRef-Requirement-" + sanitizeId(req.Name)
+			if !external[depId] {
+				external[depId] = true
+				doc.Packages = append(doc.Packages, &spdxPackage{
+					SpdxId:           depId,
+					Name:             req.Name,
+					DownloadLocation: "NOASSERTION",
+					Annotations:      []*spdxAnnotation{annotation("requirement " + req.String())},
+				})
+			}
+
+			relate(id, "DEPENDS_ON", depId)
+		}
+	}
+
+	return marshal(doc)
+}
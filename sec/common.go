@@ -3,6 +3,9 @@ package sec
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -23,6 +26,14 @@ const (
 	DefaultDigestMethod = "sha256"
 )
 
+// Signature algorithms, RSA PKCS#1 v1.5 keeps its original identifier
+// so existing signatures continue to verify
+const (
+	AlgoRsaSha256       = "sha256"
+	AlgoEcdsaP256Sha256 = "ecdsa-p256-sha256"
+	AlgoEd25519         = "ed25519"
+)
+
 func SecurityCertMetaFromBytes(certPem *[]byte) (string, string, string, error) {
 	block, _ := pem.Decode(*certPem)
 	if block == nil {
@@ -43,33 +54,88 @@ func SecurityCertMetaFromBytes(certPem *[]byte) (string, string, string, error)
 	return cert.Subject.CommonName, cert.Subject.Organization[0], fingerprint, nil
 }
 
-func SecuritySignBytes(content *[]byte, certFingerprint string, key *rsa.PrivateKey, algo string) (*action.Signature, error) {
-	switch algo {
-	case "sha256":
-		digest := sha256.Sum256(*content)
+// Signature algorithm for a public key
+func SignatureAlgo(key crypto.PublicKey) (string, error) {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		return AlgoRsaSha256, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve == elliptic.P256() {
+			return AlgoEcdsaP256Sha256, nil
+		}
 
-		rng := rand.Reader
+		return "", errors.New("unsupported ecdsa curve " + pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return AlgoEd25519, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
 
-		signature, err := rsa.SignPKCS1v15(rng, key, crypto.SHA256, digest[:])
-		if err != nil {
-			return nil, err
-		}
+// Parses a PEM encoded PKCS#1, SEC 1 or PKCS#8 private key
+func ParsePrivateKey(keyPem []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, errors.New("failed to decode key")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
 
-		return &action.Signature{
-			FingerPrint: certFingerprint,
-			Algo:        "sha256",
-			Value:       hex.EncodeToString(signature),
-		}, nil
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
 
-	default:
-		return nil, errors.New("unsupported signature algorithm")
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// Signs content with the algorithm matching the key, digest must be sha256
+func SecuritySignBytes(content *[]byte, signer Signer, digestMethod string) (*action.Signature, error) {
+	if digestMethod != DefaultDigestMethod {
+		return nil, errors.New("unsupported digest method " + digestMethod)
+	}
+
+	algo, err := SignatureAlgo(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+
+	switch algo {
+	case AlgoRsaSha256, AlgoEcdsaP256Sha256:
+		digest := sha256.Sum256(*content)
+
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case AlgoEd25519:
+		signature, err = signer.Sign(rand.Reader, *content, crypto.Hash(0))
+	}
+
+	if err != nil {
+		return nil, err
 	}
+
+	return &action.Signature{
+		FingerPrint: signer.Fingerprint(),
+		Algo:        algo,
+		Value:       hex.EncodeToString(signature),
+	}, nil
 }
 
-func SecuritySignFile(filePath string, sigPath string, fingerprint string, key *rsa.PrivateKey, algo string) error {
+func SecuritySignFile(filePath string, sigPath string, signer Signer, digestMethod string) error {
 	cfgBytes, err := ioutil.ReadFile(filePath)
 
-	sig, err := SecuritySignBytes(&cfgBytes, fingerprint, key, algo)
+	sig, err := SecuritySignBytes(&cfgBytes, signer, digestMethod)
 	if err != nil {
 		return err
 	}
@@ -83,14 +149,34 @@ func SecuritySignFile(filePath string, sigPath string, fingerprint string, key *
 }
 
 func SecurityValidateBytes(content *[]byte, cert *x509.Certificate, signature action.Signature) error {
+	sig, err := hex.DecodeString(signature.Value)
+	if err != nil {
+		return err
+	}
+
+	algo, err := SignatureAlgo(cert.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	if algo != signature.Algo {
+		return fmt.Errorf("signature algorithm %s does not match %s certificate", signature.Algo, algo)
+	}
+
 	switch signature.Algo {
-	case "sha256":
-		sig, _ := hex.DecodeString(signature.Value)
+	case AlgoRsaSha256:
+		hash := sha256.Sum256(*content)
+
+		return rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, hash[:], sig)
+	case AlgoEcdsaP256Sha256:
 		hash := sha256.Sum256(*content)
 
-		err := rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, hash[:], sig)
-		if err != nil {
-			return err
+		if !ecdsa.VerifyASN1(cert.PublicKey.(*ecdsa.PublicKey), hash[:], sig) {
+			return errors.New("ecdsa: verification error")
+		}
+	case AlgoEd25519:
+		if !ed25519.Verify(cert.PublicKey.(ed25519.PublicKey), *content, sig) {
+			return errors.New("ed25519: verification error")
 		}
 	default:
 		return errors.New("unsupported signature algorithm")
@@ -99,8 +185,45 @@ func SecurityValidateBytes(content *[]byte, cert *x509.Certificate, signature ac
 	return nil
 }
 
+// Parses a PEM or DER encoded certificate revocation list
+func ParseCrl(content []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, errors.New("unexpected pem block " + block.Type)
+		}
+
+		content = block.Bytes
+	}
+
+	return x509.ParseRevocationList(content)
+}
+
+func CrlRevokes(crl *x509.RevocationList, cert *x509.Certificate) bool {
+	if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+		return false
+	}
+
+	for _, revoked := range crl.RevokedCertificateEntries {
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 func SecurityValidateKeyPair(certPath string, keyPath string) error {
-	_, err := tls.LoadX509KeyPair(certPath, keyPath)
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return errors.New("unsupported private key")
+	}
+
+	_, err = SignatureAlgo(key.Public())
 
 	return err
 }
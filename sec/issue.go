@@ -0,0 +1,219 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package sec
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Certificate types, matching the pki store
+const (
+	CertCA           = "ca"
+	CertIntermediate = "intermediate"
+	CertUser         = "user"
+)
+
+// Subject and lifetime of a certificate to issue
+type CertRequest struct {
+	Type      string
+	Subject   string
+	Publisher string
+	Days      int
+}
+
+// Generates a private key for a signature algorithm, RSA keys are 3072 bits
+func GenerateKey(algo string) (crypto.Signer, error) {
+	switch algo {
+	case AlgoRsaSha256, "rsa":
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case AlgoEcdsaP256Sha256, "ecdsa":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgoEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, errors.New("unsupported key algorithm " + algo)
+	}
+}
+
+// PEM encodes a private key as PKCS#8
+func MarshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Issues a PEM certificate for pub, self signed with parentKey when parent is nil.
+//
+// No extended key usages are set, chains are verified with the default
+// options which would otherwise reject code signing certificates
+func IssueCert(req *CertRequest, pub crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer) ([]byte, error) {
+	if req.Publisher == "" {
+		return nil, errors.New("certificate publisher required")
+	}
+
+	if parentKey == nil {
+		return nil, errors.New("issuer key required")
+	}
+
+	_, err := SignatureAlgo(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   req.Subject,
+			Organization: []string{req.Publisher},
+		},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.AddDate(0, 0, req.Days),
+		BasicConstraintsValid: true,
+	}
+
+	switch req.Type {
+	case CertCA:
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	case CertIntermediate:
+		template.IsCA = true
+		template.MaxPathLenZero = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	case CertUser:
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+	default:
+		return nil, errors.New("unknown certificate type " + req.Type)
+	}
+
+	if parent == nil {
+		if req.Type != CertCA {
+			return nil, errors.New("only ca certificates may be self signed")
+		}
+
+		parent = template
+	} else {
+		if !parent.IsCA {
+			return nil, errors.New("issuer " + parent.Subject.CommonName + " is not a ca")
+		}
+
+		if parent.NotAfter.Before(template.NotAfter) {
+			template.NotAfter = parent.NotAfter
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// Creates a PEM certificate signing request for a publisher signing key
+func CreateCsr(subject string, publisher string, key crypto.Signer) ([]byte, error) {
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   subject,
+			Organization: []string{publisher},
+		},
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// Parses and checks the signature of a PEM certificate signing request
+func ParseCsr(content []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("failed to parse certificate request pem")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, errors.New("invalid certificate request signature: " + err.Error())
+	}
+
+	if len(csr.Subject.Organization) == 0 {
+		return nil, errors.New("invalid certificate request organization")
+	}
+
+	return csr, nil
+}
+
+func ParseCert(certPem []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, errors.New("failed to parse certificate pem")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.New("failed to parse certificate: " + err.Error())
+	}
+
+	return cert, nil
+}
+
+// Loads a PEM certificate and the private key matching it
+func LoadIssuer(certPem []byte, keyPem []byte) (*x509.Certificate, crypto.Signer, error) {
+	cert, err := ParseCert(certPem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ParsePrivateKey(keyPem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if string(pub) != string(cert.RawSubjectPublicKeyInfo) {
+		return nil, nil, errors.New("private key does not match certificate " + cert.Subject.CommonName)
+	}
+
+	return cert, key, nil
+}
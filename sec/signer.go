@@ -0,0 +1,276 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package sec
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Signing key for a publisher certificate, the private key may live outside zps
+type Signer interface {
+	crypto.Signer
+
+	Fingerprint() string
+	Subject() string
+}
+
+type KeySigner struct {
+	crypto.Signer
+
+	fingerprint string
+	subject     string
+}
+
+// Signer for a private key held by zps, such as a keystore keypair
+func NewKeySigner(fingerprint string, subject string, key crypto.Signer) *KeySigner {
+	return &KeySigner{key, fingerprint, subject}
+}
+
+func (k *KeySigner) Fingerprint() string {
+	return k.fingerprint
+}
+
+func (k *KeySigner) Subject() string {
+	return k.subject
+}
+
+// Public half of signers backed by a certificate
+type certSigner struct {
+	cert        *x509.Certificate
+	fingerprint string
+	algo        string
+}
+
+func newCertSigner(certPem []byte) (*certSigner, error) {
+	block, _ := pem.Decode(certPem)
+	if block == nil {
+		return nil, errors.New("failed to parse certificate pem")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.New("failed to parse certificate: " + err.Error())
+	}
+
+	algo, err := SignatureAlgo(cert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certSigner{cert, SpkiFingerprint(cert).String(), algo}, nil
+}
+
+func (c *certSigner) Public() crypto.PublicKey {
+	return c.cert.PublicKey
+}
+
+func (c *certSigner) Fingerprint() string {
+	return c.fingerprint
+}
+
+func (c *certSigner) Subject() string {
+	return c.cert.Subject.CommonName
+}
+
+// Ed25519 signs the message, other algorithms a sha256 digest
+func (c *certSigner) checkOpts(opts crypto.SignerOpts) error {
+	hash := opts.HashFunc()
+
+	if c.algo == AlgoEd25519 && hash != crypto.Hash(0) {
+		return errors.New("ed25519 signs unhashed content")
+	}
+
+	if c.algo != AlgoEd25519 && hash != crypto.SHA256 {
+		return errors.New("unsupported hash for " + c.algo)
+	}
+
+	return nil
+}
+
+// Delegates signing to a command, one invocation per signature
+//
+// The command reads a JSON request from stdin:
+//
+//	{"fingerprint": "...", "algo": "sha256|ecdsa-p256-sha256|ed25519", "data": "<base64>"}
+//
+// where data is the sha256 digest, or the message for ed25519, and writes
+//
+//	{"signature": "<base64>"} or {"error": "..."}
+//
+// to stdout. ECDSA signatures are ASN.1 DER encoded.
+type ExternalSigner struct {
+	*certSigner
+
+	command []string
+}
+
+type externalRequest struct {
+	Fingerprint string `json:"fingerprint"`
+	Algo        string `json:"algo"`
+	Data        string `json:"data"`
+}
+
+type externalResponse struct {
+	Signature string `json:"signature"`
+	Error     string `json:"error"`
+}
+
+func NewExternalSigner(certPem []byte, command []string) (*ExternalSigner, error) {
+	if len(command) == 0 {
+		return nil, errors.New("external signer requires a command")
+	}
+
+	cs, err := newCertSigner(certPem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExternalSigner{cs, command}, nil
+}
+
+func (e *ExternalSigner) Sign(rand io.Reader, data []byte, opts crypto.SignerOpts) ([]byte, error) {
+	err := e.checkOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := json.Marshal(&externalRequest{e.fingerprint, e.algo, base64.StdEncoding.EncodeToString(data)})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(e.command[0], e.command[1:]...)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("external signer: %s %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	response := &externalResponse{}
+
+	err = json.Unmarshal(stdout.Bytes(), response)
+	if err != nil {
+		return nil, errors.New("external signer: invalid response: " + err.Error())
+	}
+
+	if response.Error != "" {
+		return nil, errors.New("external signer: " + response.Error)
+	}
+
+	return base64.StdEncoding.DecodeString(response.Signature)
+}
+
+// Signs with a key on a PKCS#11 token through OpenSC pkcs11-tool
+type Pkcs11Signer struct {
+	*certSigner
+
+	Tool   string
+	Module string
+	KeyId  string
+	Slot   string
+	Pin    string
+}
+
+// Variable pkcs11-tool reads the token PIN from
+const pkcs11PinEnv = "ZPS_PKCS11_PIN"
+
+// DER DigestInfo prefix for sha256, RSA-PKCS signs the prefixed digest
+var sha256DigestInfo = []byte{
+	0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+	0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20,
+}
+
+func NewPkcs11Signer(certPem []byte, module string, keyId string) (*Pkcs11Signer, error) {
+	if module == "" || keyId == "" {
+		return nil, errors.New("pkcs11 signer requires a module and key_id")
+	}
+
+	cs, err := newCertSigner(certPem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pkcs11Signer{certSigner: cs, Tool: "pkcs11-tool", Module: module, KeyId: keyId}, nil
+}
+
+func (p *Pkcs11Signer) Sign(rand io.Reader, data []byte, opts crypto.SignerOpts) ([]byte, error) {
+	err := p.checkOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--module", p.Module, "--sign", "--id", p.KeyId}
+
+	switch p.algo {
+	case AlgoRsaSha256:
+		data = append(append([]byte{}, sha256DigestInfo...), data...)
+		args = append(args, "--mechanism", "RSA-PKCS")
+	case AlgoEcdsaP256Sha256:
+		args = append(args, "--mechanism", "ECDSA", "--signature-format", "openssl")
+	case AlgoEd25519:
+		args = append(args, "--mechanism", "EDDSA")
+	}
+
+	if p.Slot != "" {
+		args = append(args, "--slot", p.Slot)
+	}
+
+	// The PIN is passed through the environment, argv is visible to other users
+	env := os.Environ()
+	if p.Pin != "" {
+		args = append(args, "--login", "--pin", "env:"+pkcs11PinEnv)
+		env = append(env, pkcs11PinEnv+"="+p.Pin)
+	}
+
+	input, err := ioutil.TempFile("", "pkcs11-input")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input.Name())
+
+	_, err = input.Write(data)
+	input.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	output := input.Name() + ".sig"
+	defer os.Remove(output)
+
+	args = append(args, "--input-file", input.Name(), "--output-file", output)
+
+	cmd := exec.Command(p.Tool, args...)
+	cmd.Env = env
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 signer: %s %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return ioutil.ReadFile(output)
+}
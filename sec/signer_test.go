@@ -0,0 +1,164 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package sec
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+// Self signed certificate and a signer for a freshly generated key, the
+// key passes through PEM to cover the keystore path
+func testSigner(t *testing.T, algo string) (*x509.Certificate, Signer) {
+	key, err := GenerateKey(algo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPem, err := MarshalPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err = ParsePrivateKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem, err := IssueCert(&CertRequest{Type: CertCA, Subject: "test", Publisher: "fezz", Days: 1}, key.Public(), nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := ParseCert(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, NewKeySigner(SpkiFingerprint(cert).String(), cert.Subject.CommonName, key)
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, algo := range []string{AlgoRsaSha256, AlgoEcdsaP256Sha256, AlgoEd25519} {
+		t.Run(algo, func(t *testing.T) {
+			cert, signer := testSigner(t, algo)
+			content := []byte("metadata")
+
+			signature, err := SecuritySignBytes(&content, signer, DefaultDigestMethod)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if signature.Algo != algo || signature.FingerPrint != signer.Fingerprint() {
+				t.Errorf("unexpected signature %s %s", signature.Algo, signature.FingerPrint)
+			}
+
+			err = SecurityValidateBytes(&content, cert, *signature)
+			if err != nil {
+				t.Errorf("expected signature to verify: %s", err)
+			}
+
+			tampered := []byte("metadatA")
+			if SecurityValidateBytes(&tampered, cert, *signature) == nil {
+				t.Error("expected tampered content to be rejected")
+			}
+
+			corrupt := *signature
+			corrupt.Value = strings.Repeat("00", len(signature.Value)/2)
+			if SecurityValidateBytes(&content, cert, corrupt) == nil {
+				t.Error("expected corrupt signature to be rejected")
+			}
+		})
+	}
+}
+
+func TestSignVerifyMismatch(t *testing.T) {
+	ecdsaCert, ecdsaSigner := testSigner(t, AlgoEcdsaP256Sha256)
+	ed25519Cert, ed25519Signer := testSigner(t, AlgoEd25519)
+
+	content := []byte("metadata")
+
+	ecdsaSig, err := SecuritySignBytes(&content, ecdsaSigner, DefaultDigestMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed25519Sig, err := SecuritySignBytes(&content, ed25519Signer, DefaultDigestMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relabeled := *ed25519Sig
+	relabeled.Algo = AlgoEcdsaP256Sha256
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		sig  string
+		algo string
+		err  string
+	}{
+		{"ecdsa signature ed25519 cert", ed25519Cert, ecdsaSig.Value, ecdsaSig.Algo, "signature algorithm ecdsa-p256-sha256 does not match ed25519 certificate"},
+		{"ed25519 signature ecdsa cert", ecdsaCert, ed25519Sig.Value, ed25519Sig.Algo, "signature algorithm ed25519 does not match ecdsa-p256-sha256 certificate"},
+		{"relabeled ed25519 signature", ecdsaCert, relabeled.Value, relabeled.Algo, "ecdsa: verification error"},
+		{"rsa label", ecdsaCert, ecdsaSig.Value, AlgoRsaSha256, "signature algorithm sha256 does not match ecdsa-p256-sha256 certificate"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signature := *ecdsaSig
+			signature.Value = test.sig
+			signature.Algo = test.algo
+
+			err := SecurityValidateBytes(&content, test.cert, signature)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	if _, err := SecuritySignBytes(&content, ecdsaSigner, "sha1"); err == nil {
+		t.Error("expected unsupported digest method to be rejected")
+	}
+}
+
+func TestCertSignerOpts(t *testing.T) {
+	tests := []struct {
+		algo string
+		hash crypto.Hash
+		ok   bool
+	}{
+		{AlgoEcdsaP256Sha256, crypto.SHA256, true},
+		{AlgoEcdsaP256Sha256, crypto.Hash(0), false},
+		{AlgoEcdsaP256Sha256, crypto.SHA512, false},
+		{AlgoEd25519, crypto.Hash(0), true},
+		{AlgoEd25519, crypto.SHA256, false},
+		{AlgoRsaSha256, crypto.SHA256, true},
+		{AlgoRsaSha256, crypto.Hash(0), false},
+	}
+
+	for _, test := range tests {
+		cert, _ := testSigner(t, test.algo)
+
+		signer, err := newCertSigner(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = signer.checkOpts(test.hash)
+		if (err == nil) != test.ok {
+			t.Errorf("%s %v: expected ok %v, got %v", test.algo, test.hash, test.ok, err)
+		}
+	}
+}
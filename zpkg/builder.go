@@ -11,6 +11,7 @@
 package zpkg
 
 import (
+	"errors"
 	"os"
 	"path"
 	"path/filepath"
@@ -40,7 +41,14 @@ type Builder struct {
 
 	version uint8
 
+	zpkgFile *ZpkgFile
+	matrix   *MatrixEntry
+	vars     map[string]string
+
+	sonameDepends string
+
 	manifest *action.Manifest
+	siblings []*action.Manifest
 
 	filename string
 
@@ -76,6 +84,17 @@ func (b *Builder) TargetPath(tp string) *Builder {
 	return b
 }
 
+func (b *Builder) Vars(vars map[string]string) *Builder {
+	b.vars = vars
+	return b
+}
+
+// Depends on needed sonames: add, suggest or off
+func (b *Builder) SonameDepends(mode string) *Builder {
+	b.sonameDepends = mode
+	return b
+}
+
 func (b *Builder) Restrict(r bool) *Builder {
 	b.options.Restrict = r
 	return b
@@ -143,16 +162,23 @@ func (b *Builder) setPaths() error {
 
 // This isn't efficient, I don't expect these files to be terribly large however
 func (b *Builder) loadZpkgfile() error {
-	zpkgFile, err := (&ZpkgFile{}).Load(b.zpfPath)
-	if err != nil {
-		return err
+	var err error
+
+	if b.zpkgFile == nil {
+		b.zpkgFile, err = (&ZpkgFile{Vars: b.vars}).Load(b.zpfPath)
+		if err != nil {
+			return err
+		}
 	}
 
-	b.manifest, err = zpkgFile.Eval()
+	manifests, err := b.zpkgFile.Eval(b.matrix)
 	if err != nil {
 		return err
 	}
 
+	b.manifest = manifests[0]
+	b.siblings = manifests[1:]
+
 	return err
 }
 
@@ -164,22 +190,45 @@ func (b *Builder) processOptions() error {
 
 // Add FS objects
 func (b *Builder) resolve() error {
+	globDirs, globFiles := b.manifest.Globs()
+
+	ignore := NewIgnore().AddExcludes(b.manifest.Excludes...)
+	err := ignore.Load(filepath.Join(filepath.Dir(b.zpfPath), DefaultIgnorePath))
+	if err != nil {
+		return err
+	}
+
 	// If restrict is set don't walk the target path
 	// this will result in only defined file system objects being added
-	// to the package
-	if b.options.Restrict == true {
+	// to the package, glob actions still expand against the target path
+	if b.options.Restrict == true && len(globDirs) == 0 && len(globFiles) == 0 {
 		return nil
 	}
 
-	err := filepath.Walk(b.options.TargetPath, func(path string, f os.FileInfo, err error) error {
+	// Walk is lexical so the resulting manifest is deterministic
+	err = filepath.Walk(b.options.TargetPath, func(path string, f os.FileInfo, err error) error {
 		objectPath := strings.Replace(path, b.options.TargetPath+string(os.PathSeparator), "", 1)
 
 		if objectPath != b.options.TargetPath {
+			if ignore.Match(objectPath, f.IsDir()) {
+				if f.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
 			if f.IsDir() {
 				var dir = action.NewDir()
 				dir.Path = objectPath
 
 				if !b.manifest.Exists(dir) {
+					if glob := matchDir(globDirs, objectPath); glob != nil {
+						dir = glob
+					} else if b.options.Restrict {
+						return nil
+					}
+
 					b.manifest.Add(dir)
 				}
 			}
@@ -189,11 +238,17 @@ func (b *Builder) resolve() error {
 				file.Path = objectPath
 
 				if !b.manifest.Exists(file) {
+					if glob := matchFile(globFiles, objectPath); glob != nil {
+						file = glob
+					} else if b.options.Restrict {
+						return nil
+					}
+
 					b.manifest.Add(file)
 				}
 			}
 
-			if f.Mode()&os.ModeSymlink == os.ModeSymlink {
+			if f.Mode()&os.ModeSymlink == os.ModeSymlink && !b.options.Restrict {
 				var symlink = action.NewSymLink()
 				symlink.Path = objectPath
 
@@ -213,6 +268,153 @@ func (b *Builder) resolve() error {
 	return b.manifest.Validate()
 }
 
+// Dir for a path with the attributes of all matching glob Dirs,
+// later blocks override earlier ones
+func matchDir(globs []*action.Dir, objectPath string) *action.Dir {
+	var dir *action.Dir
+
+	for _, glob := range globs {
+		if !action.MatchGlob(glob.Path, objectPath) {
+			continue
+		}
+
+		if dir == nil {
+			dir = action.NewDir()
+			dir.Path = objectPath
+		}
+
+		dir.Owner, dir.Group, dir.Mode = mergeAttr(dir.Owner, glob.Owner), mergeAttr(dir.Group, glob.Group), mergeAttr(dir.Mode, glob.Mode)
+	}
+
+	return dir
+}
+
+// File for a path with the attributes of all matching glob Files,
+// later blocks override earlier ones
+func matchFile(globs []*action.File, objectPath string) *action.File {
+	var file *action.File
+
+	for _, glob := range globs {
+		if !action.MatchGlob(glob.Path, objectPath) {
+			continue
+		}
+
+		if file == nil {
+			file = action.NewFile()
+			file.Path = objectPath
+		}
+
+		file.Owner, file.Group, file.Mode = mergeAttr(file.Owner, glob.Owner), mergeAttr(file.Group, glob.Group), mergeAttr(file.Mode, glob.Mode)
+	}
+
+	return file
+}
+
+func mergeAttr(current string, override string) string {
+	if override != "" {
+		return override
+	}
+
+	return current
+}
+
+// Move FS objects claimed by split packages out of the primary manifest,
+// siblings inherit unset zpkg fields, tags, license and provenance
+func (b *Builder) split() error {
+	if len(b.siblings) == 0 {
+		return nil
+	}
+
+	primary := b.manifest.Zpkg
+	names := map[string]bool{primary.Name: true}
+
+	for _, sibling := range b.siblings {
+		zp := sibling.Zpkg
+
+		if names[zp.Name] {
+			return errors.New("zpkgfile: duplicate Zpkg " + zp.Name)
+		}
+		names[zp.Name] = true
+
+		if len(zp.Paths) == 0 {
+			return errors.New("zpkgfile: Zpkg " + zp.Name + " requires paths")
+		}
+
+		zp.Version = primary.Version
+		zp.Publisher = mergeAttr(primary.Publisher, zp.Publisher)
+		zp.Arch = mergeAttr(primary.Arch, zp.Arch)
+		zp.Os = mergeAttr(primary.Os, zp.Os)
+		zp.Summary = mergeAttr(primary.Summary, zp.Summary)
+		zp.Description = mergeAttr(primary.Description, zp.Description)
+
+		for _, tag := range b.manifest.Tags {
+			sibling.Add(tag)
+		}
+
+		sibling.License = b.manifest.License
+		if b.manifest.Provenance != nil {
+			provenance := *b.manifest.Provenance
+			sibling.Provenance = &provenance
+		}
+	}
+
+	var claimed []action.Action
+	dirs := make(map[string]*action.Dir)
+
+	for _, act := range b.manifest.Section("Dir", "File", "SymLink") {
+		if act.Type() == "Dir" {
+			dirs[act.Key()] = act.(*action.Dir)
+		}
+
+		if sibling := b.claim(act.Key()); sibling != nil {
+			sibling.Add(act)
+			claimed = append(claimed, act)
+		}
+	}
+
+	b.manifest.Remove(claimed...)
+
+	// Siblings share the parent dirs of what they claim
+	for _, sibling := range b.siblings {
+		for _, act := range sibling.Section("Dir", "File", "SymLink") {
+			for parent := path.Dir(act.Key()); parent != "." && parent != "/"; parent = path.Dir(parent) {
+				if dir, ok := dirs[parent]; ok {
+					shared := *dir
+					if !sibling.Exists(&shared) {
+						sibling.Add(&shared)
+					}
+				}
+			}
+		}
+	}
+
+	for _, manifest := range b.manifests() {
+		err := manifest.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// First split package claiming a path
+func (b *Builder) claim(objectPath string) *action.Manifest {
+	for _, sibling := range b.siblings {
+		for _, glob := range sibling.Zpkg.Paths {
+			if action.MatchGlob(glob, objectPath) {
+				return sibling
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *Builder) manifests() []*action.Manifest {
+	return append([]*action.Manifest{b.manifest}, b.siblings...)
+}
+
 // Set file name and zpkg timestamp
 func (b *Builder) set() error {
 	pkg, err := zps.NewPkgFromManifest(b.manifest)
@@ -223,23 +425,44 @@ func (b *Builder) set() error {
 	pkg.Version().Timestamp = time.Now().UTC()
 	b.manifest.Zpkg.Version = pkg.Version().String()
 
+	if b.manifest.Provenance != nil && b.manifest.Provenance.BuildTime == "" {
+		b.manifest.Provenance.BuildTime = pkg.Version().Timestamp.Format(time.RFC3339)
+	}
+
 	b.filename = pkg.FileName()
 
+	// Split packages share the version and depend on the exact primary build
+	for _, sibling := range b.siblings {
+		sibling.Zpkg.Version = b.manifest.Zpkg.Version
+
+		if sibling.Provenance != nil {
+			sibling.Provenance.BuildTime = b.manifest.Provenance.BuildTime
+		}
+
+		req := action.NewRequirement()
+		req.Name = b.manifest.Zpkg.Name
+		req.Method = "depends"
+		req.Operation = "EXQ"
+		req.Version = b.manifest.Zpkg.Version
+
+		sibling.Add(req)
+	}
+
 	return nil
 }
 
 // Completes manifest, builds payload
-func (b *Builder) realize() error {
+func (b *Builder) realize(manifest *action.Manifest, pw *payload.Writer) error {
 	var err error
 
 	// Setup context
 	ctx := context.WithValue(context.Background(), "options", b.options)
 	ctx = context.WithValue(ctx, "phase", phase.PACKAGE)
-	ctx = context.WithValue(ctx, "payload", b.payload)
+	ctx = context.WithValue(ctx, "payload", pw)
 
 	factory := provider.DefaultFactory(b.Emitter)
 
-	for _, act := range b.manifest.Actions() {
+	for _, act := range manifest.Actions() {
 		err = factory.Get(act).Realize(ctx)
 		if err != nil {
 			return err
@@ -250,43 +473,153 @@ func (b *Builder) realize() error {
 }
 
 func (b *Builder) Build() (string, *action.Manifest, error) {
-	err := b.setPaths()
+	filenames, manifests, err := b.BuildAll()
 	if err != nil {
 		return "", nil, err
 	}
 
-	err = b.loadZpkgfile()
+	return filenames[0], manifests[0], err
+}
+
+// Builds the primary package and any split packages for every matrix combination
+func (b *Builder) BuildAll() ([]string, []*action.Manifest, error) {
+	err := b.setPaths()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
+	}
+
+	b.zpkgFile, err = (&ZpkgFile{Vars: b.vars}).Load(b.zpfPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matrix, err := b.zpkgFile.Matrix()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(matrix) == 0 {
+		return b.build()
+	}
+
+	var filenames []string
+	var manifests []*action.Manifest
+
+	for _, entry := range matrix {
+		builder := b.combination(entry)
+
+		b.Emit("builder.matrix", entry.String())
+
+		names, built, err := builder.build()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, name := range names {
+			for _, prev := range filenames {
+				if name == prev {
+					return nil, nil, errors.New("zpkgfile: matrix builds " + name + " more than once")
+				}
+			}
+		}
+
+		filenames = append(filenames, names...)
+		manifests = append(manifests, built...)
+	}
+
+	return filenames, manifests, nil
+}
+
+// Builder for a single matrix combination, relative target paths
+// are resolved against the Zpkgfile directory
+func (b *Builder) combination(entry *MatrixEntry) *Builder {
+	builder := NewBuilder()
+	builder.Emitter = b.Emitter
+
+	options := *b.options
+	builder.options = &options
+
+	if entry.TargetPath != "" {
+		builder.options.TargetPath = entry.TargetPath
+
+		if !filepath.IsAbs(entry.TargetPath) {
+			builder.options.TargetPath = filepath.Join(filepath.Dir(b.zpfPath), entry.TargetPath)
+		}
+	}
+
+	builder.ZpfPath(b.zpfPath).WorkPath(b.workPath).OutputPath(b.outputPath).Version(b.version)
+
+	builder.zpkgFile = b.zpkgFile
+	builder.matrix = entry
+	builder.sonameDepends = b.sonameDepends
+
+	return builder
+}
+
+func (b *Builder) build() ([]string, []*action.Manifest, error) {
+	err := b.loadZpkgfile()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	err = b.processOptions()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
 	err = b.resolve()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	err = b.set()
+	err = b.split()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	err = b.realize()
+	err = b.sonames()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	// Write the file
-	err = b.writer.Write(b.filename, b.header, b.manifest, b.payload)
+	err = b.set()
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	b.Emit("builder.complete", b.filename)
+	var filenames []string
+
+	for index, manifest := range b.manifests() {
+		filename := b.filename
+		header := b.header
+		pw := b.payload
+
+		if index > 0 {
+			pkg, err := zps.NewPkgFromManifest(manifest)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			filename = pkg.FileName()
+			header = NewHeader(b.version, Compression)
+			pw = payload.NewWriter(b.workPath, 0)
+		}
+
+		err = b.realize(manifest, pw)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Write the file
+		err = b.writer.Write(filename, header, manifest, pw)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		b.Emit("builder.complete", filename)
+
+		filenames = append(filenames, filename)
+	}
 
-	return b.filename, b.manifest, err
+	return filenames, b.manifests(), err
 }
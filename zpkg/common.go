@@ -20,8 +20,9 @@ const (
 	Version     uint8  = 0
 	Compression uint8  = 0
 
-	DefaultZpfPath   = "Zpkgfile"
-	DefaultTargetDir = "proto"
+	DefaultZpfPath    = "Zpkgfile"
+	DefaultTargetDir  = "proto"
+	DefaultIgnorePath = ".zpkgignore"
 )
 
 type Header struct {
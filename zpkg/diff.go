@@ -0,0 +1,232 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package zpkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fezz-io/zps/action"
+)
+
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+type Change struct {
+	Type    string   `json:"type"`
+	Key     string   `json:"key"`
+	Status  string   `json:"status"`
+	Details []string `json:"details,omitempty"`
+
+	// Unified diff of text file content, when requested
+	Content string `json:"content,omitempty"`
+
+	from action.Action
+	to   action.Action
+}
+
+// Compared actions, nil when added or removed
+func (c *Change) Actions() (action.Action, action.Action) {
+	return c.from, c.to
+}
+
+type Diff struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Changes []*Change `json:"changes"`
+}
+
+// Compares two manifests, changes are ordered by section then key
+func NewDiff(from *action.Manifest, to *action.Manifest) *Diff {
+	diff := &Diff{From: manifestId(from), To: manifestId(to), Changes: []*Change{}}
+
+	diff.compare(header(from), header(to))
+	diff.compare(section(from, "Dir", "File", "SymLink"), section(to, "Dir", "File", "SymLink"))
+	diff.compare(section(from, "Template", "Service"), section(to, "Template", "Service"))
+	diff.compare(section(from, "Requirement"), section(to, "Requirement"))
+	diff.compare(section(from, "Tag"), section(to, "Tag"))
+	diff.compare(section(from, "Signature"), section(to, "Signature"))
+
+	return diff
+}
+
+// Files present in both manifests with differing content
+func (d *Diff) ContentChanges() []*Change {
+	var changes []*Change
+
+	for _, change := range d.Changes {
+		if change.Type != "File" || change.Status != DiffChanged {
+			continue
+		}
+
+		for _, detail := range change.Details {
+			if detail == "digest" {
+				changes = append(changes, change)
+				break
+			}
+		}
+	}
+
+	return changes
+}
+
+func (d *Diff) ToJson() string {
+	out, _ := json.MarshalIndent(d, "", "    ")
+
+	return string(out)
+}
+
+func (d *Diff) compare(from map[string]action.Action, to map[string]action.Action) {
+	keys := make(map[string]bool)
+	for key := range from {
+		keys[key] = true
+	}
+	for key := range to {
+		keys[key] = true
+	}
+
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		a, inFrom := from[key]
+		b, inTo := to[key]
+
+		switch {
+		case !inFrom:
+			d.Changes = append(d.Changes, &Change{Type: b.Type(), Key: key, Status: DiffAdded, to: b})
+		case !inTo:
+			d.Changes = append(d.Changes, &Change{Type: a.Type(), Key: key, Status: DiffRemoved, from: a})
+		default:
+			details := compareActions(a, b)
+			if len(details) > 0 {
+				d.Changes = append(d.Changes, &Change{Type: b.Type(), Key: key, Status: DiffChanged, Details: details, from: a, to: b})
+			}
+		}
+	}
+}
+
+// Names of differing fields, with from and to values where useful
+func compareActions(a action.Action, b action.Action) []string {
+	var details []string
+
+	field := func(name string, from string, to string) {
+		if from != to {
+			details = append(details, fmt.Sprintf("%s %s -> %s", name, quoted(from), quoted(to)))
+		}
+	}
+
+	if a.Type() != b.Type() {
+		return []string{fmt.Sprintf("type %s -> %s", a.Type(), b.Type())}
+	}
+
+	switch from := a.(type) {
+	case *action.Zpkg:
+		to := b.(*action.Zpkg)
+		field("name", from.Name, to.Name)
+		field("version", from.Version, to.Version)
+		field("publisher", from.Publisher, to.Publisher)
+		field("arch", from.Arch, to.Arch)
+		field("os", from.Os, to.Os)
+		field("summary", from.Summary, to.Summary)
+		field("description", from.Description, to.Description)
+	case *action.License:
+		to := b.(*action.License)
+		field("expression", from.Expression, to.Expression)
+	case *action.Dir:
+		to := b.(*action.Dir)
+		field("mode", from.Mode, to.Mode)
+		field("owner", from.Owner, to.Owner)
+		field("group", from.Group, to.Group)
+	case *action.File:
+		to := b.(*action.File)
+		if from.Digest != to.Digest {
+			details = append(details, "digest")
+		}
+		field("size", fmt.Sprint(from.Size), fmt.Sprint(to.Size))
+		field("mode", from.Mode, to.Mode)
+		field("owner", from.Owner, to.Owner)
+		field("group", from.Group, to.Group)
+	case *action.SymLink:
+		to := b.(*action.SymLink)
+		field("target", from.Target, to.Target)
+		field("owner", from.Owner, to.Owner)
+		field("group", from.Group, to.Group)
+	case *action.Requirement:
+		to := b.(*action.Requirement)
+		field("method", from.Method, to.Method)
+		field("operation", from.Operation, to.Operation)
+		field("version", from.Version, to.Version)
+	case *action.Tag:
+		to := b.(*action.Tag)
+		field("value", from.Value, to.Value)
+	case *action.Signature:
+		to := b.(*action.Signature)
+		field("algo", from.Algo, to.Algo)
+		if from.Value != to.Value {
+			details = append(details, "value")
+		}
+	default:
+		if a.Columns() != b.Columns() {
+			details = append(details, "attributes")
+		}
+	}
+
+	return details
+}
+
+func section(manifest *action.Manifest, filters ...string) map[string]action.Action {
+	actions := make(map[string]action.Action)
+
+	for _, act := range manifest.Section(filters...) {
+		actions[act.Key()] = act
+	}
+
+	return actions
+}
+
+// Zpkg and License are singular, keyed by type
+func header(manifest *action.Manifest) map[string]action.Action {
+	actions := make(map[string]action.Action)
+
+	if manifest.Zpkg != nil {
+		actions[manifest.Zpkg.Type()] = manifest.Zpkg
+	}
+
+	if manifest.License != nil {
+		actions[manifest.License.Type()] = manifest.License
+	}
+
+	return actions
+}
+
+func manifestId(manifest *action.Manifest) string {
+	if manifest.Zpkg == nil {
+		return ""
+	}
+
+	return manifest.Zpkg.Name + "@" + manifest.Zpkg.Version
+}
+
+func quoted(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	return value
+}
@@ -11,8 +11,11 @@
 package zpkg
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/fezz-io/zps/action"
@@ -20,14 +23,21 @@ import (
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
 type ZpkgFile struct {
 	Bytes []byte
 	Path  string
 
-	ctx *hcl.EvalContext
-	hcl *hcl.File
+	// Overrides for variable blocks, set before evaluation
+	Vars map[string]string
+
+	ctx  *hcl.EvalContext
+	hcl  *hcl.File
+	body hcl.Body
+
+	locals hcl.Attributes
 }
 
 func (z *ZpkgFile) Load(path string) (*ZpkgFile, error) {
@@ -42,22 +52,71 @@ func (z *ZpkgFile) Load(path string) (*ZpkgFile, error) {
 
 	z.ctx = &hcl.EvalContext{
 		Variables: map[string]cty.Value{},
+		Functions: zpkgFileFunctions(filepath.Dir(z.Path)),
 	}
 
 	return z, nil
 }
 
-func (z *ZpkgFile) Eval() (*action.Manifest, error) {
+type zpkgFileBody struct {
+	Zpkgs []*action.Zpkg `hcl:"Zpkg,block"`
+
+	Includes  []*zpkgLabeled `hcl:"include,block"`
+	Variables []*zpkgLabeled `hcl:"variable,block"`
+	Locals    []*zpkgBlock   `hcl:"locals,block"`
+	Matrix    []*zpkgBlock   `hcl:"matrix,block"`
+
+	Remain hcl.Body `hcl:",remain"`
+}
+
+type zpkgBlock struct {
+	Remain hcl.Body `hcl:",remain"`
+}
+
+type zpkgLabeled struct {
+	Label  string   `hcl:"label,label"`
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// One combination of matrix values, exposed as matrix.<name>
+type MatrixEntry struct {
+	Names  []string
+	Values map[string]string
+
+	TargetPath string
+}
+
+func (m *MatrixEntry) String() string {
+	var values []string
+	for _, name := range m.Names {
+		values = append(values, name+"="+m.Values[name])
+	}
+
+	return strings.Join(values, ",")
+}
+
+func (z *ZpkgFile) parse() error {
 	var diag hcl.Diagnostics
 
-	manifest := action.NewManifest()
+	if z.hcl != nil {
+		return nil
+	}
+
 	parser := hclparse.NewParser()
 
 	// Parse HCL
 	z.hcl, diag = parser.ParseHCL(z.Bytes, z.Path)
 	if diag.HasErrors() {
-		return nil, diag
+		return diag
+	}
+
+	// Includes are merged into a single body
+	files := []*hcl.File{z.hcl}
+	err := z.include(parser, z.hcl, z.Path, map[string]bool{z.Path: true}, &files)
+	if err != nil {
+		return err
 	}
+	z.body = hcl.MergeFiles(files)
 
 	// Populate env namespace
 	envs := make(map[string]cty.Value)
@@ -68,11 +127,350 @@ func (z *ZpkgFile) Eval() (*action.Manifest, error) {
 	}
 	z.ctx.Variables["env"] = cty.ObjectVal(envs)
 
+	err = z.loadVariables()
+	if err != nil {
+		return err
+	}
+
+	// Locals are evaluated per matrix combination
+	content, _, diag := z.body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "locals"}},
+	})
+	if diag.HasErrors() {
+		return diag
+	}
+
+	z.locals = make(hcl.Attributes)
+	for _, block := range content.Blocks {
+		attrs, diag := block.Body.JustAttributes()
+		if diag.HasErrors() {
+			return diag
+		}
+
+		for name, attr := range attrs {
+			if _, ok := z.locals[name]; ok {
+				return errors.New("zpkgfile: local." + name + " defined more than once")
+			}
+
+			z.locals[name] = attr
+		}
+	}
+
+	return nil
+}
+
+// Collect included fragments depth first, paths relative to the including file
+func (z *ZpkgFile) include(parser *hclparse.Parser, file *hcl.File, filePath string, seen map[string]bool, files *[]*hcl.File) error {
+	content, _, diag := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "include", LabelNames: []string{"path"}}},
+	})
+	if diag.HasErrors() {
+		return diag
+	}
+
+	for _, block := range content.Blocks {
+		includePath := block.Labels[0]
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(filePath), includePath)
+		}
+
+		if seen[includePath] {
+			return errors.New("zpkgfile: " + includePath + " included more than once")
+		}
+		seen[includePath] = true
+
+		fragment, diag := parser.ParseHCLFile(includePath)
+		if diag.HasErrors() {
+			return diag
+		}
+
+		*files = append(*files, fragment)
+
+		err := z.include(parser, fragment, includePath, seen, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Variables take their default unless overridden, overrides are
+// converted to the type of the default when possible
+func (z *ZpkgFile) loadVariables() error {
+	content, _, diag := z.body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+	})
+	if diag.HasErrors() {
+		return diag
+	}
+
+	vars := make(map[string]cty.Value)
+
+	for _, block := range content.Blocks {
+		name := block.Labels[0]
+
+		if _, ok := vars[name]; ok {
+			return errors.New("zpkgfile: variable " + name + " defined more than once")
+		}
+
+		attrs, diag := block.Body.JustAttributes()
+		if diag.HasErrors() {
+			return diag
+		}
+
+		value := cty.NullVal(cty.DynamicPseudoType)
+		if attr, ok := attrs["default"]; ok {
+			value, diag = attr.Expr.Value(z.ctx)
+			if diag.HasErrors() {
+				return diag
+			}
+		}
+
+		if override, ok := z.Vars[name]; ok {
+			converted, err := convert.Convert(cty.StringVal(override), value.Type())
+			if err != nil || value.IsNull() {
+				converted = cty.StringVal(override)
+			}
+
+			value = converted
+		}
+
+		if value.IsNull() {
+			return errors.New("zpkgfile: variable " + name + " requires a value")
+		}
+
+		vars[name] = value
+	}
+
+	for name := range z.Vars {
+		if _, ok := vars[name]; !ok {
+			return errors.New("zpkgfile: variable " + name + " is not declared")
+		}
+	}
+
+	z.ctx.Variables["var"] = cty.ObjectVal(vars)
+
+	return nil
+}
+
+// Locals may refer to each other, evaluate until every one resolves
+func (z *ZpkgFile) evalLocals() error {
+	locals := make(map[string]cty.Value)
+	z.ctx.Variables["local"] = cty.ObjectVal(locals)
+
+	pending := len(z.locals)
+	for pending > 0 {
+		progress := false
+
+		for name, attr := range z.locals {
+			if _, ok := locals[name]; ok {
+				continue
+			}
+
+			ready := true
+			for _, traversal := range attr.Expr.Variables() {
+				if traversal.RootName() != "local" || len(traversal) < 2 {
+					continue
+				}
+
+				if ref, ok := traversal[1].(hcl.TraverseAttr); ok {
+					if _, ok := locals[ref.Name]; !ok {
+						ready = false
+					}
+				}
+			}
+
+			if !ready {
+				continue
+			}
+
+			value, diag := attr.Expr.Value(z.ctx)
+			if diag.HasErrors() {
+				return diag
+			}
+
+			locals[name] = value
+			z.ctx.Variables["local"] = cty.ObjectVal(locals)
+
+			progress = true
+			pending--
+		}
+
+		if !progress {
+			return errors.New("zpkgfile: locals contain a cycle or undefined reference")
+		}
+	}
+
+	return nil
+}
+
+// Expands the matrix block into every combination of its lists, names sorted
+// and values in definition order, nil without a matrix block
+func (z *ZpkgFile) Matrix() ([]*MatrixEntry, error) {
+	err := z.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	content, _, diag := z.body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "matrix"}},
+	})
+	if diag.HasErrors() {
+		return nil, diag
+	}
+
+	if len(content.Blocks) == 0 {
+		return nil, nil
+	}
+
+	if len(content.Blocks) > 1 {
+		return nil, errors.New("zpkgfile: only one matrix block allowed")
+	}
+
+	attrs, diag := content.Blocks[0].Body.JustAttributes()
+	if diag.HasErrors() {
+		return nil, diag
+	}
+
+	var names []string
+	lists := make(map[string][]string)
+
+	for name, attr := range attrs {
+		if name == "target_path" {
+			continue
+		}
+
+		value, diag := attr.Expr.Value(z.ctx)
+		if diag.HasErrors() {
+			return nil, diag
+		}
+
+		if !value.Type().IsListType() && !value.Type().IsTupleType() {
+			return nil, errors.New("zpkgfile: matrix." + name + " must be a list")
+		}
+
+		for it := value.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+
+			str, err := convert.Convert(elem, cty.String)
+			if err != nil || str.IsNull() {
+				return nil, errors.New("zpkgfile: matrix." + name + " must be a list of strings")
+			}
+
+			lists[name] = append(lists[name], str.AsString())
+		}
+
+		if len(lists[name]) == 0 {
+			return nil, errors.New("zpkgfile: matrix." + name + " is empty")
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	entries := []*MatrixEntry{{Names: names, Values: map[string]string{}}}
+	for _, name := range names {
+		var expanded []*MatrixEntry
+
+		for _, entry := range entries {
+			for _, value := range lists[name] {
+				values := map[string]string{name: value}
+				for k, v := range entry.Values {
+					values[k] = v
+				}
+
+				expanded = append(expanded, &MatrixEntry{Names: names, Values: values})
+			}
+		}
+
+		entries = expanded
+	}
+
+	// Target path is a template evaluated per combination
+	if attr, ok := attrs["target_path"]; ok {
+		for _, entry := range entries {
+			z.setMatrix(entry)
+
+			value, diag := attr.Expr.Value(z.ctx)
+			if diag.HasErrors() {
+				return nil, diag
+			}
+
+			str, err := convert.Convert(value, cty.String)
+			if err != nil || str.IsNull() {
+				return nil, errors.New("zpkgfile: matrix.target_path must be a string")
+			}
+
+			entry.TargetPath = str.AsString()
+		}
+
+		z.setMatrix(nil)
+	}
+
+	return entries, nil
+}
+
+func (z *ZpkgFile) setMatrix(entry *MatrixEntry) {
+	if entry == nil {
+		delete(z.ctx.Variables, "matrix")
+		return
+	}
+
+	values := make(map[string]cty.Value)
+	for name, value := range entry.Values {
+		values[name] = cty.StringVal(value)
+	}
+
+	z.ctx.Variables["matrix"] = cty.ObjectVal(values)
+}
+
+// Evaluates to one manifest per Zpkg block, the first holds every other action,
+// entry provides the matrix namespace and may be nil
+func (z *ZpkgFile) Eval(entry *MatrixEntry) ([]*action.Manifest, error) {
+	var diag hcl.Diagnostics
+
+	body := &zpkgFileBody{}
+	manifest := action.NewManifest()
+
+	err := z.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	z.setMatrix(entry)
+	defer z.setMatrix(nil)
+
+	err = z.evalLocals()
+	if err != nil {
+		return nil, err
+	}
+
 	// Eval HCL with context
-	diag = gohcl.DecodeBody(z.hcl.Body, z.ctx, manifest)
+	diag = gohcl.DecodeBody(z.body, z.ctx, body)
+	if diag.HasErrors() {
+		return nil, diag
+	}
+
+	if len(body.Zpkgs) == 0 {
+		return nil, errors.New("zpkgfile: Zpkg block required")
+	}
+
+	diag = gohcl.DecodeBody(body.Remain, z.ctx, manifest)
 	if diag.HasErrors() {
 		return nil, diag
 	}
 
-	return manifest, nil
+	manifest.Zpkg = body.Zpkgs[0]
+	manifests := []*action.Manifest{manifest}
+
+	for _, zp := range body.Zpkgs[1:] {
+		sibling := action.NewManifest()
+		sibling.Zpkg = zp
+
+		manifests = append(manifests, sibling)
+	}
+
+	return manifests, nil
 }
@@ -0,0 +1,96 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package zpkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fezz-io/zps/config"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Config function library plus Zpkgfile helpers, paths are relative to dir
+func zpkgFileFunctions(dir string) map[string]function.Function {
+	functions := config.Functions()
+
+	functions["file"] = fileFunc(dir)
+	functions["sha256"] = sha256Func()
+	functions["git_describe"] = gitDescribeFunc(dir)
+
+	return functions
+}
+
+func fileFunc(dir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			return cty.StringVal(string(content)), nil
+		},
+	})
+}
+
+func sha256Func() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "value",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			sum := sha256.Sum256([]byte(args[0].AsString()))
+
+			return cty.StringVal(hex.EncodeToString(sum[:])), nil
+		},
+	})
+}
+
+// Output of git describe for the repository holding the Zpkgfile
+func gitDescribeFunc(dir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			cmd := exec.Command("git", "describe", "--tags", "--always", "--dirty")
+			cmd.Dir = dir
+
+			out, err := cmd.Output()
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			return cty.StringVal(strings.TrimSpace(string(out))), nil
+		},
+	})
+}
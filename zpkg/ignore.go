@@ -0,0 +1,112 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package zpkg
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/fezz-io/zps/action"
+)
+
+// Exclude patterns from Exclude blocks and .zpkgignore
+//
+// Patterns without a slash match the base name at any depth, a leading
+// slash anchors to the target path and a trailing slash matches only dirs
+type Ignore struct {
+	patterns []*ignorePattern
+}
+
+type ignorePattern struct {
+	glob    string
+	base    bool
+	dirOnly bool
+}
+
+func NewIgnore() *Ignore {
+	return &Ignore{}
+}
+
+// Load patterns from an ignore file, a missing file is not an error
+func (i *Ignore) Load(ignorePath string) error {
+	file, err := os.Open(ignorePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i.Add(line)
+	}
+
+	return scanner.Err()
+}
+
+func (i *Ignore) Add(patterns ...string) *Ignore {
+	for _, pattern := range patterns {
+		ip := &ignorePattern{}
+
+		if strings.HasSuffix(pattern, "/") {
+			ip.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+
+		if strings.HasPrefix(pattern, "/") {
+			pattern = strings.TrimLeft(pattern, "/")
+		} else if !strings.Contains(pattern, "/") {
+			ip.base = true
+		}
+
+		ip.glob = pattern
+		i.patterns = append(i.patterns, ip)
+	}
+
+	return i
+}
+
+func (i *Ignore) AddExcludes(excludes ...*action.Exclude) *Ignore {
+	for _, exclude := range excludes {
+		i.Add(exclude.Pattern)
+	}
+
+	return i
+}
+
+func (i *Ignore) Match(objectPath string, dir bool) bool {
+	for _, ip := range i.patterns {
+		if ip.dirOnly && !dir {
+			continue
+		}
+
+		name := objectPath
+		if ip.base {
+			name = path.Base(objectPath)
+		}
+
+		if action.MatchGlob(ip.glob, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,391 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fezz-io/zps/action"
+)
+
+type deb struct {
+	path string
+
+	control map[string]string
+	scripts map[string]string
+}
+
+func (d *deb) Load(stagePath string, manifest *action.Manifest, report *Report) error {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 8)
+	_, err = io.ReadFull(file, magic)
+	if err != nil || string(magic) != "!<arch>\n" {
+		return errors.New("importer: not a deb archive")
+	}
+
+	var data bool
+
+	// Walk ar members
+	for {
+		name, size, err := d.member(file)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		member := io.LimitReader(file, size)
+
+		switch {
+		case name == "debian-binary":
+			content, err := ioutil.ReadAll(member)
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(string(content), "2.") {
+				return fmt.Errorf("importer: unsupported deb version %s", strings.TrimSpace(string(content)))
+			}
+		case strings.HasPrefix(name, "control.tar"):
+			reader, err := decompress(name, member)
+			if err != nil {
+				return err
+			}
+
+			err = d.loadControl(reader, report)
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(name, "data.tar"):
+			reader, err := decompress(name, member)
+			if err != nil {
+				return err
+			}
+
+			err = extractTar(reader, stagePath, manifest, report)
+			if err != nil {
+				return err
+			}
+
+			data = true
+		default:
+			report.Add("deb member %s skipped", name)
+		}
+
+		// Drain remaining member content and padding
+		_, err = io.Copy(ioutil.Discard, member)
+		if err != nil {
+			return err
+		}
+		if size%2 != 0 {
+			_, err = file.Seek(1, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.control == nil {
+		return errors.New("importer: deb control file not found")
+	}
+	if !data {
+		return errors.New("importer: deb data archive not found")
+	}
+
+	return d.translate(manifest, report)
+}
+
+// Read an ar member header
+func (d *deb) member(reader io.Reader) (string, int64, error) {
+	header := make([]byte, 60)
+
+	_, err := io.ReadFull(reader, header)
+	if err == io.ErrUnexpectedEOF {
+		return "", 0, io.EOF
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	if string(header[58:60]) != "`\n" {
+		return "", 0, errors.New("importer: malformed ar header")
+	}
+
+	name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+	if err != nil {
+		return "", 0, errors.New("importer: malformed ar member size")
+	}
+
+	return name, size, nil
+}
+
+func (d *deb) loadControl(reader io.Reader, report *Report) error {
+	tr := tar.NewReader(reader)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, ok := cleanPath(hdr.Name)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "control":
+			d.control, err = parseControl(tr)
+			if err != nil {
+				return err
+			}
+		case "md5sums":
+			continue
+		case "preinst", "postinst", "prerm", "postrm":
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+
+			if d.scripts == nil {
+				d.scripts = make(map[string]string)
+			}
+			d.scripts[name] = string(content)
+		case "config", "triggers":
+			report.Add("maintainer script %s not translated", name)
+		case "conffiles":
+			report.Add("conffiles not translated, listed files are installed as regular files")
+		default:
+			report.Add("control file %s not translated", name)
+		}
+	}
+
+	return nil
+}
+
+// Map control fields onto the manifest
+func (d *deb) translate(manifest *action.Manifest, report *Report) error {
+	zp := action.NewZpkg()
+
+	zp.Name = d.control["Package"]
+	zp.Os = "linux"
+	zp.Arch = translateArch(d.control["Architecture"])
+
+	version := d.control["Version"]
+	if index := strings.Index(version, ":"); index != -1 {
+		report.Add("version epoch %s dropped", version[:index])
+		version = version[index+1:]
+	}
+
+	var exact bool
+	zp.Version, exact = coerceVersion(version)
+	if !exact {
+		report.Add("version %s coerced to %s", d.control["Version"], zp.Version)
+	}
+
+	description := strings.SplitN(d.control["Description"], "\n", 2)
+	zp.Summary = strings.TrimSpace(description[0])
+	if len(description) > 1 {
+		zp.Description = strings.TrimSpace(description[1])
+	}
+
+	manifest.Zpkg = zp
+
+	tag := action.NewTag()
+	tag.Name = "zps.import.version"
+	tag.Value = d.control["Version"]
+	manifest.Add(tag)
+
+	if maintainer, ok := d.control["Maintainer"]; ok {
+		tag := action.NewTag()
+		tag.Name = "zps.import.maintainer"
+		tag.Value = maintainer
+		manifest.Add(tag)
+	}
+
+	if homepage, ok := d.control["Homepage"]; ok {
+		tag := action.NewTag()
+		tag.Name = "zps.import.homepage"
+		tag.Value = homepage
+		manifest.Add(tag)
+	}
+
+	relations := []struct {
+		field  string
+		method string
+	}{
+		{"Pre-Depends", "depends"},
+		{"Depends", "depends"},
+		{"Conflicts", "conflicts"},
+		{"Breaks", "conflicts"},
+		{"Provides", "provides"},
+	}
+
+	for _, relation := range relations {
+		value, ok := d.control[relation.field]
+		if !ok {
+			continue
+		}
+
+		for _, req := range parseRelations(relation.field, relation.method, value, report) {
+			manifest.Add(req)
+		}
+	}
+
+	for _, name := range []string{"preinst", "postinst", "prerm", "postrm"} {
+		if script, ok := d.scripts[name]; ok {
+			translateScript(name, script, manifest, report)
+		}
+	}
+
+	for _, field := range []string{"Recommends", "Suggests", "Enhances", "Replaces"} {
+		if _, ok := d.control[field]; ok {
+			report.Add("%s relationships not translated", field)
+		}
+	}
+
+	return nil
+}
+
+// Parse an RFC 822 style control file
+func parseControl(reader io.Reader) (map[string]string, error) {
+	control := make(map[string]string)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var field string
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			continue
+		}
+
+		// Continuation
+		if line[0] == ' ' || line[0] == '\t' {
+			if field == "" {
+				return nil, errors.New("importer: malformed deb control file")
+			}
+
+			value := strings.TrimSpace(line)
+			if value == "." {
+				value = ""
+			}
+			control[field] = control[field] + "\n" + value
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("importer: malformed deb control file")
+		}
+
+		field = parts[0]
+		control[field] = strings.TrimSpace(parts[1])
+	}
+
+	return control, scanner.Err()
+}
+
+// Parse a deb relationship field such as "libc6 (>= 2.14), foo | bar"
+func parseRelations(field string, method string, value string, report *Report) []*action.Requirement {
+	var reqs []*action.Requirement
+
+	for _, entry := range strings.Split(strings.Replace(value, "\n", " ", -1), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		alternatives := strings.Split(entry, "|")
+		if len(alternatives) > 1 {
+			report.Add("%s alternatives %s reduced to the first", field, entry)
+		}
+
+		relation := strings.TrimSpace(alternatives[0])
+
+		name := relation
+		operation := ""
+		version := ""
+
+		if index := strings.Index(relation, "("); index != -1 {
+			name = strings.TrimSpace(relation[:index])
+			constraint := strings.Trim(strings.TrimSpace(relation[index:]), "()")
+
+			var op string
+			for _, candidate := range []string{">=", "<=", ">>", "<<", "=", ">", "<"} {
+				if strings.HasPrefix(constraint, candidate) {
+					op = candidate
+					break
+				}
+			}
+
+			raw := strings.TrimSpace(strings.TrimPrefix(constraint, op))
+			if colon := strings.Index(raw, ":"); colon != -1 {
+				raw = raw[colon+1:]
+			}
+
+			var exact bool
+			version, exact = coerceVersion(raw)
+			if !exact {
+				report.Add("%s %s version %s coerced to %s", field, name, raw, version)
+			}
+
+			switch op {
+			case ">=", ">":
+				operation = "GTE"
+			case "<=", "<":
+				operation = "LTE"
+			case "=":
+				operation = "EQ"
+			case ">>":
+				operation = "GTE"
+				report.Add("%s %s strictly greater than %s approximated as >=", field, name, raw)
+			case "<<":
+				operation = "LTE"
+				report.Add("%s %s strictly less than %s approximated as <=", field, name, raw)
+			}
+		}
+
+		// Architecture qualifiers are not supported
+		if index := strings.Index(name, ":"); index != -1 {
+			report.Add("%s %s architecture qualifier dropped", field, name)
+			name = name[:index]
+		}
+
+		if strings.Contains(name, "[") || strings.Contains(relation, "<!") {
+			report.Add("%s %s restrictions not translated", field, name)
+		}
+
+		reqs = append(reqs, newRequirement(method, name, operation, version))
+	}
+
+	return reqs
+}
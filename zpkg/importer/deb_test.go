@@ -0,0 +1,334 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fezz-io/zps/action"
+)
+
+const debControl = `Package: foo
+Version: 1:1.2.3-4
+Architecture: all
+Maintainer: Foo Maintainers <foo@example.com>
+Depends: libc6 (>= 2.14), bar | baz, qux:any
+Conflicts: old-foo (<< 1.0)
+Provides: foo-api
+Recommends: extra
+Description: Foo tool
+ Longer description
+ .
+ second paragraph
+`
+
+const debPostinst = `#!/bin/sh
+set -e
+# Automatically added by dh_installsystemd
+if [ "$1" = "configure" ] || [ "$1" = "abort-upgrade" ] ; then
+	if deb-systemd-helper --quiet was-enabled 'foo.service'; then
+		deb-systemd-helper enable 'foo.service' >/dev/null || true
+	else
+		deb-systemd-helper update-state 'foo.service' >/dev/null || true
+	fi
+fi
+# End automatically added section
+exit 0
+`
+
+type tarEntry struct {
+	name    string
+	mode    int64
+	content string
+}
+
+func tarGz(t *testing.T, entries ...tarEntry) []byte {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Mode: entry.mode, Size: int64(len(entry.content)), Uname: "root", Gname: "root"}
+		if strings.HasSuffix(entry.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Size = 0
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, entry.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func arHeader(name string, size int) string {
+	return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10d`\n", name, "0", "0", "0", "100644", size)
+}
+
+// Build an ar archive, odd sized members are padded to an even offset
+func arArchive(members ...[2]string) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("!<arch>\n")
+	for _, member := range members {
+		buf.WriteString(arHeader(member[0], len(member[1])))
+		buf.WriteString(member[1])
+		if len(member[1])%2 != 0 {
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func writeFixture(t *testing.T, name string, content []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixture := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(fixture, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fixture, func() { os.RemoveAll(dir) }
+}
+
+func hasMessage(report *Report, message string) bool {
+	for _, m := range report.Messages {
+		if m == message {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestDebMember(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		member string
+		size   int64
+		err    string
+	}{
+		{"plain", arHeader("debian-binary", 4), "debian-binary", 4, ""},
+		{"gnu name", arHeader("data.tar.xz/", 1024), "data.tar.xz", 1024, ""},
+		{"truncated", arHeader("control.tar.gz", 10)[:30], "", 0, "EOF"},
+		{"bad terminator", arHeader("control.tar.gz", 10)[:58] + "\n\n", "", 0, "importer: malformed ar header"},
+		{"bad size", strings.Replace(arHeader("control.tar.gz", 10), "10 ", "1x ", 1), "", 0, "importer: malformed ar member size"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, size, err := (&deb{}).member(strings.NewReader(test.header))
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.member || size != test.size {
+				t.Errorf("expected %s %d, got %s %d", test.member, test.size, name, size)
+			}
+		})
+	}
+}
+
+func TestParseControl(t *testing.T) {
+	control, err := parseControl(strings.NewReader(debControl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Package":      "foo",
+		"Version":      "1:1.2.3-4",
+		"Architecture": "all",
+		"Depends":      "libc6 (>= 2.14), bar | baz, qux:any",
+		"Description":  "Foo tool\nLonger description\n\nsecond paragraph",
+	}
+
+	for field, value := range expected {
+		if control[field] != value {
+			t.Errorf("%s: expected %q, got %q", field, value, control[field])
+		}
+	}
+
+	for _, malformed := range []string{" leading continuation\n", "Package foo\n"} {
+		if _, err := parseControl(strings.NewReader(malformed)); err == nil {
+			t.Errorf("expected error for %q", malformed)
+		}
+	}
+}
+
+func TestParseRelations(t *testing.T) {
+	tests := []struct {
+		value     string
+		name      string
+		operation string
+		version   string
+		message   string
+	}{
+		{"libc6 (>= 2.14)", "libc6", "GTE", "2.14.0", ""},
+		{"libfoo (>= 1.2~rc1)", "libfoo", "GTE", "1.2.0", "Depends libfoo version 1.2~rc1 coerced to 1.2.0"},
+		{"bar | baz", "bar", "ANY", "", "Depends alternatives bar | baz reduced to the first"},
+		{"qux:any", "qux", "ANY", "", "Depends qux:any architecture qualifier dropped"},
+		{"old (<< 1:1.0.0)", "old", "LTE", "1.0.0", "Depends old strictly less than 1.0.0 approximated as <="},
+		{"exact (= 2.0.0)", "exact", "EQ", "2.0.0", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			report := &Report{}
+
+			reqs := parseRelations("Depends", "depends", test.value, report)
+			if len(reqs) != 1 {
+				t.Fatalf("expected 1 requirement, got %d", len(reqs))
+			}
+
+			req := reqs[0]
+			if req.Name != test.name || req.Operation != test.operation || req.Version != test.version {
+				t.Errorf("expected %s %s %s, got %s %s %s", test.name, test.operation, test.version, req.Name, req.Operation, req.Version)
+			}
+
+			if test.message != "" && !hasMessage(report, test.message) {
+				t.Errorf("expected report %q, got %v", test.message, report.Messages)
+			}
+			if test.message == "" && len(report.Messages) != 0 {
+				t.Errorf("expected empty report, got %v", report.Messages)
+			}
+		})
+	}
+}
+
+func TestDebLoad(t *testing.T) {
+	control := tarGz(t,
+		tarEntry{"./control", 0644, debControl},
+		tarEntry{"./md5sums", 0644, ""},
+		tarEntry{"./postinst", 0755, debPostinst},
+		tarEntry{"./prerm", 0755, "#!/bin/sh\nldconfig\n"},
+	)
+	data := tarGz(t,
+		tarEntry{"./usr/", 0755, ""},
+		tarEntry{"./usr/bin/", 0755, ""},
+		tarEntry{"./usr/bin/foo", 0755, "foo"},
+		tarEntry{"./lib/systemd/system/foo.service", 0644, "[Service]\n"},
+	)
+
+	fixture, cleanup := writeFixture(t, "foo.deb", arArchive(
+		[2]string{"debian-binary", "2.0\n"},
+		[2]string{"control.tar.gz", string(control)},
+		[2]string{"_extra", "odd"},
+		[2]string{"data.tar.gz", string(data)},
+	))
+	defer cleanup()
+
+	stagePath, err := ioutil.TempDir("", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stagePath)
+
+	manifest := action.NewManifest()
+	report := &Report{}
+
+	err = (&deb{path: fixture}).Load(stagePath, manifest, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zp := manifest.Zpkg
+	if zp.Name != "foo" || zp.Version != "1.2.3" || zp.Arch != "all" || zp.Summary != "Foo tool" {
+		t.Errorf("unexpected zpkg %s %s %s %q", zp.Name, zp.Version, zp.Arch, zp.Summary)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(stagePath, "usr/bin/foo"))
+	if err != nil || string(content) != "foo" {
+		t.Errorf("expected usr/bin/foo to be staged, got %q %v", content, err)
+	}
+
+	var reqs []string
+	for _, req := range manifest.Requirements {
+		reqs = append(reqs, req.Method+" "+req.Name)
+	}
+	if strings.Join(reqs, ",") != "depends libc6,depends bar,depends qux,conflicts old-foo,provides foo-api" {
+		t.Errorf("unexpected requirements %v", reqs)
+	}
+
+	for _, message := range []string{
+		"deb member _extra skipped",
+		"version epoch 1 dropped",
+		"maintainer script prerm not translated, ldconfig has no equivalent",
+		"Recommends relationships not translated",
+	} {
+		if !hasMessage(report, message) {
+			t.Errorf("expected report %q, got %v", message, report.Messages)
+		}
+	}
+
+	for _, message := range report.Messages {
+		if strings.Contains(message, "postinst") {
+			t.Errorf("expected postinst to be translated, got %q", message)
+		}
+	}
+}
+
+func TestDebLoadMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+		err     string
+	}{
+		{"not ar", []byte("not an ar archive"), "importer: not a deb archive"},
+		{"version", arArchive([2]string{"debian-binary", "3.0\n"}), "importer: unsupported deb version 3.0"},
+		{"no control", arArchive([2]string{"debian-binary", "2.0\n"}), "importer: deb control file not found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture, cleanup := writeFixture(t, "bad.deb", test.archive)
+			defer cleanup()
+
+			err := (&deb{path: fixture}).Load(filepath.Dir(fixture), action.NewManifest(), &Report{})
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
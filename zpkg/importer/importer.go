@@ -0,0 +1,912 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/chuckpreslar/emission"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/fezz-io/zps/action"
+	"github.com/fezz-io/zps/phase"
+	"github.com/fezz-io/zps/provider"
+	"github.com/fezz-io/zps/zpkg"
+	"github.com/fezz-io/zps/zpkg/payload"
+	"github.com/fezz-io/zps/zps"
+)
+
+const (
+	FormatDeb = "deb"
+	FormatRpm = "rpm"
+	FormatTar = "tar"
+)
+
+// Translates a foreign archive into the manifest and extracts its
+// file system objects into the stage path
+type archive interface {
+	Load(stagePath string, manifest *action.Manifest, report *Report) error
+}
+
+// Collects anything that could not be translated into a zpkg
+type Report struct {
+	Messages []string
+}
+
+func (r *Report) Add(format string, a ...interface{}) {
+	r.Messages = append(r.Messages, fmt.Sprintf(format, a...))
+}
+
+type Importer struct {
+	*emission.Emitter
+
+	options *provider.Options
+
+	format     string
+	workPath   string
+	outputPath string
+
+	name        string
+	version     string
+	publisher   string
+	os          string
+	arch        string
+	summary     string
+	description string
+
+	manifest *action.Manifest
+	report   *Report
+
+	stagePath string
+	filename  string
+
+	header  *zpkg.Header
+	payload *payload.Writer
+
+	writer *zpkg.Writer
+}
+
+func NewImporter() *Importer {
+	importer := &Importer{Emitter: emission.NewEmitter()}
+
+	importer.options = &provider.Options{}
+
+	importer.manifest = action.NewManifest()
+	importer.report = &Report{}
+
+	importer.header = zpkg.NewHeader(zpkg.Version, zpkg.Compression)
+	importer.payload = payload.NewWriter("", 0)
+	importer.writer = zpkg.NewWriter()
+
+	return importer
+}
+
+func (i *Importer) Format(format string) *Importer {
+	i.format = format
+	return i
+}
+
+func (i *Importer) WorkPath(wp string) *Importer {
+	i.workPath = wp
+	i.payload.WorkPath = wp
+	return i
+}
+
+func (i *Importer) OutputPath(op string) *Importer {
+	i.outputPath = op
+	return i
+}
+
+func (i *Importer) Secure(s bool) *Importer {
+	i.options.Secure = s
+	return i
+}
+
+func (i *Importer) Name(name string) *Importer {
+	i.name = name
+	return i
+}
+
+func (i *Importer) Version(version string) *Importer {
+	i.version = version
+	return i
+}
+
+func (i *Importer) Publisher(publisher string) *Importer {
+	i.publisher = publisher
+	return i
+}
+
+func (i *Importer) Os(os string) *Importer {
+	i.os = os
+	return i
+}
+
+func (i *Importer) Arch(arch string) *Importer {
+	i.arch = arch
+	return i
+}
+
+func (i *Importer) Summary(summary string) *Importer {
+	i.summary = summary
+	return i
+}
+
+func (i *Importer) Description(description string) *Importer {
+	i.description = description
+	return i
+}
+
+// Set default paths
+func (i *Importer) setPaths() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	if i.workPath == "" {
+		i.workPath = wd
+		i.payload.WorkPath = wd
+	}
+	if i.outputPath == "" {
+		i.outputPath = wd
+	}
+
+	i.stagePath, err = ioutil.TempDir(i.workPath, "import")
+	if err != nil {
+		return err
+	}
+
+	i.options.TargetPath = i.stagePath
+
+	return nil
+}
+
+// Sniff the archive format if one was not supplied
+func (i *Importer) detect(filePath string) (archive, error) {
+	format := i.format
+
+	if format == "" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		magic := make([]byte, 8)
+		_, err = io.ReadFull(file, magic)
+		if err != nil {
+			return nil, errors.New("importer: unable to read archive header")
+		}
+
+		switch {
+		case bytes.Equal(magic, []byte("!<arch>\n")):
+			format = FormatDeb
+		case bytes.Equal(magic[:4], rpmLeadMagic):
+			format = FormatRpm
+		default:
+			format = FormatTar
+		}
+	}
+
+	switch format {
+	case FormatDeb:
+		return &deb{path: filePath}, nil
+	case FormatRpm:
+		return &rpm{path: filePath}, nil
+	case FormatTar:
+		return &tarball{path: filePath}, nil
+	default:
+		return nil, fmt.Errorf("importer: unsupported format %s", format)
+	}
+}
+
+// Apply overrides and defaults to the translated zpkg metadata
+func (i *Importer) processOptions(format string) error {
+	zp := i.manifest.Zpkg
+
+	if zp == nil {
+		zp = action.NewZpkg()
+	}
+
+	if i.name != "" {
+		zp.Name = i.name
+	}
+	if i.publisher != "" {
+		zp.Publisher = i.publisher
+	}
+	if i.os != "" {
+		zp.Os = i.os
+	}
+	if i.arch != "" {
+		zp.Arch = i.arch
+	}
+	if i.summary != "" {
+		zp.Summary = i.summary
+	}
+	if i.description != "" {
+		zp.Description = i.description
+	}
+
+	if i.version != "" {
+		version, exact := coerceVersion(i.version)
+		if !exact {
+			return fmt.Errorf("importer: version %s is not semver", i.version)
+		}
+		zp.Version = version
+	}
+
+	if zp.Os == "" {
+		zp.Os = runtime.GOOS
+	}
+	if zp.Arch == "" {
+		zp.Arch = translateArch(runtime.GOARCH)
+	}
+
+	// Architecture independent packages are built for the host
+	if zp.Arch == "all" || zp.Arch == "noarch" {
+		i.report.Add("architecture %s mapped to %s, override with --arch", zp.Arch, translateArch(runtime.GOARCH))
+		zp.Arch = translateArch(runtime.GOARCH)
+	}
+	if zp.Summary == "" {
+		zp.Summary = zp.Name
+	}
+	if zp.Description == "" {
+		zp.Description = zp.Summary
+	}
+
+	if zp.Name == "" {
+		return errors.New("importer: name required")
+	}
+	if zp.Version == "" {
+		return errors.New("importer: version required")
+	}
+	if zp.Publisher == "" {
+		return errors.New("importer: publisher required")
+	}
+	if !zp.IsValid() {
+		return fmt.Errorf("importer: unsupported platform %s-%s", zp.Os, zp.Arch)
+	}
+
+	i.manifest.Zpkg = zp
+
+	tag := action.NewTag()
+	tag.Name = "zps.import.format"
+	tag.Value = format
+	i.manifest.Add(tag)
+
+	// A package may not require itself, rpm packages always provide their
+	// own name which is implied for a zpkg
+	for _, act := range i.manifest.Section("Requirement") {
+		req := act.(*action.Requirement)
+
+		if req.Name == zp.Name {
+			if req.Method != "provides" {
+				i.report.Add("%s %s names the package itself, dropped", req.Method, req.Name)
+			}
+
+			i.manifest.Requirements = removeRequirement(i.manifest.Requirements, req)
+			i.manifest.Index()
+		}
+	}
+
+	return nil
+}
+
+// Add FS objects not described by the archive metadata, parent
+// directories are commonly implied by rpm and tar archives
+func (i *Importer) resolve() error {
+	err := filepath.Walk(i.stagePath, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		objectPath := strings.Replace(path, i.stagePath+string(os.PathSeparator), "", 1)
+
+		if objectPath != i.stagePath {
+			if f.IsDir() {
+				var dir = action.NewDir()
+				dir.Path = objectPath
+
+				if !i.manifest.Exists(dir) {
+					dir.Owner = "root"
+					dir.Group = "root"
+					dir.Mode = "0755"
+					i.manifest.Add(dir)
+				}
+			}
+
+			if f.Mode().IsRegular() {
+				var file = action.NewFile()
+				file.Path = objectPath
+
+				if !i.manifest.Exists(file) {
+					i.manifest.Add(file)
+				}
+			}
+
+			if f.Mode()&os.ModeSymlink == os.ModeSymlink {
+				var symlink = action.NewSymLink()
+				symlink.Path = objectPath
+
+				if !i.manifest.Exists(symlink) {
+					i.manifest.Add(symlink)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Systemd units map onto the Service action
+	for _, act := range i.manifest.Section("File") {
+		file := act.(*action.File)
+
+		dir, name := path.Split(file.Path)
+		if dir == "usr/lib/systemd/system/" || dir == "lib/systemd/system/" {
+			if strings.HasSuffix(name, ".service") {
+				service := action.NewService()
+				service.Name = strings.TrimSuffix(name, ".service")
+				i.manifest.Add(service)
+			}
+		}
+	}
+
+	return i.manifest.Validate()
+}
+
+// Set file name and zpkg timestamp
+func (i *Importer) set() error {
+	pkg, err := zps.NewPkgFromManifest(i.manifest)
+	if err != nil {
+		return err
+	}
+
+	pkg.Version().Timestamp = time.Now().UTC()
+	i.manifest.Zpkg.Version = pkg.Version().String()
+
+	i.filename = filepath.Join(i.outputPath, pkg.FileName())
+
+	return nil
+}
+
+// Completes manifest, builds payload
+func (i *Importer) realize() error {
+	var err error
+
+	// Setup context
+	ctx := context.WithValue(context.Background(), "options", i.options)
+	ctx = context.WithValue(ctx, "phase", phase.PACKAGE)
+	ctx = context.WithValue(ctx, "payload", i.payload)
+
+	factory := provider.DefaultFactory(i.Emitter)
+
+	for _, act := range i.manifest.Actions() {
+		err = factory.Get(act).Realize(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (i *Importer) Import(filePath string) (string, *action.Manifest, []string, error) {
+	arch, err := i.detect(filePath)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	err = i.setPaths()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer os.RemoveAll(i.stagePath)
+
+	err = arch.Load(i.stagePath, i.manifest, i.report)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	format := i.format
+	switch arch.(type) {
+	case *deb:
+		format = FormatDeb
+	case *rpm:
+		format = FormatRpm
+	case *tarball:
+		format = FormatTar
+	}
+
+	err = i.processOptions(format)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	err = i.resolve()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	err = i.set()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	err = i.realize()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	// Write the file
+	err = i.writer.Write(i.filename, i.header, i.manifest, i.payload)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	i.Emit("builder.complete", i.filename)
+
+	return i.filename, i.manifest, i.report.Messages, err
+}
+
+var leadingVersion = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}`)
+
+// Coerce a foreign version into semver, reports whether the version
+// was translated without loss
+func coerceVersion(version string) (string, bool) {
+	core := leadingVersion.FindString(version)
+	if core == "" {
+		return "0.0.0", false
+	}
+
+	parts := strings.Split(core, ".")
+	exact := core == version
+
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+
+	// Strip leading zeros which semver rejects
+	for index, part := range parts {
+		trimmed := strings.TrimLeft(part, "0")
+		if trimmed == "" {
+			trimmed = "0"
+		}
+		if trimmed != part {
+			exact = false
+		}
+		parts[index] = trimmed
+	}
+
+	return strings.Join(parts, "."), exact
+}
+
+func translateArch(arch string) string {
+	switch arch {
+	case "amd64", "x86_64", "x64":
+		return "x86_64"
+	case "arm64", "aarch64":
+		return "arm64"
+	default:
+		return arch
+	}
+}
+
+// Build a requirement from a foreign relation, the operator is the
+// zps operation name
+func newRequirement(method string, name string, operation string, version string) *action.Requirement {
+	req := action.NewRequirement()
+	req.Name = name
+	req.Method = method
+	req.Operation = operation
+	req.Version = version
+
+	if req.Operation == "" {
+		req.Operation = "ANY"
+	}
+
+	return req
+}
+
+func removeRequirement(reqs []*action.Requirement, remove *action.Requirement) []*action.Requirement {
+	var result []*action.Requirement
+
+	for _, req := range reqs {
+		if req != remove {
+			result = append(result, req)
+		}
+	}
+
+	return result
+}
+
+var scriptRedirect = regexp.MustCompile(`\s*[0-9&]?>>?\s*&?[^\s;|&]+`)
+var scriptAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=\S*$`)
+var scriptPattern = regexp.MustCompile(`^[^\s()]+\)$`)
+var scriptSeparator = regexp.MustCompile(`;|&&|\|\|`)
+
+// Verbs of the systemd helpers, whatever remains on the command line is a unit
+var scriptVerbs = map[string]bool{
+	"daemon-reload": true, "enable": true, "disable": true, "start": true, "stop": true,
+	"restart": true, "try-restart": true, "reload": true, "preset": true, "mask": true,
+	"unmask": true, "purge": true, "update-state": true, "was-enabled": true,
+	"debian-installed": true, "is-enabled": true, "is-active": true,
+}
+
+// Translate a maintainer script that only manages systemd services, as
+// generated by debhelper and the rpm systemd macros. The Service action
+// resolve adds for a shipped unit enables and restarts it on configure
+// and disables it on remove, any other script is reported
+func translateScript(name string, script string, manifest *action.Manifest, report *Report) {
+	services, command := scriptServices(script)
+	if command != "" {
+		report.Add("maintainer script %s not translated, %s has no equivalent", name, command)
+		return
+	}
+
+	for _, service := range services {
+		if !shipsUnit(manifest, service+".service") {
+			report.Add("maintainer script %s not translated, service %s is not shipped", name, service)
+			return
+		}
+	}
+}
+
+// Collect the services a script manages, or the first command that is
+// neither shell control flow nor a systemd helper
+func scriptServices(script string) ([]string, string) {
+	var services []string
+
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = scriptRedirect.ReplaceAllString(line, "")
+
+		for _, statement := range scriptSeparator.Split(line, -1) {
+			statement = strings.TrimSpace(statement)
+			for _, keyword := range []string{"if ", "elif ", "then ", "else ", "! "} {
+				statement = strings.TrimSpace(strings.TrimPrefix(statement, keyword))
+			}
+
+			fields := strings.Fields(strings.NewReplacer("'", "", "\"", "").Replace(statement))
+
+			switch {
+			case len(fields) == 0:
+			case statement == "then" || statement == "else" || statement == "fi" || statement == "esac":
+			case fields[0] == "set" || fields[0] == "exit" || fields[0] == "true" || fields[0] == ":":
+			case fields[0] == "[" || fields[0] == "test":
+			case fields[0] == "case" && fields[len(fields)-1] == "in":
+			case scriptPattern.MatchString(statement) || scriptAssignment.MatchString(statement):
+			case path.Base(fields[0]) == "systemctl" || fields[0] == "deb-systemd-helper" || fields[0] == "deb-systemd-invoke":
+				for _, unit := range fields[1:] {
+					if strings.HasPrefix(unit, "-") || strings.HasPrefix(unit, "$") || scriptVerbs[unit] {
+						continue
+					}
+
+					if strings.Contains(unit, ".") && !strings.HasSuffix(unit, ".service") {
+						return nil, statement
+					}
+
+					services = append(services, strings.TrimSuffix(unit, ".service"))
+				}
+			default:
+				return nil, statement
+			}
+		}
+	}
+
+	return services, ""
+}
+
+// Whether a unit is shipped where resolve looks for systemd services
+func shipsUnit(manifest *action.Manifest, unit string) bool {
+	for _, dir := range []string{"usr/lib/systemd/system/", "lib/systemd/system/"} {
+		file := action.NewFile()
+		file.Path = dir + unit
+
+		if manifest.Exists(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wrap a reader in the decompressor matching the given name
+func decompress(name string, reader io.Reader) (io.Reader, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") || name == "gzip":
+		return gzip.NewReader(reader)
+	case strings.HasSuffix(name, ".xz") || name == "xz" || name == "lzma":
+		return xz.NewReader(reader)
+	case strings.HasSuffix(name, ".zst") || name == "zstd":
+		return zstd.NewReader(reader)
+	case strings.HasSuffix(name, ".bz2") || name == "bzip2":
+		return bzip2.NewReader(reader), nil
+	default:
+		return reader, nil
+	}
+}
+
+// Wrap a reader in a decompressor chosen by magic bytes
+func sniffDecompress(reader io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(reader)
+
+	magic, _ := buffered.Peek(6)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return xz.NewReader(buffered)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return zstd.NewReader(buffered)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(buffered), nil
+	default:
+		return buffered, nil
+	}
+}
+
+// Clean an archive member path, returns false if it escapes the stage
+func cleanPath(name string) (string, bool) {
+	cleaned := path.Clean("/" + name)
+	cleaned = strings.TrimPrefix(cleaned, "/")
+
+	if cleaned == "" || cleaned == "." {
+		return "", false
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+
+	return cleaned, true
+}
+
+// Normalize an octal mode to the form used by the providers,
+// special bits are reported as they are not supported
+func fileMode(mode int64, objectPath string, report *Report) string {
+	if mode&07000 != 0 {
+		report.Add("special mode bits %#o on %s dropped", mode&07000, objectPath)
+	}
+
+	return fmt.Sprintf("%#o", os.FileMode(mode).Perm())
+}
+
+// Extract a tar stream into the stage path, adding FS objects to the manifest
+func extractTar(reader io.Reader, stagePath string, manifest *action.Manifest, report *Report) error {
+	tr := tar.NewReader(reader)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		objectPath, ok := cleanPath(hdr.Name)
+		if !ok {
+			if strings.Trim(hdr.Name, "./") != "" {
+				report.Add("unsafe path %s skipped", hdr.Name)
+			}
+			continue
+		}
+
+		target, err := stageTarget(stagePath, objectPath)
+		if err == errUnsafePath {
+			report.Add("unsafe path %s skipped", hdr.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		owner, group := owners(hdr.Uname, hdr.Uid, hdr.Gname, hdr.Gid, objectPath, report)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = writeDir(target)
+			if err == errUnsafePath {
+				report.Add("unsafe path %s skipped", hdr.Name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			dir := action.NewDir()
+			dir.Path = objectPath
+			dir.Owner = owner
+			dir.Group = group
+			dir.Mode = fileMode(hdr.Mode, objectPath, report)
+			manifest.Add(dir)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(target, tr)
+			if err != nil {
+				return err
+			}
+
+			file := action.NewFile()
+			file.Path = objectPath
+			file.Owner = owner
+			file.Group = group
+			file.Mode = fileMode(hdr.Mode, objectPath, report)
+			manifest.Add(file)
+		case tar.TypeSymlink:
+			err = writeSymLink(target, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+
+			symlink := action.NewSymLink()
+			symlink.Path = objectPath
+			symlink.Owner = owner
+			symlink.Group = group
+			symlink.Target = hdr.Linkname
+			manifest.Add(symlink)
+		case tar.TypeLink:
+			linkPath, ok := cleanPath(hdr.Linkname)
+			if !ok {
+				report.Add("unsafe hard link %s skipped", hdr.Name)
+				continue
+			}
+
+			source, err := stageTarget(stagePath, linkPath)
+			if err == nil {
+				err = copyFile(source, target)
+			}
+			if err == errUnsafePath {
+				report.Add("unsafe hard link %s skipped", hdr.Name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			report.Add("hard link %s to %s copied", objectPath, linkPath)
+
+			file := action.NewFile()
+			file.Path = objectPath
+			file.Owner = owner
+			file.Group = group
+			file.Mode = fileMode(hdr.Mode, objectPath, report)
+			manifest.Add(file)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			report.Add("unsupported object %s skipped", objectPath)
+		}
+	}
+
+	return nil
+}
+
+// Resolve archive owners, numeric ids other than root can't be translated
+func owners(uname string, uid int, gname string, gid int, objectPath string, report *Report) (string, string) {
+	if uname == "" {
+		if uid == 0 {
+			uname = "root"
+		} else {
+			report.Add("numeric owner %d on %s replaced with root", uid, objectPath)
+			uname = "root"
+		}
+	}
+
+	if gname == "" {
+		if gid == 0 {
+			gname = "root"
+		} else {
+			report.Add("numeric group %d on %s replaced with root", gid, objectPath)
+			gname = "root"
+		}
+	}
+
+	return uname, gname
+}
+
+var errUnsafePath = errors.New("importer: path passes through a symlink")
+
+// Join an object path onto the stage path. Writing through a symlink
+// already in the stage could escape it, so parents must be directories
+func stageTarget(stagePath string, objectPath string) (string, error) {
+	target := stagePath
+	parts := strings.Split(objectPath, "/")
+
+	for _, part := range parts[:len(parts)-1] {
+		target = filepath.Join(target, part)
+
+		info, err := os.Lstat(target)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if !info.IsDir() {
+			return "", errUnsafePath
+		}
+	}
+
+	return filepath.Join(stagePath, objectPath), nil
+}
+
+func writeDir(target string) error {
+	info, err := os.Lstat(target)
+	if err == nil && !info.IsDir() {
+		return errUnsafePath
+	}
+
+	return os.MkdirAll(target, 0755)
+}
+
+// Replaces the target rather than writing through an existing symlink
+func writeFile(target string, reader io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, reader)
+
+	return err
+}
+
+func writeSymLink(target string, linkName string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(target)
+
+	return os.Symlink(linkName, target)
+}
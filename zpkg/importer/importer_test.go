@@ -0,0 +1,255 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/fezz-io/zps/action"
+)
+
+func TestScriptServices(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		services []string
+		command  string
+	}{
+		{"debhelper postinst", debPostinst, []string{"foo", "foo", "foo"}, ""},
+		{"debhelper prerm", "#!/bin/sh\nset -e\nif [ -d /run/systemd/system ] && [ \"$1\" = remove ]; then\n\tdeb-systemd-invoke stop 'foo.service' >/dev/null || true\nfi\n", []string{"foo"}, ""},
+		{"rpm systemd_postun", "systemctl daemon-reload >/dev/null 2>&1 || :\nif [ $1 -ge 1 ] ; then\n\tsystemctl try-restart foo.service >/dev/null 2>&1 || :\nfi\n", []string{"foo"}, ""},
+		{"case", "case \"$1\" in\n\tconfigure|abort-upgrade)\n\t\t_action=restart\n\t\tsystemctl $_action bar\n\t;;\n\t*)\n\t;;\nesac\n", []string{"bar"}, ""},
+		{"empty", "#!/bin/sh\n#DEBHELPER#\nexit 0\n", nil, ""},
+		{"ldconfig", "#!/bin/sh\nset -e\nldconfig\n", nil, "ldconfig"},
+		{"user", "if [ \"$1\" = configure ]; then\n\tadduser --system foo\nfi\n", nil, "adduser --system foo"},
+		{"timer", "systemctl enable foo.timer\n", nil, "systemctl enable foo.timer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services, command := scriptServices(test.script)
+
+			if command != test.command {
+				t.Errorf("expected command %q, got %q", test.command, command)
+			}
+			if !reflect.DeepEqual(services, test.services) {
+				t.Errorf("expected services %v, got %v", test.services, services)
+			}
+		})
+	}
+}
+
+func TestTranslateScript(t *testing.T) {
+	manifest := action.NewManifest()
+
+	unit := action.NewFile()
+	unit.Path = "lib/systemd/system/foo.service"
+	manifest.Add(unit)
+
+	report := &Report{}
+	translateScript("postinst", debPostinst, manifest, report)
+	if len(report.Messages) != 0 {
+		t.Errorf("expected postinst to be translated, got %v", report.Messages)
+	}
+
+	translateScript("prerm", "deb-systemd-invoke stop bar.service\n", manifest, report)
+	if !hasMessage(report, "maintainer script prerm not translated, service bar is not shipped") {
+		t.Errorf("expected unshipped service to be reported, got %v", report.Messages)
+	}
+}
+
+func TestProcessOptions(t *testing.T) {
+	host := translateArch(runtime.GOARCH)
+
+	tests := []struct {
+		name     string
+		arch     string
+		override string
+		expected string
+		messages []string
+	}{
+		{"deb all", "all", "", host, []string{"architecture all mapped to " + host + ", override with --arch"}},
+		{"rpm noarch", "noarch", "", host, []string{"architecture noarch mapped to " + host + ", override with --arch"}},
+		{"override", "noarch", "arm64", "arm64", nil},
+		{"native", "x86_64", "", "x86_64", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := NewImporter().Publisher("fezz").Arch(test.override)
+
+			zp := action.NewZpkg()
+			zp.Name = "foo"
+			zp.Version = "1.2.3"
+			zp.Os = "linux"
+			zp.Arch = test.arch
+			i.manifest.Zpkg = zp
+
+			err := i.processOptions(FormatRpm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if i.manifest.Zpkg.Arch != test.expected {
+				t.Errorf("expected arch %s, got %s", test.expected, i.manifest.Zpkg.Arch)
+			}
+			if !reflect.DeepEqual(i.report.Messages, test.messages) {
+				t.Errorf("expected report %v, got %v", test.messages, i.report.Messages)
+			}
+		})
+	}
+}
+
+func TestProcessOptionsSelfRequirements(t *testing.T) {
+	i := NewImporter().Publisher("fezz")
+
+	zp := action.NewZpkg()
+	zp.Name = "foo"
+	zp.Version = "1.2.3"
+	zp.Os = "linux"
+	zp.Arch = "x86_64"
+	i.manifest.Zpkg = zp
+
+	i.manifest.Add(newRequirement("provides", "foo", "EQ", "1.2.3"))
+	i.manifest.Add(newRequirement("conflicts", "foo", "LTE", "1.0.0"))
+	i.manifest.Add(newRequirement("depends", "bar", "ANY", ""))
+
+	err := i.processOptions(FormatRpm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(i.manifest.Requirements) != 1 || i.manifest.Requirements[0].Name != "bar" {
+		t.Errorf("expected only the bar requirement to remain, got %v", i.manifest.Requirements)
+	}
+
+	expected := []string{"conflicts foo names the package itself, dropped"}
+	if !reflect.DeepEqual(i.report.Messages, expected) {
+		t.Errorf("expected report %v, got %v", expected, i.report.Messages)
+	}
+}
+
+func TestCoerceVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+		exact    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2", "1.2.0", true},
+		{"1.2.3.4", "1.2.3", false},
+		{"1.2~rc1", "1.2.0", false},
+		{"abc", "0.0.0", false},
+	}
+
+	for _, test := range tests {
+		version, exact := coerceVersion(test.version)
+		if version != test.expected || exact != test.exact {
+			t.Errorf("%s: expected %s %v, got %s %v", test.version, test.expected, test.exact, version, exact)
+		}
+	}
+}
+
+func TestExtractTarSymlinkEscape(t *testing.T) {
+	host, err := ioutil.TempDir("", "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(host)
+
+	secret := filepath.Join(host, "secret")
+
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+		message string
+	}{
+		{"file through dir symlink", []*tar.Header{
+			{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: host},
+			{Name: "etc/secret", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+		}, "unsafe path etc/secret skipped"},
+		{"dir through dir symlink", []*tar.Header{
+			{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: host},
+			{Name: "etc/sub/", Typeflag: tar.TypeDir, Mode: 0755},
+		}, "unsafe path etc/sub/ skipped"},
+		{"dir over symlink", []*tar.Header{
+			{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: host},
+			{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755},
+		}, "unsafe path etc/ skipped"},
+		{"hard link through dir symlink", []*tar.Header{
+			{Name: "lnk", Typeflag: tar.TypeSymlink, Linkname: host},
+			{Name: "copy", Typeflag: tar.TypeLink, Linkname: "lnk/secret"},
+		}, "unsafe hard link copy skipped"},
+		{"hard link to symlink", []*tar.Header{
+			{Name: "lnk", Typeflag: tar.TypeSymlink, Linkname: secret},
+			{Name: "copy", Typeflag: tar.TypeLink, Linkname: "lnk"},
+		}, "unsafe hard link copy skipped"},
+		{"file over symlink", []*tar.Header{
+			{Name: "x", Typeflag: tar.TypeSymlink, Linkname: secret},
+			{Name: "x", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+		}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.RemoveAll(filepath.Join(host, "sub"))
+			if err := ioutil.WriteFile(secret, []byte("host"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range test.headers {
+				if err := tw.WriteHeader(hdr); err != nil {
+					t.Fatal(err)
+				}
+				io.WriteString(tw, strings.Repeat("x", int(hdr.Size)))
+			}
+			tw.Close()
+
+			stagePath, err := ioutil.TempDir("", "stage")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(stagePath)
+
+			report := &Report{}
+
+			err = extractTar(&buf, stagePath, action.NewManifest(), report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if content, _ := ioutil.ReadFile(secret); string(content) != "host" {
+				t.Errorf("host file modified: %q", content)
+			}
+			if _, err := os.Stat(filepath.Join(host, "sub")); err == nil {
+				t.Error("host directory created")
+			}
+			if _, err := os.Lstat(filepath.Join(stagePath, "copy")); err == nil {
+				t.Error("hard link copied a host file")
+			}
+
+			if test.message != "" && !hasMessage(report, test.message) {
+				t.Errorf("expected report %q, got %v", test.message, report.Messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,662 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fezz-io/zps/action"
+)
+
+var rpmLeadMagic = []byte{0xed, 0xab, 0xee, 0xdb}
+var rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+
+const (
+	rpmTagName            = 1000
+	rpmTagVersion         = 1001
+	rpmTagRelease         = 1002
+	rpmTagEpoch           = 1003
+	rpmTagSummary         = 1004
+	rpmTagDescription     = 1005
+	rpmTagVendor          = 1011
+	rpmTagLicense         = 1014
+	rpmTagPackager        = 1015
+	rpmTagUrl             = 1020
+	rpmTagOs              = 1021
+	rpmTagArch            = 1022
+	rpmTagPreIn           = 1023
+	rpmTagPostIn          = 1024
+	rpmTagPreUn           = 1025
+	rpmTagPostUn          = 1026
+	rpmTagFileUserName    = 1039
+	rpmTagFileGroupName   = 1040
+	rpmTagProvideName     = 1047
+	rpmTagRequireFlags    = 1048
+	rpmTagRequireName     = 1049
+	rpmTagRequireVersion  = 1050
+	rpmTagConflictFlags   = 1053
+	rpmTagConflictName    = 1054
+	rpmTagConflictVersion = 1055
+	rpmTagObsoleteName    = 1090
+	rpmTagProvideFlags    = 1112
+	rpmTagProvideVersion  = 1113
+	rpmTagDirIndexes      = 1116
+	rpmTagBaseNames       = 1117
+	rpmTagDirNames        = 1118
+	rpmTagPayloadFormat   = 1124
+	rpmTagPayloadCompress = 1125
+	rpmTagPreTrans        = 1151
+	rpmTagPostTrans       = 1152
+	rpmTagTriggerScripts  = 1065
+	rpmTagPreInProg       = 1085
+	rpmTagPostInProg      = 1086
+	rpmTagPreUnProg       = 1087
+	rpmTagPostUnProg      = 1088
+	rpmTagPreTransProg    = 1153
+	rpmTagPostTransProg   = 1154
+	rpmTagRecommendName   = 5046
+	rpmTagSuggestName     = 5049
+
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18nString  = 9
+
+	rpmSenseLess    = 0x02
+	rpmSenseGreater = 0x04
+	rpmSenseEqual   = 0x08
+	rpmSenseRpmLib  = 0x01000000
+)
+
+type rpmEntry struct {
+	typ   uint32
+	count uint32
+	data  []byte
+}
+
+type rpmHeader map[uint32]*rpmEntry
+
+func (h rpmHeader) String(tag uint32) string {
+	strs := h.Strings(tag)
+	if len(strs) == 0 {
+		return ""
+	}
+
+	return strs[0]
+}
+
+func (h rpmHeader) Strings(tag uint32) []string {
+	entry, ok := h[tag]
+	if !ok {
+		return nil
+	}
+
+	switch entry.typ {
+	case rpmTypeString, rpmTypeStringArray, rpmTypeI18nString:
+	default:
+		return nil
+	}
+
+	var strs []string
+	data := entry.data
+	for index := uint32(0); index < entry.count; index++ {
+		end := bytes.IndexByte(data, 0)
+		if end == -1 {
+			break
+		}
+		strs = append(strs, string(data[:end]))
+		data = data[end+1:]
+	}
+
+	return strs
+}
+
+func (h rpmHeader) Ints(tag uint32) []int64 {
+	entry, ok := h[tag]
+	if !ok {
+		return nil
+	}
+
+	var ints []int64
+	for index := uint32(0); index < entry.count; index++ {
+		switch entry.typ {
+		case rpmTypeInt16:
+			if len(entry.data) < int(index+1)*2 {
+				return ints
+			}
+			ints = append(ints, int64(binary.BigEndian.Uint16(entry.data[index*2:])))
+		case rpmTypeInt32:
+			if len(entry.data) < int(index+1)*4 {
+				return ints
+			}
+			ints = append(ints, int64(binary.BigEndian.Uint32(entry.data[index*4:])))
+		default:
+			return nil
+		}
+	}
+
+	return ints
+}
+
+type rpm struct {
+	path string
+
+	header rpmHeader
+
+	// Ownership by path from the header file list
+	owners map[string][2]string
+}
+
+func (r *rpm) Load(stagePath string, manifest *action.Manifest, report *Report) error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lead := make([]byte, 96)
+	_, err = io.ReadFull(file, lead)
+	if err != nil || !bytes.Equal(lead[:4], rpmLeadMagic) {
+		return errors.New("importer: not an rpm archive")
+	}
+
+	// Signature header is padded to 8 bytes
+	_, size, err := readRpmHeader(file)
+	if err != nil {
+		return err
+	}
+	if size%8 != 0 {
+		_, err = file.Seek(int64(8-size%8), io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.header, _, err = readRpmHeader(file)
+	if err != nil {
+		return err
+	}
+
+	report.Add("rpm signatures not translated")
+
+	r.files()
+
+	if format := r.header.String(rpmTagPayloadFormat); format != "" && format != "cpio" {
+		return fmt.Errorf("importer: unsupported rpm payload format %s", format)
+	}
+
+	compressor := r.header.String(rpmTagPayloadCompress)
+	if compressor == "" {
+		compressor = "gzip"
+	}
+
+	reader, err := decompress(compressor, file)
+	if err != nil {
+		return err
+	}
+
+	err = r.extract(reader, stagePath, manifest, report)
+	if err != nil {
+		return err
+	}
+
+	return r.translate(manifest, report)
+}
+
+// Read a header structure returning the header and its size in bytes
+func readRpmHeader(reader io.Reader) (rpmHeader, int, error) {
+	intro := make([]byte, 16)
+	_, err := io.ReadFull(reader, intro)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !bytes.Equal(intro[:4], rpmHeaderMagic) {
+		return nil, 0, errors.New("importer: malformed rpm header")
+	}
+
+	count := binary.BigEndian.Uint32(intro[8:12])
+	storeSize := binary.BigEndian.Uint32(intro[12:16])
+
+	if count > 0x10000 || storeSize > 0x10000000 {
+		return nil, 0, errors.New("importer: rpm header too large")
+	}
+
+	index := make([]byte, count*16)
+	_, err = io.ReadFull(reader, index)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	store := make([]byte, storeSize)
+	_, err = io.ReadFull(reader, store)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make(rpmHeader)
+	for entry := uint32(0); entry < count; entry++ {
+		raw := index[entry*16 : entry*16+16]
+
+		tag := binary.BigEndian.Uint32(raw[0:4])
+		typ := binary.BigEndian.Uint32(raw[4:8])
+		offset := binary.BigEndian.Uint32(raw[8:12])
+		num := binary.BigEndian.Uint32(raw[12:16])
+
+		if offset > storeSize {
+			return nil, 0, errors.New("importer: malformed rpm header entry")
+		}
+
+		header[tag] = &rpmEntry{typ, num, store[offset:]}
+	}
+
+	return header, 16 + len(index) + len(store), nil
+}
+
+// Index ownership of the header file list
+func (r *rpm) files() {
+	r.owners = make(map[string][2]string)
+
+	dirs := r.header.Strings(rpmTagDirNames)
+	bases := r.header.Strings(rpmTagBaseNames)
+	indexes := r.header.Ints(rpmTagDirIndexes)
+	users := r.header.Strings(rpmTagFileUserName)
+	groups := r.header.Strings(rpmTagFileGroupName)
+
+	for index, base := range bases {
+		if index >= len(indexes) || int(indexes[index]) >= len(dirs) {
+			break
+		}
+
+		objectPath, ok := cleanPath(dirs[indexes[index]] + base)
+		if !ok {
+			continue
+		}
+
+		if index < len(users) && index < len(groups) {
+			r.owners[objectPath] = [2]string{users[index], groups[index]}
+		}
+	}
+}
+
+// Extract the cpio newc payload
+func (r *rpm) extract(reader io.Reader, stagePath string, manifest *action.Manifest, report *Report) error {
+	// Hard links carry their content on the last entry
+	links := make(map[string][]string)
+
+	var offset int64
+	for {
+		header := make([]byte, 110)
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			return errors.New("importer: truncated rpm payload")
+		}
+		offset += 110
+
+		magic := string(header[0:6])
+		if magic != "070701" && magic != "070702" {
+			return errors.New("importer: unsupported rpm payload archive")
+		}
+
+		field := func(index int) int64 {
+			value, _ := strconv.ParseInt(string(header[6+index*8:14+index*8]), 16, 64)
+			return value
+		}
+
+		ino := field(0)
+		mode := field(1)
+		nlink := field(4)
+		size := field(6)
+		nameSize := field(11)
+
+		name := make([]byte, nameSize)
+		_, err = io.ReadFull(reader, name)
+		if err != nil {
+			return err
+		}
+		offset += nameSize
+
+		err = skip(reader, &offset, 4)
+		if err != nil {
+			return err
+		}
+
+		entryName := strings.TrimRight(string(name), "\x00")
+		if entryName == "TRAILER!!!" {
+			break
+		}
+
+		content := io.LimitReader(reader, size)
+
+		objectPath, ok := cleanPath(entryName)
+		if !ok {
+			report.Add("unsafe path %s skipped", entryName)
+		} else {
+			err = r.object(objectPath, mode, ino, nlink, size, content, links, stagePath, manifest, report)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = io.Copy(ioutil.Discard, content)
+		if err != nil {
+			return err
+		}
+		offset += size
+
+		err = skip(reader, &offset, 4)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *rpm) object(objectPath string, mode int64, ino int64, nlink int64, size int64, content io.Reader,
+	links map[string][]string, stagePath string, manifest *action.Manifest, report *Report) error {
+	target, err := stageTarget(stagePath, objectPath)
+	if err == errUnsafePath {
+		report.Add("unsafe path %s skipped", objectPath)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	owner, group := "root", "root"
+	if names, ok := r.owners[objectPath]; ok {
+		owner, group = names[0], names[1]
+	}
+
+	switch mode & 0170000 {
+	case 0040000:
+		err = writeDir(target)
+		if err == errUnsafePath {
+			report.Add("unsafe path %s skipped", objectPath)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		dir := action.NewDir()
+		dir.Path = objectPath
+		dir.Owner = owner
+		dir.Group = group
+		dir.Mode = fileMode(mode, objectPath, report)
+		manifest.Add(dir)
+	case 0100000:
+		key := strconv.FormatInt(ino, 10)
+
+		if nlink > 1 && size == 0 {
+			links[key] = append(links[key], objectPath)
+		} else {
+			err := writeFile(target, content)
+			if err != nil {
+				return err
+			}
+
+			for _, link := range links[key] {
+				linkTarget, err := stageTarget(stagePath, link)
+				if err == nil {
+					err = copyFile(target, linkTarget)
+				}
+				if err == errUnsafePath {
+					report.Add("unsafe hard link %s skipped", link)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				report.Add("hard link %s to %s copied", link, objectPath)
+			}
+			delete(links, key)
+		}
+
+		file := action.NewFile()
+		file.Path = objectPath
+		file.Owner = owner
+		file.Group = group
+		file.Mode = fileMode(mode, objectPath, report)
+		manifest.Add(file)
+	case 0120000:
+		linkName, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+
+		err = writeSymLink(target, string(linkName))
+		if err != nil {
+			return err
+		}
+
+		symlink := action.NewSymLink()
+		symlink.Path = objectPath
+		symlink.Owner = owner
+		symlink.Group = group
+		symlink.Target = string(linkName)
+		manifest.Add(symlink)
+	default:
+		report.Add("unsupported object %s skipped", objectPath)
+	}
+
+	return nil
+}
+
+// Map header tags onto the manifest
+func (r *rpm) translate(manifest *action.Manifest, report *Report) error {
+	zp := action.NewZpkg()
+
+	zp.Name = r.header.String(rpmTagName)
+	zp.Summary = r.header.String(rpmTagSummary)
+	zp.Description = r.header.String(rpmTagDescription)
+	zp.Arch = translateArch(r.header.String(rpmTagArch))
+	zp.Os = strings.ToLower(r.header.String(rpmTagOs))
+
+	if epoch := r.header.Ints(rpmTagEpoch); len(epoch) > 0 {
+		report.Add("version epoch %d dropped", epoch[0])
+	}
+
+	version := r.header.String(rpmTagVersion)
+	release := r.header.String(rpmTagRelease)
+
+	var exact bool
+	zp.Version, exact = coerceVersion(version)
+	if !exact {
+		report.Add("version %s coerced to %s", version, zp.Version)
+	}
+
+	manifest.Zpkg = zp
+
+	tags := [][2]string{
+		{"zps.import.version", strings.Trim(version+"-"+release, "-")},
+		{"zps.import.vendor", r.header.String(rpmTagVendor)},
+		{"zps.import.packager", r.header.String(rpmTagPackager)},
+		{"zps.import.license", r.header.String(rpmTagLicense)},
+		{"zps.import.homepage", r.header.String(rpmTagUrl)},
+	}
+
+	for _, pair := range tags {
+		if pair[1] == "" {
+			continue
+		}
+
+		tag := action.NewTag()
+		tag.Name = pair[0]
+		tag.Value = pair[1]
+		manifest.Add(tag)
+	}
+
+	relations := []struct {
+		method  string
+		names   uint32
+		flags   uint32
+		version uint32
+	}{
+		{"depends", rpmTagRequireName, rpmTagRequireFlags, rpmTagRequireVersion},
+		{"conflicts", rpmTagConflictName, rpmTagConflictFlags, rpmTagConflictVersion},
+		{"provides", rpmTagProvideName, rpmTagProvideFlags, rpmTagProvideVersion},
+	}
+
+	for _, relation := range relations {
+		names := r.header.Strings(relation.names)
+		flags := r.header.Ints(relation.flags)
+		versions := r.header.Strings(relation.version)
+
+		for index, name := range names {
+			var flag int64
+			var raw string
+
+			if index < len(flags) {
+				flag = flags[index]
+			}
+			if index < len(versions) {
+				raw = versions[index]
+			}
+
+			switch {
+			case flag&rpmSenseRpmLib != 0 || strings.HasPrefix(name, "rpmlib("):
+				continue
+			case strings.HasPrefix(name, "/"):
+				report.Add("%s on path %s not translated", relation.method, name)
+				continue
+			case strings.Contains(name, "("):
+				report.Add("%s %s not translated", relation.method, name)
+				continue
+			}
+
+			operation := ""
+			version := ""
+
+			if raw != "" {
+				if colon := strings.Index(raw, ":"); colon != -1 {
+					raw = raw[colon+1:]
+				}
+				if dash := strings.Index(raw, "-"); dash != -1 {
+					raw = raw[:dash]
+				}
+
+				var exact bool
+				version, exact = coerceVersion(raw)
+				if !exact {
+					report.Add("%s %s version %s coerced to %s", relation.method, name, raw, version)
+				}
+
+				switch flag & (rpmSenseLess | rpmSenseGreater | rpmSenseEqual) {
+				case rpmSenseGreater | rpmSenseEqual:
+					operation = "GTE"
+				case rpmSenseLess | rpmSenseEqual:
+					operation = "LTE"
+				case rpmSenseEqual:
+					operation = "EQ"
+				case rpmSenseGreater:
+					operation = "GTE"
+					report.Add("%s %s strictly greater than %s approximated as >=", relation.method, name, raw)
+				case rpmSenseLess:
+					operation = "LTE"
+					report.Add("%s %s strictly less than %s approximated as <=", relation.method, name, raw)
+				default:
+					version = ""
+				}
+			}
+
+			manifest.Add(newRequirement(relation.method, name, operation, version))
+		}
+	}
+
+	scripts := []struct {
+		name string
+		body uint32
+		prog uint32
+	}{
+		{"%pretrans", rpmTagPreTrans, rpmTagPreTransProg},
+		{"%pre", rpmTagPreIn, rpmTagPreInProg},
+		{"%post", rpmTagPostIn, rpmTagPostInProg},
+		{"%preun", rpmTagPreUn, rpmTagPreUnProg},
+		{"%postun", rpmTagPostUn, rpmTagPostUnProg},
+		{"%posttrans", rpmTagPostTrans, rpmTagPostTransProg},
+	}
+
+	for _, script := range scripts {
+		if _, ok := r.header[script.body]; !ok {
+			continue
+		}
+
+		if prog := r.header.String(script.prog); prog != "" && prog != "/bin/sh" {
+			report.Add("maintainer script %s not translated, interpreter %s not supported", script.name, prog)
+			continue
+		}
+
+		translateScript(script.name, r.header.String(script.body), manifest, report)
+	}
+
+	if _, ok := r.header[rpmTagTriggerScripts]; ok {
+		report.Add("triggers not translated")
+	}
+
+	fields := map[uint32]string{
+		rpmTagObsoleteName:  "Obsoletes",
+		rpmTagRecommendName: "Recommends",
+		rpmTagSuggestName:   "Suggests",
+	}
+
+	for _, tag := range []uint32{rpmTagObsoleteName, rpmTagRecommendName, rpmTagSuggestName} {
+		if _, ok := r.header[tag]; ok {
+			report.Add("%s relationships not translated", fields[tag])
+		}
+	}
+
+	return nil
+}
+
+// Discard padding to the given alignment
+func skip(reader io.Reader, offset *int64, align int64) error {
+	pad := (align - *offset%align) % align
+	if pad == 0 {
+		return nil
+	}
+
+	_, err := io.CopyN(ioutil.Discard, reader, pad)
+	*offset += pad
+
+	return err
+}
+
+// Copy a regular file within the stage, the source must not be a symlink
+func copyFile(source string, target string) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return errUnsafePath
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFile(target, in)
+}
@@ -0,0 +1,385 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fezz-io/zps/action"
+)
+
+type rpmTestEntry struct {
+	tag   uint32
+	typ   uint32
+	count uint32
+	data  []byte
+}
+
+func rpmString(tag uint32, value string) rpmTestEntry {
+	return rpmTestEntry{tag, rpmTypeString, 1, append([]byte(value), 0)}
+}
+
+func rpmStrings(tag uint32, values ...string) rpmTestEntry {
+	var data []byte
+	for _, value := range values {
+		data = append(data, append([]byte(value), 0)...)
+	}
+
+	return rpmTestEntry{tag, rpmTypeStringArray, uint32(len(values)), data}
+}
+
+func rpmInt32s(tag uint32, values ...uint32) rpmTestEntry {
+	data := make([]byte, len(values)*4)
+	for index, value := range values {
+		binary.BigEndian.PutUint32(data[index*4:], value)
+	}
+
+	return rpmTestEntry{tag, rpmTypeInt32, uint32(len(values)), data}
+}
+
+// Build a header structure, the store is laid out in entry order
+func rpmHeaderBytes(entries ...rpmTestEntry) []byte {
+	var index, store bytes.Buffer
+
+	for _, entry := range entries {
+		binary.Write(&index, binary.BigEndian, []uint32{entry.tag, entry.typ, uint32(store.Len()), entry.count})
+		store.Write(entry.data)
+	}
+
+	var header bytes.Buffer
+	header.Write(rpmHeaderMagic)
+	header.Write([]byte{0, 0, 0, 0})
+	binary.Write(&header, binary.BigEndian, []uint32{uint32(len(entries)), uint32(store.Len())})
+	header.Write(index.Bytes())
+	header.Write(store.Bytes())
+
+	return header.Bytes()
+}
+
+type cpioTestEntry struct {
+	name    string
+	mode    int64
+	ino     int64
+	nlink   int64
+	content string
+}
+
+// Build a newc payload, names and content are padded to 4 bytes
+func cpioArchive(entries ...cpioTestEntry) []byte {
+	var buf bytes.Buffer
+
+	pad := func() {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+
+	entries = append(entries, cpioTestEntry{name: "TRAILER!!!", nlink: 1})
+
+	for _, entry := range entries {
+		fields := []int64{entry.ino, entry.mode, 0, 0, entry.nlink, 0, int64(len(entry.content)), 0, 0, 0, 0, int64(len(entry.name) + 1), 0}
+
+		buf.WriteString("070701")
+		for _, field := range fields {
+			fmt.Fprintf(&buf, "%08x", field)
+		}
+		buf.WriteString(entry.name + "\x00")
+		pad()
+		buf.WriteString(entry.content)
+		pad()
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadRpmHeader(t *testing.T) {
+	raw := rpmHeaderBytes(
+		rpmString(rpmTagName, "foo"),
+		rpmStrings(rpmTagBaseNames, "foo", "bar"),
+		rpmInt32s(rpmTagDirIndexes, 0, 1),
+		rpmTestEntry{rpmTagRequireFlags, rpmTypeInt16, 2, []byte{0, 8, 0, 12}},
+		rpmTestEntry{rpmTagSummary, rpmTypeI18nString, 1, []byte("Foo tool\x00")},
+	)
+
+	header, size, err := readRpmHeader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != len(raw) {
+		t.Errorf("expected size %d, got %d", len(raw), size)
+	}
+
+	if header.String(rpmTagName) != "foo" {
+		t.Errorf("expected name foo, got %q", header.String(rpmTagName))
+	}
+	if header.String(rpmTagSummary) != "Foo tool" {
+		t.Errorf("expected summary, got %q", header.String(rpmTagSummary))
+	}
+	if !reflect.DeepEqual(header.Strings(rpmTagBaseNames), []string{"foo", "bar"}) {
+		t.Errorf("unexpected base names %v", header.Strings(rpmTagBaseNames))
+	}
+	if !reflect.DeepEqual(header.Ints(rpmTagDirIndexes), []int64{0, 1}) {
+		t.Errorf("unexpected dir indexes %v", header.Ints(rpmTagDirIndexes))
+	}
+	if !reflect.DeepEqual(header.Ints(rpmTagRequireFlags), []int64{8, 12}) {
+		t.Errorf("unexpected int16 flags %v", header.Ints(rpmTagRequireFlags))
+	}
+
+	// Mismatched accessors and missing tags are empty
+	if header.Strings(rpmTagDirIndexes) != nil || header.Ints(rpmTagName) != nil || header.String(rpmTagVersion) != "" {
+		t.Error("expected mismatched and missing tags to be empty")
+	}
+}
+
+func TestReadRpmHeaderMalformed(t *testing.T) {
+	valid := rpmHeaderBytes(rpmString(rpmTagName, "foo"))
+
+	badMagic := append([]byte{}, valid...)
+	badMagic[0] = 0
+
+	badOffset := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badOffset[16+8:], 0xffff)
+
+	tooLarge := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(tooLarge[8:], 0x20000)
+
+	tests := []struct {
+		name string
+		raw  []byte
+		err  string
+	}{
+		{"magic", badMagic, "importer: malformed rpm header"},
+		{"offset", badOffset, "importer: malformed rpm header entry"},
+		{"count", tooLarge, "importer: rpm header too large"},
+		{"truncated", valid[:len(valid)-2], "unexpected EOF"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := readRpmHeader(bytes.NewReader(test.raw))
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRpmExtract(t *testing.T) {
+	stagePath, err := ioutil.TempDir("", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stagePath)
+
+	payload := cpioArchive(
+		cpioTestEntry{"./usr", 040755, 1, 2, ""},
+		cpioTestEntry{"./usr/bin/foo", 0100755, 2, 1, "foo"},
+		cpioTestEntry{"./usr/bin/foo-link", 0120777, 3, 1, "foo"},
+		cpioTestEntry{"./usr/bin/hard-a", 0100644, 4, 2, ""},
+		cpioTestEntry{"./usr/bin/hard-b", 0100644, 4, 2, "shared"},
+		cpioTestEntry{"../escape", 0100644, 5, 1, "x"},
+	)
+
+	r := &rpm{owners: map[string][2]string{"usr/bin/foo": {"foo", "bar"}}}
+	manifest := action.NewManifest()
+	report := &Report{}
+
+	err = r.extract(bytes.NewReader(payload), stagePath, manifest, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"usr/bin/foo": "foo", "usr/bin/hard-a": "shared", "usr/bin/hard-b": "shared"} {
+		content, err := ioutil.ReadFile(filepath.Join(stagePath, name))
+		if err != nil || string(content) != expected {
+			t.Errorf("%s: expected %q, got %q %v", name, expected, content, err)
+		}
+	}
+
+	if target, err := os.Readlink(filepath.Join(stagePath, "usr/bin/foo-link")); err != nil || target != "foo" {
+		t.Errorf("expected symlink to foo, got %q %v", target, err)
+	}
+
+	file := manifest.Section("File")[0].(*action.File)
+	if file.Path != "usr/bin/foo" || file.Owner != "foo" || file.Group != "bar" || file.Mode != "0755" {
+		t.Errorf("unexpected file %s %s %s %s", file.Path, file.Owner, file.Group, file.Mode)
+	}
+
+	for _, message := range []string{"hard link usr/bin/hard-a to usr/bin/hard-b copied", "unsafe path ../escape skipped"} {
+		if !hasMessage(report, message) {
+			t.Errorf("expected report %q, got %v", message, report.Messages)
+		}
+	}
+}
+
+func TestRpmExtractSymlinkEscape(t *testing.T) {
+	host, err := ioutil.TempDir("", "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(host)
+
+	stagePath, err := ioutil.TempDir("", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stagePath)
+
+	payload := cpioArchive(
+		cpioTestEntry{"./etc/hard-a", 0100644, 4, 2, ""},
+		cpioTestEntry{"./etc", 0120777, 1, 1, host},
+		cpioTestEntry{"./etc/secret", 0100644, 2, 1, "x"},
+		cpioTestEntry{"./etc/sub", 040755, 3, 2, ""},
+		cpioTestEntry{"./usr/hard-b", 0100644, 4, 2, "shared"},
+	)
+
+	report := &Report{}
+
+	err = (&rpm{}).extract(bytes.NewReader(payload), stagePath, action.NewManifest(), report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := ioutil.ReadDir(host)
+	if len(entries) != 0 {
+		t.Errorf("expected nothing written to the host, got %d entries", len(entries))
+	}
+
+	for _, message := range []string{"unsafe path etc/secret skipped", "unsafe path etc/sub skipped", "unsafe hard link etc/hard-a skipped"} {
+		if !hasMessage(report, message) {
+			t.Errorf("expected report %q, got %v", message, report.Messages)
+		}
+	}
+}
+
+func TestRpmExtractMalformed(t *testing.T) {
+	payload := cpioArchive(cpioTestEntry{"./usr/bin/foo", 0100755, 1, 1, "foo"})
+
+	odc := append([]byte{}, payload...)
+	copy(odc, "070707")
+
+	tests := []struct {
+		name    string
+		payload []byte
+		err     string
+	}{
+		{"magic", odc, "importer: unsupported rpm payload archive"},
+		{"no trailer", payload[:112+16], "importer: truncated rpm payload"},
+		{"truncated header", payload[:50], "importer: truncated rpm payload"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stagePath, err := ioutil.TempDir("", "stage")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(stagePath)
+
+			err = (&rpm{}).extract(bytes.NewReader(test.payload), stagePath, action.NewManifest(), &Report{})
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRpmLoad(t *testing.T) {
+	var payload bytes.Buffer
+	gz := gzip.NewWriter(&payload)
+	gz.Write(cpioArchive(
+		cpioTestEntry{"./usr/bin/foo", 0100755, 1, 1, "foo"},
+		cpioTestEntry{"./usr/lib/systemd/system/foo.service", 0100644, 2, 1, "[Service]\n"},
+	))
+	gz.Close()
+
+	postIn := "if [ $1 -eq 1 ] ; then\n\tsystemctl --no-reload preset foo.service &>/dev/null || :\nfi\n"
+
+	lead := make([]byte, 96)
+	copy(lead, rpmLeadMagic)
+
+	var fixture bytes.Buffer
+	fixture.Write(lead)
+
+	// A 5 byte signature store is padded to 8 bytes
+	fixture.Write(rpmHeaderBytes(rpmTestEntry{1000, rpmTypeString, 1, []byte("sig\x00\x00")}))
+	fixture.Write([]byte{0, 0, 0})
+
+	fixture.Write(rpmHeaderBytes(
+		rpmString(rpmTagName, "foo"),
+		rpmString(rpmTagVersion, "1.2"),
+		rpmString(rpmTagRelease, "3.el8"),
+		rpmString(rpmTagSummary, "Foo tool"),
+		rpmString(rpmTagOs, "Linux"),
+		rpmString(rpmTagArch, "noarch"),
+		rpmStrings(rpmTagRequireName, "rpmlib(CompressedFileNames)", "bar", "/bin/sh"),
+		rpmInt32s(rpmTagRequireFlags, rpmSenseRpmLib|rpmSenseLess|rpmSenseEqual, rpmSenseGreater|rpmSenseEqual, 0),
+		rpmStrings(rpmTagRequireVersion, "3.0.4-1", "1:2.0-1", ""),
+		rpmStrings(rpmTagProvideName, "foo"),
+		rpmInt32s(rpmTagProvideFlags, rpmSenseEqual),
+		rpmStrings(rpmTagProvideVersion, "1.2-3.el8"),
+		rpmString(rpmTagPostIn, postIn),
+		rpmString(rpmTagPostInProg, "/bin/sh"),
+		rpmString(rpmTagPreUn, "print('hi')"),
+		rpmString(rpmTagPreUnProg, "<lua>"),
+		rpmString(rpmTagPayloadFormat, "cpio"),
+		rpmString(rpmTagPayloadCompress, "gzip"),
+	))
+	fixture.Write(payload.Bytes())
+
+	path, cleanup := writeFixture(t, "foo.rpm", fixture.Bytes())
+	defer cleanup()
+
+	manifest := action.NewManifest()
+	report := &Report{}
+
+	err := (&rpm{path: path}).Load(filepath.Dir(path), manifest, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zp := manifest.Zpkg
+	if zp.Name != "foo" || zp.Version != "1.2.0" || zp.Os != "linux" || zp.Arch != "noarch" {
+		t.Errorf("unexpected zpkg %s %s %s %s", zp.Name, zp.Version, zp.Os, zp.Arch)
+	}
+
+	var reqs []string
+	for _, req := range manifest.Requirements {
+		reqs = append(reqs, strings.Join([]string{req.Method, req.Name, req.Operation, req.Version}, " "))
+	}
+	if strings.Join(reqs, ",") != "depends bar GTE 2.0.0,provides foo EQ 1.2.0" {
+		t.Errorf("unexpected requirements %v", reqs)
+	}
+
+	for _, message := range []string{
+		"depends on path /bin/sh not translated",
+		"maintainer script %preun not translated, interpreter <lua> not supported",
+	} {
+		if !hasMessage(report, message) {
+			t.Errorf("expected report %q, got %v", message, report.Messages)
+		}
+	}
+
+	for _, message := range report.Messages {
+		if strings.Contains(message, "%post ") || strings.Contains(message, "rpmlib") {
+			t.Errorf("unexpected report %q", message)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package importer
+
+import (
+	"os"
+
+	"github.com/fezz-io/zps/action"
+)
+
+// Plain tarballs carry no package metadata, name, version and
+// publisher are expected to be supplied as overrides
+type tarball struct {
+	path string
+}
+
+func (t *tarball) Load(stagePath string, manifest *action.Manifest, report *Report) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := sniffDecompress(file)
+	if err != nil {
+		return err
+	}
+
+	return extractTar(reader, stagePath, manifest, report)
+}
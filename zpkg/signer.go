@@ -12,7 +12,6 @@ package zpkg
 
 import (
 	"bufio"
-	"crypto/rsa"
 	"io"
 	"os"
 
@@ -33,7 +32,7 @@ func NewSigner(path string, workPath string) *Signer {
 	return signer
 }
 
-func (s *Signer) Sign(fingerprint string, key *rsa.PrivateKey) error {
+func (s *Signer) Sign(signer sec.Signer) error {
 	err := s.reader.Read()
 	if err != nil {
 		return err
@@ -43,7 +42,7 @@ func (s *Signer) Sign(fingerprint string, key *rsa.PrivateKey) error {
 	content = []byte(s.reader.Manifest.ToSigningJson())
 
 	// Get signature action
-	sigAction, err := sec.SecuritySignBytes(&content, fingerprint, key, "sha256")
+	sigAction, err := sec.SecuritySignBytes(&content, signer, sec.DefaultDigestMethod)
 	if err != nil {
 		return err
 	}
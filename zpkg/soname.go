@@ -0,0 +1,127 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package zpkg
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/fezz-io/zps/action"
+)
+
+const (
+	SonameDependsAdd     = "add"
+	SonameDependsSuggest = "suggest"
+	SonameDependsOff     = "off"
+)
+
+// Sonames shipped and needed by the ELF objects in a manifest
+type sonames struct {
+	provides map[string]bool
+	needs    map[string][]string
+	needed   map[string]bool
+}
+
+func scanSonames(targetPath string, manifest *action.Manifest) *sonames {
+	found := &sonames{
+		provides: make(map[string]bool),
+		needs:    make(map[string][]string),
+		needed:   make(map[string]bool),
+	}
+
+	for _, file := range manifest.Files {
+		obj, err := elf.Open(filepath.Join(targetPath, file.Path))
+		if err != nil {
+			continue
+		}
+
+		if obj.Type == elf.ET_DYN {
+			names, _ := obj.DynString(elf.DT_SONAME)
+			for _, name := range names {
+				found.provides[name] = true
+			}
+		}
+
+		needed, _ := obj.ImportedLibraries()
+		for _, name := range needed {
+			found.needs[name] = append(found.needs[name], file.Path)
+			found.needed[name] = true
+		}
+
+		obj.Close()
+	}
+
+	return found
+}
+
+// Adds provides for shipped sonames and depends, or suggestions,
+// for sonames needed from other packages
+func (b *Builder) sonames() error {
+	switch b.sonameDepends {
+	case "", SonameDependsSuggest, SonameDependsAdd, SonameDependsOff:
+	default:
+		return errors.New("zpkg: unknown soname depends mode " + b.sonameDepends)
+	}
+
+	for _, manifest := range b.manifests() {
+		found := scanSonames(b.options.TargetPath, manifest)
+
+		for _, name := range sortedKeys(found.provides) {
+			req := action.NewRequirement()
+			req.Name = name
+			req.Method = "provides"
+			req.Operation = "ANY"
+
+			if !manifest.Exists(req) {
+				manifest.Add(req)
+			}
+		}
+
+		if b.sonameDepends == SonameDependsOff {
+			continue
+		}
+
+		for _, name := range sortedKeys(found.needed) {
+			req := action.NewRequirement()
+			req.Name = name
+			req.Method = "depends"
+			req.Operation = "ANY"
+
+			if found.provides[name] || manifest.Exists(req) {
+				continue
+			}
+
+			if b.sonameDepends == SonameDependsAdd {
+				manifest.Add(req)
+				continue
+			}
+
+			b.Emit("action.warn", fmt.Sprintf("%s: %s needs %s, consider Requirement \"%s\"",
+				manifest.Zpkg.Name, found.needs[name][0], name, name))
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(values map[string]bool) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
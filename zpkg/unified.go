@@ -0,0 +1,233 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package zpkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	diffContext = 3
+
+	// Edit distance beyond which content is shown as fully replaced
+	maxDiffEdits = 2000
+)
+
+type lineEdit struct {
+	op   byte
+	line string
+}
+
+// Reports whether content looks like text, NUL bytes or invalid UTF-8 mark binary
+func IsText(content []byte) bool {
+	sniff := content
+	if len(sniff) > 8000 {
+		sniff = sniff[:8000]
+	}
+
+	return bytes.IndexByte(sniff, 0) == -1 && utf8.Valid(content)
+}
+
+// Unified diff of two texts, empty when they are equal
+func UnifiedDiff(fromName string, toName string, from []byte, to []byte) string {
+	edits := lineEdits(splitLines(from), splitLines(to))
+
+	// Line counts preceding each edit
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if edit.op != '+' {
+			aPos[i+1]++
+		}
+		if edit.op != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while changes are separated by little context
+		end := i
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+
+			if run == len(edits) || run-end > 2*diffContext {
+				end += diffContext
+				if end > run {
+					end = run
+				}
+				break
+			}
+
+			end = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]-aPos[start]), hunkRange(bPos[start], bPos[end]-bPos[start]))
+
+		for _, edit := range edits[start:end] {
+			out.WriteByte(edit.op)
+			out.WriteString(edit.line)
+
+			if !strings.HasSuffix(edit.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return out.String()
+}
+
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// Shortest edit script using Myers' algorithm
+func lineEdits(a []string, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= n+m && d <= maxDiffEdits && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		var edits []lineEdit
+		for _, line := range a {
+			edits = append(edits, lineEdit{'-', line})
+		}
+		for _, line := range b {
+			edits = append(edits, lineEdit{'+', line})
+		}
+
+		return edits
+	}
+
+	// Walk the trace backwards, trace[d] holds v before round d on diagonals -d..d
+	var edits []lineEdit
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		prev := func(k int) int {
+			return trace[d][k+d]
+		}
+
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := 0
+		if d > 0 {
+			prevX = prev(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, lineEdit{' ', a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, lineEdit{'+', b[y-1]})
+			} else {
+				edits = append(edits, lineEdit{'-', a[x-1]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
@@ -57,6 +57,10 @@ func (c *Cache) GetConfigSig(uri string) string {
 	return filepath.Join(c.path, fmt.Sprint(c.getId(uri), ".config.sig"))
 }
 
+func (c *Cache) GetCrl(uri string) string {
+	return filepath.Join(c.path, fmt.Sprint(c.getId(uri), ".crl.pem"))
+}
+
 func (c *Cache) GetMeta(osarch string, uri string) string {
 	return filepath.Join(c.path, fmt.Sprint(c.getId(uri), "-", osarch, ".metadata.db"))
 }
@@ -65,6 +69,20 @@ func (c *Cache) GetMetaSig(osarch string, uri string) string {
 	return filepath.Join(c.path, fmt.Sprint(c.getId(uri), "-", osarch, ".metadata.sig"))
 }
 
+// Fetched metadata is staged until validated
+func (c *Cache) GetMetaStaged(osarch string, uri string) string {
+	return c.GetMeta(osarch, uri) + ".staged"
+}
+
+func (c *Cache) GetMetaSigStaged(osarch string, uri string) string {
+	return c.GetMetaSig(osarch, uri) + ".staged"
+}
+
+// Highest metadata version seen for a repo, kept across cache clears
+func (c *Cache) GetMetaVersion(osarch string, uri string) string {
+	return filepath.Join(c.path, fmt.Sprint(c.getId(uri), "-", osarch, ".metadata.version"))
+}
+
 func (c *Cache) GetFile(name string) string {
 	return filepath.Join(c.path, name)
 }
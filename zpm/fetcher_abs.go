@@ -33,6 +33,7 @@ type ABSFetcher struct {
 
 	cache    *Cache
 	security Security
+	stale    bool
 
 	account         string
 	container       string
@@ -41,7 +42,7 @@ type ABSFetcher struct {
 	containerClient *containers.Client
 }
 
-func NewABSFetcher(uri *url.URL, cache *Cache, security Security) *ABSFetcher {
+func NewABSFetcher(uri *url.URL, cache *Cache, security Security, stale bool) *ABSFetcher {
 	authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource("https://storage.azure.com/")
 	if err != nil {
 		authorizer, err = auth.NewAuthorizerFromCLIWithResource("https://storage.azure.com/")
@@ -60,6 +61,7 @@ func NewABSFetcher(uri *url.URL, cache *Cache, security Security) *ABSFetcher {
 		uri,
 		cache,
 		security,
+		stale,
 		cloud.AzureStorageAccountFromURL(uri),
 		cloud.AzureBlobContainerFromURL(uri),
 		cloud.AzureBlobObjectPrefixFromURL(uri),
@@ -97,6 +99,20 @@ func (a *ABSFetcher) Refresh() error {
 
 			return err
 		}
+
+		// Fetch CRL if published
+		cdst := a.cache.GetCrl(a.uri.String())
+		os.Remove(cdst)
+
+		err = a.chunkedGet(path.Join(a.path, "crl.pem"), cdst)
+		if err != nil {
+			os.Remove(cdst)
+		}
+
+		err = importCrl(a.security, cdst)
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, osarch := range zps.Platforms() {
@@ -174,8 +190,18 @@ func (a *ABSFetcher) Keys() ([][]string, error) {
 
 func (a *ABSFetcher) refresh(osarch *zps.OsArch) error {
 	var err error
-	target := path.Join(a.path, osarch.String(), "metadata.db")
-	dst := a.cache.GetMeta(osarch.String(), a.uri.String())
+
+	generation, err := absMetadataPointer(a.blobClient, a.account, a.container, path.Join(a.path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	metadataName, sigName := MetadataFiles(generation)
+
+	target := path.Join(a.path, osarch.String(), metadataName)
+	dst := a.cache.GetMetaStaged(osarch.String(), a.uri.String())
+
+	os.Remove(dst)
 
 	err = a.chunkedGet(target, dst)
 	if err != nil {
@@ -188,8 +214,8 @@ func (a *ABSFetcher) refresh(osarch *zps.OsArch) error {
 	}
 
 	if a.security.Mode() != SecurityModeNone {
-		starget := path.Join(a.path, osarch.String(), "metadata.sig")
-		sdst := a.cache.GetMetaSig(osarch.String(), a.uri.String())
+		starget := path.Join(a.path, osarch.String(), sigName)
+		sdst := a.cache.GetMetaSigStaged(osarch.String(), a.uri.String())
 
 		err = a.chunkedGet(starget, sdst)
 		if err != nil {
@@ -200,23 +226,13 @@ func (a *ABSFetcher) refresh(osarch *zps.OsArch) error {
 				return nil
 			}
 		}
-
-		// Validate metadata signature
-		err = ValidateFileSignature(a.security, a.cache.GetMeta(osarch.String(), a.uri.String()), a.cache.GetMetaSig(osarch.String(), a.uri.String()))
-		if err != nil {
-			// Remove the config and sig if validation fails
-			os.Remove(a.cache.GetMeta(osarch.String(), a.uri.String()))
-			os.Remove(a.cache.GetMetaSig(osarch.String(), a.uri.String()))
-
-			return err
-		}
 	}
 
-	return nil
+	return commitMetadata(a.cache, a.security, a.uri, osarch, a.stale)
 }
 
 func (a *ABSFetcher) chunkedGet(source, dest string) error {
-	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE, 0640)
+	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
 		return err
 	}
@@ -252,3 +268,19 @@ func (a *ABSFetcher) chunkedGet(source, dest string) error {
 
 	return nil
 }
+
+// Reads the metadata pointer below prefix, empty for repos without one
+func absMetadataPointer(client *blobs.Client, account string, container string, prefix string) (string, error) {
+	target := path.Join(prefix, MetadataPointer)
+
+	object, err := client.Get(context.Background(), account, container, target, blobs.GetInput{})
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return "", nil
+		}
+
+		return "", errors.New(fmt.Sprintf("unable to download: %s", target))
+	}
+
+	return ParseMetadataPointer(object.Contents)
+}
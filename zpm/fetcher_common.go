@@ -13,7 +13,9 @@ package zpm
 import (
 	"fmt"
 	"github.com/fezz-io/zps/cloud"
+	"io/ioutil"
 	"net/url"
+	"os"
 
 	"github.com/fezz-io/zps/zps"
 )
@@ -24,28 +26,30 @@ type Fetcher interface {
 	Keys() ([][]string, error)
 }
 
-func NewFetcher(uri *url.URL, cache *Cache, security Security, cloudProvider string) Fetcher {
+// Stale allows refreshing expired metadata, metadata older than the version
+// previously seen is always refused
+func NewFetcher(uri *url.URL, cache *Cache, security Security, cloudProvider string, stale bool) Fetcher {
 	switch uri.Scheme {
 	case "file":
-		return NewFileFetcher(uri, cache, security)
+		return NewFileFetcher(uri, cache, security, stale)
 	case "https":
-		return NewHttpsFetcher(uri, cache, security)
+		return NewHttpsFetcher(uri, cache, security, stale)
 	case "local":
 		return NewLocalFetcher(uri, cache, security)
 	case "abs":
-		return NewABSFetcher(uri, cache, security)
+		return NewABSFetcher(uri, cache, security, stale)
 	case "gcs":
-		return NewGCSFetcher(uri, cache, security)
+		return NewGCSFetcher(uri, cache, security, stale)
 	case "s3":
-		return NewS3Fetcher(uri, cache, security)
+		return NewS3Fetcher(uri, cache, security, stale)
 	case "cloud":
 		switch cloudProvider {
 		case cloud.AWS:
-			return NewS3Fetcher(uri, cache, security)
+			return NewS3Fetcher(uri, cache, security, stale)
 		case cloud.GCP:
-			return NewGCSFetcher(uri, cache, security)
+			return NewGCSFetcher(uri, cache, security, stale)
 		case cloud.Azure:
-			return NewABSFetcher(uri, cache, security)
+			return NewABSFetcher(uri, cache, security, stale)
 		default:
 			return nil
 		}
@@ -54,6 +58,71 @@ func NewFetcher(uri *url.URL, cache *Cache, security Security, cloudProvider str
 	}
 }
 
+// Imports a CRL published alongside config.db, repos without one and CRLs
+// from issuers outside the local trust are skipped
+func importCrl(security Security, crlPath string) error {
+	content, err := ioutil.ReadFile(crlPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	_, _, err = security.Crl(&content)
+	if err == ErrCrlIssuerUntrusted {
+		return nil
+	}
+
+	return err
+}
+
+// Validates metadata staged by a fetcher before it replaces the cached copy,
+// which is kept when validation fails. The version of committed metadata
+// is recorded so a later refresh can not roll it back
+func commitMetadata(cache *Cache, security Security, uri *url.URL, osarch *zps.OsArch, stale bool) error {
+	staged := cache.GetMetaStaged(osarch.String(), uri.String())
+	stagedSig := cache.GetMetaSigStaged(osarch.String(), uri.String())
+	defer os.Remove(staged)
+	defer os.Remove(stagedSig)
+
+	if security.Mode() != SecurityModeNone {
+		err := ValidateFileSignature(security, staged, stagedSig)
+		if err != nil {
+			return err
+		}
+	}
+
+	info, err := NewMetadata(staged).Info()
+	if err != nil {
+		return err
+	}
+
+	err = checkMetadata(cache, uri, osarch, NewMetadata(staged), false)
+	if expired, ok := err.(*StaleMetadataError); ok && expired.Expired && stale {
+		err = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if security.Mode() != SecurityModeNone {
+		err = os.Rename(stagedSig, cache.GetMetaSig(osarch.String(), uri.String()))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Rename(staged, cache.GetMeta(osarch.String(), uri.String()))
+	if err != nil {
+		return err
+	}
+
+	return recordMetadataVersion(cache, uri, osarch, info.Version)
+}
+
 func SafeURI(uri *url.URL) string {
 	return fmt.Sprintf("%s://%s%s", uri.Scheme, uri.Host, uri.Path)
 }
\ No newline at end of file
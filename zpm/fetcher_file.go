@@ -29,10 +29,11 @@ type FileFetcher struct {
 
 	cache    *Cache
 	security Security
+	stale    bool
 }
 
-func NewFileFetcher(uri *url.URL, cache *Cache, security Security) *FileFetcher {
-	return &FileFetcher{uri, cache, security}
+func NewFileFetcher(uri *url.URL, cache *Cache, security Security, stale bool) *FileFetcher {
+	return &FileFetcher{uri, cache, security, stale}
 }
 
 func (f *FileFetcher) Refresh() error {
@@ -49,7 +50,7 @@ func (f *FileFetcher) Refresh() error {
 	}
 	defer srcCfg.Close()
 
-	dstCfg, err := os.OpenFile(f.cache.GetConfig(f.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+	dstCfg, err := os.OpenFile(f.cache.GetConfig(f.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
 		return err
 	}
@@ -73,7 +74,7 @@ func (f *FileFetcher) Refresh() error {
 		}
 		defer srcSig.Close()
 
-		destSig, err := os.OpenFile(f.cache.GetConfigSig(f.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+		destSig, err := os.OpenFile(f.cache.GetConfigSig(f.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 		if err != nil {
 			return err
 		}
@@ -91,6 +92,11 @@ func (f *FileFetcher) Refresh() error {
 
 			return err
 		}
+
+		err = importCrl(f.security, filepath.Join(f.uri.Path, "crl.pem"))
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, osarch := range zps.Platforms() {
@@ -161,16 +167,10 @@ func (f *FileFetcher) Keys() ([][]string, error) {
 func (f *FileFetcher) refresh(osarch *zps.OsArch) error {
 	var err error
 
-	metadataFile := filepath.Join(f.uri.Path, osarch.String(), "metadata.db")
-
 	if _, err = os.Stat(filepath.Join(f.uri.Path, osarch.String())); os.IsNotExist(err) {
 		return nil
 	}
 
-	if _, err = os.Stat(metadataFile); os.IsNotExist(err) {
-		return nil
-	}
-
 	lock, err := lockfile.New(filepath.Join(f.uri.Path, osarch.String(), ".lock"))
 	if err != nil {
 		return err
@@ -182,6 +182,18 @@ func (f *FileFetcher) refresh(osarch *zps.OsArch) error {
 	}
 	defer lock.Unlock()
 
+	generation, err := readMetadataPointer(filepath.Join(f.uri.Path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	metadataName, sigName := MetadataFiles(generation)
+	metadataFile := filepath.Join(f.uri.Path, osarch.String(), metadataName)
+
+	if _, err = os.Stat(metadataFile); os.IsNotExist(err) {
+		return nil
+	}
+
 	if err == nil {
 		// Fetch meta
 		srcMeta, err := os.Open(metadataFile)
@@ -190,7 +202,7 @@ func (f *FileFetcher) refresh(osarch *zps.OsArch) error {
 		}
 		defer srcMeta.Close()
 
-		dstMeta, err := os.OpenFile(f.cache.GetMeta(osarch.String(), f.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+		dstMeta, err := os.OpenFile(f.cache.GetMetaStaged(osarch.String(), f.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 		if err != nil {
 			return err
 		}
@@ -201,7 +213,7 @@ func (f *FileFetcher) refresh(osarch *zps.OsArch) error {
 		}
 
 		if f.security.Mode() != SecurityModeNone {
-			metadataSig := filepath.Join(f.uri.Path, osarch.String(), "metadata.sig")
+			metadataSig := filepath.Join(f.uri.Path, osarch.String(), sigName)
 
 			// Fetch meta sig
 			srcSig, err := os.Open(metadataSig)
@@ -210,7 +222,7 @@ func (f *FileFetcher) refresh(osarch *zps.OsArch) error {
 			}
 			defer srcMeta.Close()
 
-			dstSig, err := os.OpenFile(f.cache.GetMetaSig(osarch.String(), f.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+			dstSig, err := os.OpenFile(f.cache.GetMetaSigStaged(osarch.String(), f.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 			if err != nil {
 				return err
 			}
@@ -219,18 +231,9 @@ func (f *FileFetcher) refresh(osarch *zps.OsArch) error {
 			if _, err := io.Copy(dstSig, srcSig); err != nil {
 				return err
 			}
-
-			err = ValidateFileSignature(f.security, f.cache.GetMeta(osarch.String(), f.uri.String()), f.cache.GetMetaSig(osarch.String(), f.uri.String()))
-			if err != nil {
-				// Remove the config and sig if validation fails
-				os.Remove(f.cache.GetMeta(osarch.String(), f.uri.String()))
-				os.Remove(f.cache.GetMetaSig(osarch.String(), f.uri.String()))
-
-				return err
-			}
 		}
 
-		return nil
+		return commitMetadata(f.cache, f.security, f.uri, osarch, f.stale)
 	} else if !os.IsNotExist(err) {
 		return err
 	}
@@ -0,0 +1,93 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zpm
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fezz-io/zps/zps"
+)
+
+// Writes a legacy metadata.db with the given version into a file repo
+func writeTestMetadata(t *testing.T, repoPath string, osarch *zps.OsArch, version uint64) {
+	dir := filepath.Join(repoPath, osarch.String())
+
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(dir, "metadata.db"))
+
+	err = NewMetadata(filepath.Join(dir, "metadata.db")).SetInfo(&MetadataInfo{Version: version, Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileFetcherRefreshRollback(t *testing.T) {
+	root, err := ioutil.TempDir("", "fetcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	repoPath := filepath.Join(root, "repo")
+	cachePath := filepath.Join(root, "cache")
+
+	for _, dir := range []string{repoPath, cachePath} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(repoPath, "config.db"), []byte("config"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	osarch := &zps.OsArch{Os: "linux", Arch: "x86_64"}
+	uri := &url.URL{Scheme: "file", Path: repoPath}
+	cache := NewCache(cachePath)
+
+	fetcher := NewFileFetcher(uri, cache, &SecurityNone{}, false)
+
+	writeTestMetadata(t, repoPath, osarch, 2)
+
+	err = fetcher.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seen := seenMetadataVersion(cache, uri, osarch); seen != 2 {
+		t.Fatalf("expected version 2 to be recorded, got %d", seen)
+	}
+
+	writeTestMetadata(t, repoPath, osarch, 1)
+
+	err = fetcher.Refresh()
+	if _, ok := err.(*StaleMetadataError); !ok {
+		t.Fatalf("expected a StaleMetadataError, got %v", err)
+	}
+
+	info, err := NewMetadata(cache.GetMeta(osarch.String(), uri.String())).Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Version != 2 {
+		t.Errorf("expected cached metadata to stay at version 2, got %d", info.Version)
+	}
+}
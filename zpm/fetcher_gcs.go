@@ -33,14 +33,15 @@ type GCSFetcher struct {
 
 	cache    *Cache
 	security Security
+	stale    bool
 }
 
-func NewGCSFetcher(uri *url.URL, cache *Cache, security Security) *GCSFetcher {
-	return &GCSFetcher{uri, cache, security}
+func NewGCSFetcher(uri *url.URL, cache *Cache, security Security, stale bool) *GCSFetcher {
+	return &GCSFetcher{uri, cache, security, stale}
 }
 
 func (g *GCSFetcher) Refresh() error {
-	dst, err := os.OpenFile(g.cache.GetConfig(g.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+	dst, err := os.OpenFile(g.cache.GetConfig(g.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
 		return err
 	}
@@ -77,7 +78,7 @@ func (g *GCSFetcher) Refresh() error {
 	cancel()
 
 	if g.security.Mode() != SecurityModeNone {
-		sdst, err := os.OpenFile(g.cache.GetConfigSig(g.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+		sdst, err := os.OpenFile(g.cache.GetConfigSig(g.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 		if err != nil {
 			return err
 		}
@@ -115,6 +116,11 @@ func (g *GCSFetcher) Refresh() error {
 
 			return err
 		}
+
+		err = g.refreshCrl(ctx, client)
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, osarch := range zps.Platforms() {
@@ -127,6 +133,38 @@ func (g *GCSFetcher) Refresh() error {
 	return nil
 }
 
+// Fetch CRL if published
+func (g *GCSFetcher) refreshCrl(ctx context.Context, client *storage.Client) error {
+	crlCtx, cancel := context.WithTimeout(ctx, time.Second*60)
+	defer cancel()
+
+	os.Remove(g.cache.GetCrl(g.uri.String()))
+
+	cr, err := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, "crl.pem")).NewReader(crlCtx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("refresh failed: %s", g.uri.String())
+	}
+	defer cr.Close()
+
+	dst, err := os.Create(g.cache.GetCrl(g.uri.String()))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, cr)
+	dst.Close()
+	if err != nil {
+		os.Remove(g.cache.GetCrl(g.uri.String()))
+
+		return fmt.Errorf("refresh failed: %s", g.uri.String())
+	}
+
+	return importCrl(g.security, g.cache.GetCrl(g.uri.String()))
+}
+
 func (g *GCSFetcher) Fetch(pkg *zps.Pkg) error {
 	var err error
 	osarch := &zps.OsArch{pkg.Os(), pkg.Arch()}
@@ -238,20 +276,28 @@ func (g *GCSFetcher) Keys() ([][]string, error) {
 
 func (g *GCSFetcher) refresh(osarch *zps.OsArch) error {
 	var err error
-	target := path.Join(g.uri.Path, osarch.String(), "metadata.db")
-	dest := g.cache.GetMeta(osarch.String(), g.uri.String())
 
-	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE, 0640)
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
 
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+	generation, _, err := gcsMetadataPointer(ctx, client, g.uri.Host, path.Join(g.uri.Path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	metadataName, sigName := MetadataFiles(generation)
+
+	target := path.Join(g.uri.Path, osarch.String(), metadataName)
+	dest := g.cache.GetMetaStaged(osarch.String(), g.uri.String())
+
+	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
 		return err
 	}
+	defer dst.Close()
 
 	// Download metadata db
 	mdCtx, cancel := context.WithTimeout(ctx, time.Second*60)
@@ -280,10 +326,10 @@ func (g *GCSFetcher) refresh(osarch *zps.OsArch) error {
 	cancel()
 
 	if g.security.Mode() != SecurityModeNone {
-		starget := path.Join(g.uri.Path, osarch.String(), "metadata.sig")
-		sdest := g.cache.GetMetaSig(osarch.String(), g.uri.String())
+		starget := path.Join(g.uri.Path, osarch.String(), sigName)
+		sdest := g.cache.GetMetaSigStaged(osarch.String(), g.uri.String())
 
-		sdst, err := os.OpenFile(sdest, os.O_RDWR|os.O_CREATE, 0640)
+		sdst, err := os.OpenFile(sdest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 		if err != nil {
 			return err
 		}
@@ -314,17 +360,34 @@ func (g *GCSFetcher) refresh(osarch *zps.OsArch) error {
 			return fmt.Errorf("Reader.Close: %v", err)
 		}
 		cancel()
+	}
 
-		// Validate metadata signature
-		err = ValidateFileSignature(g.security, g.cache.GetMeta(osarch.String(), g.uri.String()), g.cache.GetMetaSig(osarch.String(), g.uri.String()))
-		if err != nil {
-			// Remove the config and sig if validation fails
-			os.Remove(g.cache.GetMeta(osarch.String(), g.uri.String()))
-			os.Remove(g.cache.GetMetaSig(osarch.String(), g.uri.String()))
+	return commitMetadata(g.cache, g.security, g.uri, osarch, g.stale)
+}
 
-			return err
-		}
+// Reads the metadata pointer below prefix and its object generation, empty
+// for repos without one
+func gcsMetadataPointer(ctx context.Context, client *storage.Client, bucket string, prefix string) (string, int64, error) {
+	pointerCtx, cancel := context.WithTimeout(ctx, time.Second*60)
+	defer cancel()
+
+	target := path.Join(prefix, MetadataPointer)
+
+	reader, err := client.Bucket(bucket).Object(target).NewReader(pointerCtx)
+	if err == storage.ErrObjectNotExist {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to download: %s", target)
 	}
+	defer reader.Close()
 
-	return nil
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to download: %s", target)
+	}
+
+	generation, err := ParseMetadataPointer(content)
+
+	return generation, reader.Attrs.Generation, err
 }
@@ -29,15 +29,16 @@ type HttpsFetcher struct {
 
 	cache    *Cache
 	security Security
+	stale    bool
 
 	client *resty.Client
 }
 
-func NewHttpsFetcher(uri *url.URL, cache *Cache, security Security) *HttpsFetcher {
+func NewHttpsFetcher(uri *url.URL, cache *Cache, security Security, stale bool) *HttpsFetcher {
 	client := resty.New()
 	client.SetTimeout(time.Duration(10) * time.Second)
 
-	return &HttpsFetcher{uri, cache, security, client}
+	return &HttpsFetcher{uri, cache, security, stale, client}
 }
 
 func (h *HttpsFetcher) Refresh() error {
@@ -104,6 +105,32 @@ func (h *HttpsFetcher) Refresh() error {
 
 			return err
 		}
+
+		// Fetch CRL if published
+		crlUri, _ := url.Parse(h.uri.String())
+		crlUri.Path = path.Join(crlUri.Path, "crl.pem")
+
+		resp, err = h.client.R().
+			SetBasicAuth(user, password).
+			SetOutput(h.cache.GetCrl(h.uri.String())).
+			Get(crlUri.String())
+
+		if err != nil {
+			return errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+		}
+
+		if resp.IsError() {
+			os.Remove(h.cache.GetCrl(h.uri.String()))
+
+			if resp.StatusCode() != 404 {
+				return errors.New(fmt.Sprintf("server error %d: %s", resp.StatusCode(), crlUri.String()))
+			}
+		}
+
+		err = importCrl(h.security, h.cache.GetCrl(h.uri.String()))
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, osarch := range zps.Platforms() {
@@ -173,15 +200,22 @@ func (h *HttpsFetcher) Keys() ([][]string, error) {
 func (h *HttpsFetcher) refresh(osarch *zps.OsArch) error {
 	var err error
 
+	generation, err := h.metadataPointer(osarch)
+	if err != nil {
+		return err
+	}
+
+	metadataName, sigName := MetadataFiles(generation)
+
 	metadataUri, _ := url.Parse(h.uri.String())
-	metadataUri.Path = path.Join(metadataUri.Path, osarch.String(), "metadata.db")
+	metadataUri.Path = path.Join(metadataUri.Path, osarch.String(), metadataName)
 
 	user := metadataUri.User.Username()
 	password, _ := metadataUri.User.Password()
 
 	resp, err := h.client.R().
 		SetBasicAuth(user, password).
-		SetOutput(h.cache.GetMeta(osarch.String(), h.uri.String())).
+		SetOutput(h.cache.GetMetaStaged(osarch.String(), h.uri.String())).
 		Get(metadataUri.String())
 
 	if err != nil {
@@ -189,7 +223,7 @@ func (h *HttpsFetcher) refresh(osarch *zps.OsArch) error {
 	}
 
 	if resp.IsError() {
-		os.Remove(h.cache.GetMeta(osarch.String(), h.uri.String()))
+		os.Remove(h.cache.GetMetaStaged(osarch.String(), h.uri.String()))
 
 		switch resp.StatusCode() {
 		case 404:
@@ -203,11 +237,11 @@ func (h *HttpsFetcher) refresh(osarch *zps.OsArch) error {
 
 	if h.security.Mode() != SecurityModeNone {
 		sigUri, _ := url.Parse(h.uri.String())
-		sigUri.Path = path.Join(sigUri.Path, osarch.String(), "metadata.sig")
+		sigUri.Path = path.Join(sigUri.Path, osarch.String(), sigName)
 
 		resp, err := h.client.R().
 			SetBasicAuth(user, password).
-			SetOutput(h.cache.GetMetaSig(osarch.String(), h.uri.String())).
+			SetOutput(h.cache.GetMetaSigStaged(osarch.String(), h.uri.String())).
 			Get(sigUri.String())
 
 		if err != nil {
@@ -215,7 +249,7 @@ func (h *HttpsFetcher) refresh(osarch *zps.OsArch) error {
 		}
 
 		if resp.IsError() {
-			os.Remove(h.cache.GetMeta(osarch.String(), h.uri.String()))
+			os.Remove(h.cache.GetMetaStaged(osarch.String(), h.uri.String()))
 
 			switch resp.StatusCode() {
 			case 404:
@@ -226,17 +260,35 @@ func (h *HttpsFetcher) refresh(osarch *zps.OsArch) error {
 				return errors.New(fmt.Sprintf("server error %d: %s", resp.StatusCode(), sigUri.String()))
 			}
 		}
+	}
 
-		// Validate config signature
-		err = ValidateFileSignature(h.security, h.cache.GetMeta(osarch.String(), h.uri.String()), h.cache.GetMetaSig(osarch.String(), h.uri.String()))
-		if err != nil {
-			// Remove the config and sig if validation fails
-			os.Remove(h.cache.GetMeta(osarch.String(), h.uri.String()))
-			os.Remove(h.cache.GetMetaSig(osarch.String(), h.uri.String()))
+	return commitMetadata(h.cache, h.security, h.uri, osarch, h.stale)
+}
 
-			return err
+// Reads the metadata pointer, empty for repos without one
+func (h *HttpsFetcher) metadataPointer(osarch *zps.OsArch) (string, error) {
+	pointerUri, _ := url.Parse(h.uri.String())
+	pointerUri.Path = path.Join(pointerUri.Path, osarch.String(), MetadataPointer)
+
+	user := pointerUri.User.Username()
+	password, _ := pointerUri.User.Password()
+
+	resp, err := h.client.R().
+		SetBasicAuth(user, password).
+		Get(pointerUri.String())
+
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.IsError() {
+		switch resp.StatusCode() {
+		case 404, 403:
+			return "", nil
+		default:
+			return "", errors.New(fmt.Sprintf("server error %d: %s", resp.StatusCode(), pointerUri.String()))
 		}
 	}
 
-	return nil
+	return ParseMetadataPointer(resp.Body())
 }
@@ -35,11 +35,12 @@ type S3Fetcher struct {
 
 	cache    *Cache
 	security Security
+	stale    bool
 
 	session *session.Session
 }
 
-func NewS3Fetcher(uri *url.URL, cache *Cache, security Security) *S3Fetcher {
+func NewS3Fetcher(uri *url.URL, cache *Cache, security Security, stale bool) *S3Fetcher {
 	sess := session.Must(session.NewSession())
 
 	user := uri.User.Username()
@@ -59,11 +60,11 @@ func NewS3Fetcher(uri *url.URL, cache *Cache, security Security) *S3Fetcher {
 
 	sess.Config.Region = aws.String(region)
 
-	return &S3Fetcher{uri, cache, security, sess}
+	return &S3Fetcher{uri, cache, security, stale, sess}
 }
 
 func (s *S3Fetcher) Refresh() error {
-	dst, err := os.OpenFile(s.cache.GetConfig(s.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+	dst, err := os.OpenFile(s.cache.GetConfig(s.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
 		return err
 	}
@@ -83,7 +84,7 @@ func (s *S3Fetcher) Refresh() error {
 	}
 
 	if s.security.Mode() != SecurityModeNone {
-		sdst, err := os.OpenFile(s.cache.GetConfigSig(s.uri.String()), os.O_RDWR|os.O_CREATE, 0640)
+		sdst, err := os.OpenFile(s.cache.GetConfigSig(s.uri.String()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 		if err != nil {
 			return err
 		}
@@ -109,6 +110,11 @@ func (s *S3Fetcher) Refresh() error {
 
 			return err
 		}
+
+		err = s.refreshCrl(client)
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, osarch := range zps.Platforms() {
@@ -121,6 +127,31 @@ func (s *S3Fetcher) Refresh() error {
 	return nil
 }
 
+// Fetch CRL if published
+func (s *S3Fetcher) refreshCrl(client *s3manager.Downloader) error {
+	dst, err := os.Create(s.cache.GetCrl(s.uri.String()))
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Download(dst, &s3.GetObjectInput{
+		Bucket: aws.String(s.uri.Host),
+		Key:    aws.String(path.Join(s.uri.Path, "crl.pem")),
+	})
+	dst.Close()
+	if err != nil {
+		os.Remove(s.cache.GetCrl(s.uri.String()))
+
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchKey" {
+			return nil
+		}
+
+		return errors.New(fmt.Sprintf("refresh failed: %s", s.uri.String()))
+	}
+
+	return importCrl(s.security, s.cache.GetCrl(s.uri.String()))
+}
+
 func (s *S3Fetcher) Fetch(pkg *zps.Pkg) error {
 	var err error
 	osarch := &zps.OsArch{pkg.Os(), pkg.Arch()}
@@ -200,16 +231,24 @@ func (s *S3Fetcher) Keys() ([][]string, error) {
 
 func (s *S3Fetcher) refresh(osarch *zps.OsArch) error {
 	var err error
-	target := path.Join(s.uri.Path, osarch.String(), "metadata.db")
-	dest := s.cache.GetMeta(osarch.String(), s.uri.String())
 
-	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE, 0640)
+	client := s3manager.NewDownloader(s.session)
+
+	generation, err := s3MetadataPointer(client, s.uri.Host, path.Join(s.uri.Path, osarch.String()))
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
 
-	client := s3manager.NewDownloader(s.session)
+	metadataName, sigName := MetadataFiles(generation)
+
+	target := path.Join(s.uri.Path, osarch.String(), metadataName)
+	dest := s.cache.GetMetaStaged(osarch.String(), s.uri.String())
+
+	dst, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
 
 	_, err = client.Download(dst, &s3.GetObjectInput{
 		Bucket: aws.String(s.uri.Host),
@@ -226,10 +265,10 @@ func (s *S3Fetcher) refresh(osarch *zps.OsArch) error {
 	}
 
 	if s.security.Mode() != SecurityModeNone {
-		starget := path.Join(s.uri.Path, osarch.String(), "metadata.sig")
-		sdest := s.cache.GetMetaSig(osarch.String(), s.uri.String())
+		starget := path.Join(s.uri.Path, osarch.String(), sigName)
+		sdest := s.cache.GetMetaSigStaged(osarch.String(), s.uri.String())
 
-		sdst, err := os.OpenFile(sdest, os.O_RDWR|os.O_CREATE, 0640)
+		sdst, err := os.OpenFile(sdest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
 		if err != nil {
 			return err
 		}
@@ -247,17 +286,26 @@ func (s *S3Fetcher) refresh(osarch *zps.OsArch) error {
 				os.Remove(sdest)
 			}
 		}
+	}
 
-		// Validate metadata signature
-		err = ValidateFileSignature(s.security, s.cache.GetMeta(osarch.String(), s.uri.String()), s.cache.GetMetaSig(osarch.String(), s.uri.String()))
-		if err != nil {
-			// Remove the config and sig if validation fails
-			os.Remove(s.cache.GetMeta(osarch.String(), s.uri.String()))
-			os.Remove(s.cache.GetMetaSig(osarch.String(), s.uri.String()))
+	return commitMetadata(s.cache, s.security, s.uri, osarch, s.stale)
+}
 
-			return err
+// Reads the metadata pointer below prefix, empty for repos without one
+func s3MetadataPointer(client *s3manager.Downloader, bucket string, prefix string) (string, error) {
+	buf := aws.NewWriteAtBuffer([]byte{})
+
+	_, err := client.Download(buf, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path.Join(prefix, MetadataPointer)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchKey" {
+			return "", nil
 		}
+
+		return "", errors.New(fmt.Sprintf("unable to download: %s", path.Join(prefix, MetadataPointer)))
 	}
 
-	return nil
+	return ParseMetadataPointer(buf.Bytes())
 }
@@ -13,6 +13,8 @@ package zpm
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,18 +25,24 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/fezz-io/zps/oci"
 	"github.com/fezz-io/zps/provider"
+	"github.com/fezz-io/zps/sbom"
 	"github.com/fezz-io/zps/sec"
 
 	"github.com/fezz-io/zps/action"
 	"github.com/fezz-io/zps/phase"
 	"github.com/fezz-io/zps/zpkg"
+	"github.com/fezz-io/zps/zpkg/importer"
 	"github.com/fezz-io/zps/zps"
 
 	"github.com/chuckpreslar/emission"
 	"github.com/fezz-io/zps/config"
+	"github.com/fezz-io/zps/lint"
 	"github.com/nightlyone/lockfile"
 )
 
@@ -49,6 +57,8 @@ type Manager struct {
 
 	security Security
 
+	allowStale bool
+
 	lock lockfile.Lockfile
 }
 
@@ -72,7 +82,7 @@ func NewManager(image string) (*Manager, error) {
 	mgr.cache = NewCache(mgr.config.CachePath())
 	mgr.pki = NewPki(mgr.config.PkiPath())
 
-	mgr.security, err = NewSecurity(mgr.config.Security, mgr.pki)
+	mgr.security, err = NewSecurity(mgr.config.Security, mgr.pki, mgr.config.Signers)
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +90,12 @@ func NewManager(image string) (*Manager, error) {
 	return mgr, nil
 }
 
+// Loads repo metadata that is expired or older than previously seen,
+// warning instead of failing
+func (m *Manager) AllowStaleMetadata(allow bool) {
+	m.allowStale = allow
+}
+
 func (m *Manager) CacheClean() error {
 	err := m.cache.Clean()
 	if err != nil {
@@ -107,9 +123,14 @@ func (m *Manager) Channel(repo string, pkg string, channel string) error {
 		}
 
 		if repo == r.Publish.Name && r.Publish.Uri != nil {
-			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), r.Publish.Uri, r.Publish.Name, r.Publish.Prune)
+			err := m.channelComply(r, pkg, channel)
+			if err != nil {
+				return err
+			}
 
-			err := pb.Channel(pkg, channel)
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), r.Publish.Uri, r.Publish.Name, r.Publish.Prune, r.Publish.Expires, r.Publish.Files)
+
+			err = pb.Channel(channel, pkg)
 
 			return err
 		}
@@ -118,6 +139,123 @@ func (m *Manager) Channel(repo string, pkg string, channel string) error {
 	return errors.New("Repo: " + repo + " not found")
 }
 
+// Channels in a repo with their package counts, or the packages of one channel
+func (m *Manager) ChannelList(repo string, channel string) ([]string, error) {
+	for _, r := range m.config.Repos {
+		if r.Publish == nil {
+			continue
+		}
+
+		if repo == r.Publish.Name && r.Publish.Uri != nil {
+			pkgs, err := m.publishedPackages(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if channel != "" {
+				var contents []string
+				for _, pkg := range pkgs {
+					if containsId(pkg.Channels(), channel) {
+						contents = append(contents, strings.Join([]string{pkg.Name(), pkg.Id(), pkg.Arch()}, "|"))
+					}
+				}
+
+				return contents, nil
+			}
+
+			members := make(map[string]map[string]bool)
+
+			for _, osarch := range zps.Platforms() {
+				metadata := NewMetadata(m.cache.GetMeta(osarch.String(), r.Fetch.Uri.String()))
+				if !metadata.Exists() {
+					continue
+				}
+
+				channels, err := metadata.Channels.List()
+				if err != nil {
+					return nil, err
+				}
+
+				for _, ch := range channels {
+					members[ch] = make(map[string]bool)
+				}
+			}
+
+			for _, rule := range r.Publish.Channels {
+				if members[rule.Name] == nil {
+					members[rule.Name] = make(map[string]bool)
+				}
+			}
+
+			for _, pkg := range pkgs {
+				for _, ch := range pkg.Channels() {
+					if members[ch] != nil {
+						members[ch][pkg.Id()] = true
+					}
+				}
+			}
+
+			var names []string
+			for name := range members {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			var contents []string
+			for _, name := range names {
+				line := []string{name, fmt.Sprint(len(members[name]))}
+
+				for _, rule := range r.Publish.Channels {
+					if rule.Name == name {
+						line = append(line, "auto")
+					}
+				}
+
+				contents = append(contents, strings.Join(line, "|"))
+			}
+
+			return contents, nil
+		}
+	}
+
+	return nil, errors.New("Repo: " + repo + " not found")
+}
+
+func (m *Manager) ChannelRemove(repo string, pkg string, channel string) error {
+	for _, r := range m.config.Repos {
+		if r.Publish == nil {
+			continue
+		}
+
+		if repo == r.Publish.Name && r.Publish.Uri != nil {
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), r.Publish.Uri, r.Publish.Name, r.Publish.Prune, r.Publish.Expires, r.Publish.Files)
+
+			return pb.Unchannel(channel, pkg)
+		}
+	}
+
+	return errors.New("Repo: " + repo + " not found")
+}
+
+func (m *Manager) ChannelSync(repo string) error {
+	for _, r := range m.config.Repos {
+		if r.Publish == nil {
+			continue
+		}
+
+		if repo == r.Publish.Name && r.Publish.Uri != nil {
+			if len(r.Publish.Channels) == 0 {
+				m.Emit("manager.warn", fmt.Sprint("no channel rules defined for ", repo))
+				return nil
+			}
+
+			return m.channelSync(r)
+		}
+	}
+
+	return errors.New("Repo: " + repo + " not found")
+}
+
 func (m *Manager) Configure(packages []string, profile string) error {
 	pool, err := m.pool()
 	if err != nil {
@@ -250,7 +388,7 @@ func (m *Manager) Fetch(args []string) error {
 		pkg := policy.SelectRequest(pool.WhatProvides(job.Requirement()))
 
 		uri, _ := url.ParseRequestURI(pool.Location(pkg.Location()).Uri)
-		fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider())
+		fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider(), m.allowStale)
 		err = fe.Fetch(pkg.(*zps.Pkg))
 		if err != nil {
 			return err
@@ -452,7 +590,7 @@ func (m *Manager) ImageInit(imageFilePath string, name string, imageOs string, a
 	m.pki = NewPki(m.config.PkiPath())
 
 	// Point to new security
-	m.security, err = NewSecurity(m.config.Security, m.pki)
+	m.security, err = NewSecurity(m.config.Security, m.pki, m.config.Signers)
 	if err != nil {
 		return err
 	}
@@ -482,7 +620,7 @@ func (m *Manager) ImageInit(imageFilePath string, name string, imageOs string, a
 	}
 
 	// TODO fix this so we can refresh the cert cache following imports
-	m.security, err = NewSecurity(m.config.Security, m.pki)
+	m.security, err = NewSecurity(m.config.Security, m.pki, m.config.Signers)
 	if err != nil {
 		return err
 	}
@@ -653,6 +791,78 @@ func (m *Manager) ImageDelete(image string) error {
 	return nil
 }
 
+func (m *Manager) ImageExport(outputPath string, name string) error {
+	err := m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	if outputPath == "" {
+		return errors.New("output path required")
+	}
+
+	packages, err := m.state.Packages.All()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Zpkg.Name < packages[j].Zpkg.Name
+	})
+
+	image := m.config.CurrentImage
+	layout := oci.NewImageLayout(outputPath, image.Os, image.Arch)
+	ids := oci.NewIdMap(image.Path)
+
+	// Installed content is read from the image
+	source := func(file *action.File) (io.ReadCloser, int64, error) {
+		content, err := os.Open(filepath.Join(image.Path, file.Path))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		info, err := content.Stat()
+		if err != nil {
+			content.Close()
+			return nil, 0, err
+		}
+
+		return content, info.Size(), nil
+	}
+
+	for _, manifest := range packages {
+		pkg, err := zps.NewPkgFromManifest(manifest)
+		if err != nil {
+			return err
+		}
+
+		layer, err := layout.AddLayer(manifest, source, ids, pkg.Version().Timestamp)
+		if err != nil {
+			return err
+		}
+
+		m.Emit("manager.info", fmt.Sprintf("layer %s %s", pkg.Id(), layer.Digest))
+	}
+
+	for _, unknown := range ids.Unknown() {
+		m.Emit("manager.warn", fmt.Sprintf("%s not found, mapped to root", unknown))
+	}
+
+	if name == "" {
+		name = image.Name
+	}
+
+	err = layout.Write(name)
+	if err != nil {
+		return err
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("exported %s => %s", image.Name, outputPath))
+
+	return nil
+}
+
 func (m *Manager) ImageList() error {
 	for _, image := range m.config.Images {
 		if image == m.config.CurrentImage {
@@ -667,6 +877,26 @@ func (m *Manager) ImageList() error {
 	return nil
 }
 
+func (m *Manager) ImageSbom(format string) (string, error) {
+	err := m.lock.TryLock()
+	if err != nil {
+		return "", errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	packages, err := m.state.Packages.All()
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := sbom.Generate(format, m.config.CurrentImage.Name, packages)
+	if err != nil {
+		return "", err
+	}
+
+	return string(doc), nil
+}
+
 func (m *Manager) Info(pkgName string) ([]string, error) {
 	err := m.lock.TryLock()
 	if err != nil {
@@ -688,7 +918,7 @@ func (m *Manager) Info(pkgName string) ([]string, error) {
 		return nil, err
 	}
 
-	return []string{
+	info := []string{
 		strings.Join([]string{"Name:", pkg.Name()}, "|"),
 		strings.Join([]string{"Publisher:", pkg.Publisher()}, "|"),
 		strings.Join([]string{"Version:", pkg.Version().Semver.String()}, "|"),
@@ -696,7 +926,21 @@ func (m *Manager) Info(pkgName string) ([]string, error) {
 		strings.Join([]string{"Arch:", pkg.Arch()}, "|"),
 		strings.Join([]string{"Summary:", pkg.Summary()}, "|"),
 		strings.Join([]string{"Description: ", pkg.Description()}, "|"),
-	}, err
+	}
+
+	if pkg.License() != "" {
+		info = append(info, strings.Join([]string{"License:", pkg.License()}, "|"))
+	}
+
+	if prov := pkg.Provenance(); prov != nil {
+		for _, field := range [][]string{{"Source:", prov.Source()}, {"Builder:", prov.Builder}, {"Built:", prov.BuildTime}} {
+			if field[1] != "" {
+				info = append(info, strings.Join(field, "|"))
+			}
+		}
+	}
+
+	return info, err
 }
 
 func (m *Manager) Install(args []string, request *zps.Request) error {
@@ -764,7 +1008,7 @@ func (m *Manager) Install(args []string, request *zps.Request) error {
 		switch op.Operation {
 		case phase.INSTALL:
 			uri, _ := url.ParseRequestURI(pool.Location(op.Package.Location()).Uri)
-			fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider())
+			fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider(), m.allowStale)
 
 			m.Emitter.Emit("spin.start", fmt.Sprint("fetching: ", op.Package.Id()))
 			err = fe.Fetch(op.Package.(*zps.Pkg))
@@ -823,132 +1067,432 @@ func (m *Manager) List() ([]string, error) {
 	return output, nil
 }
 
-func (m *Manager) PkiKeyPairImport(certPath string, keyPath string) error {
-	err := m.lock.TryLock()
+// Creates a self signed publisher CA and an intermediate it issues,
+// written as ca and intermediate pem and key files in outPath
+func (m *Manager) PkiCaInit(publisher string, outPath string, algo string, days int, trust bool) error {
+	caKey, err := sec.GenerateKey(algo)
 	if err != nil {
-		return errors.New("zpm: locked by another process")
+		return err
 	}
-	defer m.lock.Unlock()
 
-	err = sec.SecurityValidateKeyPair(certPath, keyPath)
+	caPem, err := sec.IssueCert(&sec.CertRequest{
+		Type:      sec.CertCA,
+		Subject:   publisher + " CA",
+		Publisher: publisher,
+		Days:      days,
+	}, caKey.Public(), nil, caKey)
 	if err != nil {
 		return err
 	}
 
-	certPem, err := ioutil.ReadFile(certPath)
+	ca, err := sec.ParseCert(caPem)
 	if err != nil {
 		return err
 	}
 
-	keyPem, err := ioutil.ReadFile(keyPath)
+	intKey, err := sec.GenerateKey(algo)
 	if err != nil {
 		return err
 	}
 
-	subject, publisher, fingerprint, err := sec.SecurityCertMetaFromBytes(&certPem)
+	intPem, err := sec.IssueCert(&sec.CertRequest{
+		Type:      sec.CertIntermediate,
+		Subject:   publisher + " Intermediate",
+		Publisher: publisher,
+		Days:      days / 2,
+	}, intKey.Public(), ca, caKey)
 	if err != nil {
 		return err
 	}
 
-	kps, err := m.pki.KeyPairs.GetByPublisher(publisher)
+	err = writePki(outPath, "ca", caPem, caKey)
 	if err != nil {
 		return err
 	}
 
-	if len(kps) > 0 {
-		for index := range kps {
-			m.Emitter.Emit("manager.warn",
-				fmt.Sprintf("Removing %s due to matching publisher %s",
-					kps[index].Fingerprint,
-					publisher,
-				),
-			)
+	err = writePki(outPath, "intermediate", intPem, intKey)
+	if err != nil {
+		return err
+	}
 
-			err := m.pki.KeyPairs.Del(kps[index].Fingerprint)
-			if err != nil {
-				return err
-			}
+	m.Emit("manager.info", fmt.Sprintf("Created ca and intermediate for publisher %s in %s", publisher, outPath))
+
+	if !trust {
+		return nil
+	}
+
+	err = m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	for _, cert := range []struct {
+		content []byte
+		typ     string
+	}{{caPem, PKICertCA}, {intPem, PKICertIntermediate}} {
+		subject, _, err := m.security.Trust(&cert.content, cert.typ)
+		if err != nil {
+			return err
 		}
+
+		m.Emit("manager.info", fmt.Sprintf("Imported certificate '%s' for publisher: %s", subject, publisher))
 	}
 
-	err = m.pki.KeyPairs.Put(fingerprint, subject, publisher, certPem, keyPem)
+	return nil
+}
+
+func (m *Manager) PkiCrlImport(crlPath string) error {
+	err := m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	content, err := ioutil.ReadFile(crlPath)
 	if err != nil {
 		return err
 	}
 
-	m.Emit("manager.info", fmt.Sprintf("Imported keypair for publisher %s", publisher))
+	issuer, updated, err := m.security.Crl(&content)
+	if err != nil {
+		return err
+	}
+
+	if issuer == "" {
+		return ErrCrlIssuerUntrusted
+	}
+
+	if !updated {
+		m.Emit("manager.warn", fmt.Sprintf("Stored crl for '%s' is as recent, skipping", issuer))
+
+		return nil
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("Imported crl for '%s'", issuer))
 
 	return nil
 }
 
-func (m *Manager) PkiKeyPairList() ([]string, error) {
+func (m *Manager) PkiCrlList() ([]string, error) {
 	err := m.lock.TryLock()
 	if err != nil {
 		return nil, errors.New("zpm: locked by another process")
 	}
 	defer m.lock.Unlock()
 
-	kps, err := m.pki.KeyPairs.All()
+	crls, err := m.pki.Crls.All()
 	if err != nil {
 		return nil, err
 	}
 
 	var output []string
 
-	for _, entry := range kps {
+	for _, entry := range crls {
+		crl, err := sec.ParseCrl(entry.Crl)
+		if err != nil {
+			return nil, err
+		}
+
+		status := "current"
+		if !entry.NextUpdate.IsZero() && time.Now().After(entry.NextUpdate) {
+			status = "[yellow]stale"
+		}
+
 		output = append(output, strings.Join([]string{
 			entry.Subject,
-			entry.Publisher,
-			entry.Fingerprint,
+			entry.ThisUpdate.Format(time.RFC3339),
+			entry.NextUpdate.Format(time.RFC3339),
+			fmt.Sprint(len(crl.RevokedCertificateEntries), " revoked"),
+			entry.Issuer,
+			status,
 		}, "|"))
 	}
 
 	return output, nil
 }
 
-func (m *Manager) PkiKeyPairRemove(fingerprint string) error {
+func (m *Manager) PkiCrlRemove(issuer string) error {
 	err := m.lock.TryLock()
 	if err != nil {
 		return errors.New("zpm: locked by another process")
 	}
 	defer m.lock.Unlock()
 
-	kp, err := m.pki.KeyPairs.Get(fingerprint)
+	crl, err := m.pki.Crls.Get(issuer)
 	if err != nil {
 		return err
 	}
 
-	err = m.pki.KeyPairs.Del(fingerprint)
+	if crl == nil {
+		return fmt.Errorf("crl not found for issuer: %s", issuer)
+	}
+
+	err = m.pki.Crls.Del(issuer)
 	if err != nil {
 		return err
 	}
 
-	m.Emit("manager.info", fmt.Sprintf("removed keypair: %s", kp.Subject))
+	m.Emit("manager.info", fmt.Sprintf("removed crl: %s", crl.Subject))
 
 	return err
 }
 
-func (m *Manager) PkiTrustFetch(uriString string) error {
-	uri, err := url.Parse(uriString)
+// Creates a signing key for a publisher, issued by the ca cert and key when
+// given, otherwise a certificate request is written for keypair sign
+func (m *Manager) PkiKeyPairCreate(publisher string, name string, outPath string, algo string, caCertPath string, caKeyPath string, days int, doImport bool) error {
+	if name == "" {
+		name = publisher
+	}
+
+	key, err := sec.GenerateKey(algo)
 	if err != nil {
-		return fmt.Errorf("invalid uri format")
+		return err
 	}
 
-	fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider())
-	if fe == nil {
-		return fmt.Errorf("uri path not found: %s", uri)
+	if caCertPath == "" {
+		if doImport {
+			return errors.New("import requires an issuing ca cert and key")
+		}
+
+		csrPem, err := sec.CreateCsr(name, publisher, key)
+		if err != nil {
+			return err
+		}
+
+		err = writePki(outPath, name, nil, key)
+		if err != nil {
+			return err
+		}
+
+		err = writeNewFile(filepath.Join(outPath, name+".csr"), csrPem, 0644)
+		if err != nil {
+			return err
+		}
+
+		m.Emit("manager.info", fmt.Sprintf("Created key and certificate request for publisher %s in %s", publisher, outPath))
+
+		return nil
 	}
 
-	results, err := fe.Keys()
+	issuer, issuerKey, err := loadIssuer(caCertPath, caKeyPath)
 	if err != nil {
 		return err
 	}
 
-	for _, cert := range results {
-		m.Emit("manager.info", fmt.Sprintf("Imported certificate '%s' for publisher: %s", cert[0], cert[1]))
+	certPem, err := sec.IssueCert(&sec.CertRequest{
+		Type:      sec.CertUser,
+		Subject:   name,
+		Publisher: publisher,
+		Days:      days,
+	}, key.Public(), issuer, issuerKey)
+	if err != nil {
+		return err
 	}
 
-	return err
+	err = writePki(outPath, name, certPem, key)
+	if err != nil {
+		return err
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("Created keypair for publisher %s in %s", publisher, outPath))
+
+	if !doImport {
+		return nil
+	}
+
+	err = m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	_, _, err = m.security.Trust(&certPem, PKICertUser)
+	if err != nil {
+		return err
+	}
+
+	keyPem, err := sec.MarshalPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	return m.keyPairPut(certPem, keyPem)
+}
+
+func (m *Manager) PkiKeyPairImport(certPath string, keyPath string) error {
+	err := m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	err = sec.SecurityValidateKeyPair(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	certPem, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return err
+	}
+
+	keyPem, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	return m.keyPairPut(certPem, keyPem)
+}
+
+// Stores a keypair, replacing the keypairs of the same publisher
+func (m *Manager) keyPairPut(certPem []byte, keyPem []byte) error {
+	subject, publisher, fingerprint, err := sec.SecurityCertMetaFromBytes(&certPem)
+	if err != nil {
+		return err
+	}
+
+	kps, err := m.pki.KeyPairs.GetByPublisher(publisher)
+	if err != nil {
+		return err
+	}
+
+	if len(kps) > 0 {
+		for index := range kps {
+			m.Emitter.Emit("manager.warn",
+				fmt.Sprintf("Removing %s due to matching publisher %s",
+					kps[index].Fingerprint,
+					publisher,
+				),
+			)
+
+			err := m.pki.KeyPairs.Del(kps[index].Fingerprint)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = m.pki.KeyPairs.Put(fingerprint, subject, publisher, certPem, keyPem)
+	if err != nil {
+		return err
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("Imported keypair for publisher %s", publisher))
+
+	return nil
+}
+
+func (m *Manager) PkiKeyPairList() ([]string, error) {
+	err := m.lock.TryLock()
+	if err != nil {
+		return nil, errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	kps, err := m.pki.KeyPairs.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var output []string
+
+	for _, entry := range kps {
+		output = append(output, strings.Join([]string{
+			entry.Subject,
+			entry.Publisher,
+			entry.Fingerprint,
+		}, "|"))
+	}
+
+	return output, nil
+}
+
+func (m *Manager) PkiKeyPairRemove(fingerprint string) error {
+	err := m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	kp, err := m.pki.KeyPairs.Get(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	err = m.pki.KeyPairs.Del(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("removed keypair: %s", kp.Subject))
+
+	return err
+}
+
+// Issues a publisher certificate for a certificate request
+func (m *Manager) PkiKeyPairSign(csrPath string, caCertPath string, caKeyPath string, outPath string, days int) error {
+	content, err := ioutil.ReadFile(csrPath)
+	if err != nil {
+		return err
+	}
+
+	csr, err := sec.ParseCsr(content)
+	if err != nil {
+		return err
+	}
+
+	issuer, issuerKey, err := loadIssuer(caCertPath, caKeyPath)
+	if err != nil {
+		return err
+	}
+
+	certPem, err := sec.IssueCert(&sec.CertRequest{
+		Type:      sec.CertUser,
+		Subject:   csr.Subject.CommonName,
+		Publisher: csr.Subject.Organization[0],
+		Days:      days,
+	}, csr.PublicKey, issuer, issuerKey)
+	if err != nil {
+		return err
+	}
+
+	if outPath == "" {
+		outPath = strings.TrimSuffix(csrPath, filepath.Ext(csrPath)) + ".pem"
+	}
+
+	err = writeNewFile(outPath, certPem, 0644)
+	if err != nil {
+		return err
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("Issued certificate '%s' for publisher %s: %s",
+		csr.Subject.CommonName, csr.Subject.Organization[0], outPath))
+
+	return nil
+}
+
+func (m *Manager) PkiTrustFetch(uriString string) error {
+	uri, err := url.Parse(uriString)
+	if err != nil {
+		return fmt.Errorf("invalid uri format")
+	}
+
+	fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider(), m.allowStale)
+	if fe == nil {
+		return fmt.Errorf("uri path not found: %s", uri)
+	}
+
+	results, err := fe.Keys()
+	if err != nil {
+		return err
+	}
+
+	for _, cert := range results {
+		m.Emit("manager.info", fmt.Sprintf("Imported certificate '%s' for publisher: %s", cert[0], cert[1]))
+	}
+
+	return err
 }
 
 func (m *Manager) PkiTrustImport(certPath string, typ string) error {
@@ -985,14 +1529,25 @@ func (m *Manager) PkiTrustList() ([]string, error) {
 		return nil, err
 	}
 
+	crls, err := m.pki.Crls.All()
+	if err != nil {
+		return nil, err
+	}
+
 	var output []string
 
 	for _, entry := range kps {
+		status, err := revocationStatus(entry, crls)
+		if err != nil {
+			return nil, err
+		}
+
 		output = append(output, strings.Join([]string{
 			entry.Subject,
 			entry.Publisher,
 			entry.Type,
 			entry.Fingerprint,
+			status,
 		}, "|"))
 	}
 
@@ -1095,9 +1650,33 @@ func (m *Manager) Publish(repo string, pkgs ...string) error {
 		}
 
 		if repo == r.Publish.Name && r.Publish.Uri != nil {
-			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), r.Publish.Uri, r.Publish.Name, r.Publish.Prune)
+			for _, file := range pkgs {
+				reader := zpkg.NewReader(file, "")
+
+				err := reader.Read()
+				if err != nil {
+					return err
+				}
+
+				pkg, err := zps.NewPkgFromManifest(reader.Manifest)
+				if err != nil {
+					return err
+				}
+
+				err = comply(r.Publish.Policies, "", pkg)
+				if err != nil {
+					return err
+				}
+			}
+
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), r.Publish.Uri, r.Publish.Name, r.Publish.Prune, r.Publish.Expires, r.Publish.Files)
 
-			return pb.Publish(pkgs...)
+			err := pb.Publish(pkgs...)
+			if err != nil || len(r.Publish.Channels) == 0 {
+				return err
+			}
+
+			return m.channelSync(r)
 		}
 	}
 
@@ -1111,17 +1690,22 @@ func (m *Manager) Refresh() error {
 	}
 	defer m.lock.Unlock()
 
+	var rejected []string
+
 	for _, r := range m.config.Repos {
 		if r.Enabled == false {
 			m.Emit("manager.warn", fmt.Sprint("skipped disabled: ", SafeURI(r.Fetch.Uri)))
 			continue
 		}
 
-		fe := NewFetcher(r.Fetch.Uri, m.cache, m.security, m.config.CloudProvider())
+		fe := NewFetcher(r.Fetch.Uri, m.cache, m.security, m.config.CloudProvider(), m.allowStale)
 		m.Emit("spin.start", fmt.Sprint("refreshing: ", SafeURI(r.Fetch.Uri)))
 		err = fe.Refresh()
 		if err == nil {
 			m.Emit("spin.success", fmt.Sprint("refreshed: ", SafeURI(r.Fetch.Uri)))
+		} else if _, ok := err.(*StaleMetadataError); ok {
+			m.Emit("spin.error", fmt.Sprint("metadata rejected: ", err.Error()))
+			rejected = append(rejected, SafeURI(r.Fetch.Uri))
 		} else if strings.Contains(err.Error(), "no trusted certificates") {
 			m.Emit("spin.error", fmt.Sprint("metadata validation failed: ", SafeURI(r.Fetch.Uri)))
 		} else if strings.Contains(err.Error(), "refresh failed") {
@@ -1131,6 +1715,12 @@ func (m *Manager) Refresh() error {
 		}
 	}
 
+	m.warnStaleCrls()
+
+	if len(rejected) > 0 {
+		return errors.New("refresh failed, kept cached metadata for: " + strings.Join(rejected, ", "))
+	}
+
 	return nil
 }
 
@@ -1175,6 +1765,66 @@ func (m *Manager) Remove(args []string) error {
 	return err
 }
 
+func (m *Manager) RepoCheck(name string, fix bool) error {
+	for _, repo := range m.config.Repos {
+		if repo.Publish == nil {
+			continue
+		}
+
+		if name == repo.Publish.Name && repo.Publish.Uri != nil {
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune, repo.Publish.Expires, repo.Publish.Files)
+
+			problems, unresolved := 0, 0
+
+			err := m.checkRepoMetadata(repo)
+			if err != nil {
+				m.Emit("manager.warn", err.Error())
+				problems++
+
+				// Re-signing also stamps a new version and expiry
+				if fix {
+					err = pb.Update()
+					if err == nil {
+						err = m.checkRepoMetadata(repo)
+					}
+				}
+
+				if err != nil {
+					if fix {
+						m.Emit("manager.warn", err.Error())
+					}
+					unresolved++
+				} else {
+					m.Emit("manager.info", fmt.Sprint("re-signed metadata for ", name))
+				}
+			}
+
+			found, left, err := pb.Check(fix)
+			if err != nil {
+				return err
+			}
+
+			problems += found
+			unresolved += left
+
+			switch {
+			case problems == 0:
+				m.Emit("manager.info", fmt.Sprint("no problems found in ", name))
+			case unresolved == 0:
+				m.Emit("manager.info", fmt.Sprintf("fixed %d problems in %s", problems, name))
+			case fix:
+				return fmt.Errorf("%d of %d problems in %s could not be fixed", unresolved, problems, name)
+			default:
+				return fmt.Errorf("%d problems found in %s, run with --fix to repair", problems, name)
+			}
+
+			return nil
+		}
+	}
+
+	return errors.New("Repo: " + name + " not found")
+}
+
 func (m *Manager) RepoInit(name string) error {
 	for _, repo := range m.config.Repos {
 		if repo.Publish == nil {
@@ -1182,7 +1832,7 @@ func (m *Manager) RepoInit(name string) error {
 		}
 
 		if name == repo.Publish.Name && repo.Publish.Uri != nil {
-			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune)
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune, repo.Publish.Expires, repo.Publish.Files)
 
 			return pb.Init()
 		}
@@ -1235,7 +1885,12 @@ func (m *Manager) RepoContents(name string) ([]string, error) {
 				zrepo.Load(meta)
 
 				for _, pkg := range zrepo.Solvables() {
-					contents = append(contents, strings.Join([]string{pkg.(*zps.Pkg).Name(), pkg.(*zps.Pkg).Id()}, "|"))
+					line := []string{pkg.(*zps.Pkg).Name(), pkg.(*zps.Pkg).Id()}
+					if pkg.Yanked() {
+						line = append(line, "yanked")
+					}
+
+					contents = append(contents, strings.Join(line, "|"))
 				}
 			}
 
@@ -1276,26 +1931,327 @@ func (m *Manager) RepoList() ([]string, error) {
 	return repos, nil
 }
 
-func (m *Manager) RepoUpdate(name string) error {
-	for _, repo := range m.config.Repos {
-		if repo.Publish == nil {
-			continue
+// Copies packages from the repo at srcUri missing in the destination repo,
+// optionally limited to channels, names and the newest versions of each name.
+// Signatures are verified while fetching, with resign the packages are also
+// signed by the destination publisher
+func (m *Manager) RepoMirror(srcUri string, dstName string, channels []string, names []string, versions int, resign bool) error {
+	var dst *config.RepoConfig
+	for _, r := range m.config.Repos {
+		if r.Publish != nil && r.Publish.Uri != nil && r.Publish.Name == dstName {
+			dst = r
 		}
+	}
 
-		if name == repo.Publish.Name && repo.Publish.Uri != nil {
-			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune)
-
-			return pb.Update()
-		}
+	if dst == nil {
+		return errors.New("Repo: " + dstName + " not found")
 	}
 
-	return errors.New("Repo: " + name + " not found")
-}
+	if dst.Fetch == nil || dst.Fetch.Uri == nil {
+		return errors.New("Repo: " + dstName + " has no fetch uri")
+	}
 
-func (m *Manager) Thaw(args []string) error {
-	err := m.lock.TryLock()
+	src, err := url.Parse(srcUri)
 	if err != nil {
-		return errors.New("zpm: locked by another process")
+		return err
+	}
+
+	if m.security.Mode() == SecurityModeNone {
+		m.Emit("manager.warn", "security mode none, signatures are not verified")
+	}
+
+	workPath, err := ioutil.TempDir(m.config.WorkPath(), "mirror")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workPath)
+
+	cache := NewCache(workPath)
+
+	m.Emit("spin.start", fmt.Sprint("refreshing: ", SafeURI(src)))
+	srcFetcher := NewFetcher(src, cache, m.security, m.config.CloudProvider(), m.allowStale)
+	if srcFetcher == nil {
+		m.Emit("spin.error", fmt.Sprint("unsupported: ", SafeURI(src)))
+		return errors.New("unsupported repo uri scheme: " + src.Scheme)
+	}
+
+	err = srcFetcher.Refresh()
+	if err != nil {
+		m.Emit("spin.error", fmt.Sprint("refresh failed: ", SafeURI(src)))
+		return err
+	}
+	m.Emit("spin.success", fmt.Sprint("refreshed: ", SafeURI(src)))
+
+	m.Emit("spin.start", fmt.Sprint("refreshing: ", SafeURI(dst.Fetch.Uri)))
+	err = NewFetcher(dst.Fetch.Uri, cache, m.security, m.config.CloudProvider(), true).Refresh()
+	if err != nil {
+		m.Emit("spin.error", fmt.Sprint("refresh failed: ", SafeURI(dst.Fetch.Uri)))
+		return errors.New(err.Error() + ", initialize the destination with zps repo init " + dstName)
+	}
+	m.Emit("spin.success", fmt.Sprint("refreshed: ", SafeURI(dst.Fetch.Uri)))
+
+	var signer sec.Signer
+	if resign {
+		signer, err = m.security.Signer(PublisherFromUri(dst.Publish.Uri))
+		if err != nil {
+			return err
+		}
+
+		if signer == nil {
+			return errors.New("no signer found for publisher " + PublisherFromUri(dst.Publish.Uri))
+		}
+	}
+
+	nameFilter := make(map[string]bool)
+	for _, name := range names {
+		nameFilter[name] = true
+	}
+
+	var files []string
+	channelAdds := make(map[string][]string)
+
+	for _, osarch := range zps.Platforms() {
+		srcMeta := NewMetadata(cache.GetMeta(osarch.String(), src.String()))
+		if !srcMeta.Exists() {
+			continue
+		}
+
+		srcPkgs, err := srcMeta.All()
+		if err != nil {
+			return err
+		}
+
+		var named []*zps.Pkg
+		for _, pkg := range srcPkgs {
+			if len(nameFilter) == 0 || nameFilter[pkg.Name()] {
+				named = append(named, pkg)
+			}
+		}
+
+		// Channel filtering is done by the repo, then the newest versions kept
+		selected := zps.NewRepo(src.String(), 0, true, channels, []zps.Solvable{})
+		selected.Load(named)
+
+		candidates := &zps.Repo{}
+		for _, solvable := range selected.Solvables() {
+			candidates.Add(solvable.(*zps.Pkg))
+		}
+
+		if versions > 0 {
+			_, err = candidates.Prune(versions)
+			if err != nil {
+				return err
+			}
+		}
+
+		dstRepo := &zps.Repo{}
+		dstChannels := make(map[string]map[string]bool)
+
+		if dstMeta := NewMetadata(cache.GetMeta(osarch.String(), dst.Fetch.Uri.String())); dstMeta.Exists() {
+			dstPkgs, err := dstMeta.All()
+			if err != nil {
+				return err
+			}
+
+			dstRepo.Load(dstPkgs)
+
+			for _, pkg := range dstPkgs {
+				dstChannels[pkg.Id()] = make(map[string]bool)
+				for _, ch := range pkg.Channels() {
+					dstChannels[pkg.Id()][ch] = true
+				}
+			}
+		}
+
+		for _, solvable := range candidates.Solvables() {
+			pkg := solvable.(*zps.Pkg)
+
+			for _, ch := range pkg.Channels() {
+				if !dstChannels[pkg.Id()][ch] {
+					channelAdds[pkg.Id()] = append(channelAdds[pkg.Id()], ch)
+				}
+			}
+
+			if dstRepo.Contains(pkg) {
+				continue
+			}
+
+			err = comply(dst.Publish.Policies, "", pkg)
+			if err != nil {
+				return err
+			}
+
+			m.Emit("spin.start", fmt.Sprint("fetching: ", pkg.Id()))
+			err = srcFetcher.Fetch(pkg)
+			if err != nil {
+				m.Emit("spin.error", fmt.Sprint("failed: ", pkg.Id()))
+				return err
+			}
+			m.Emit("spin.success", fmt.Sprint("fetched: ", pkg.Id()))
+
+			file := cache.GetFile(pkg.FileName())
+
+			if signer != nil {
+				err = zpkg.NewSigner(file, workPath).Sign(signer)
+				if err != nil {
+					return err
+				}
+			}
+
+			files = append(files, file)
+		}
+	}
+
+	pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), dst.Publish.Uri, dst.Publish.Name, dst.Publish.Prune, dst.Publish.Expires, dst.Publish.Files)
+
+	if len(files) > 0 {
+		err = pb.Publish(files...)
+		if err != nil {
+			return err
+		}
+	}
+
+	var ids []string
+	for id := range channelAdds {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for _, ch := range channelAdds[id] {
+			err = m.channelComply(dst, id, ch)
+			if err != nil {
+				return err
+			}
+
+			err = pb.Channel(ch, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	m.Emit("manager.info", fmt.Sprintf("mirrored %d packages to %s", len(files), dstName))
+
+	return nil
+}
+
+func (m *Manager) RepoRemove(name string, args []string) error {
+	for _, repo := range m.config.Repos {
+		if repo.Publish == nil {
+			continue
+		}
+
+		if name == repo.Publish.Name && repo.Publish.Uri != nil {
+			ids, err := m.repoPackages(repo, args)
+			if err != nil {
+				return err
+			}
+
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune, repo.Publish.Expires, repo.Publish.Files)
+
+			return pb.Remove(ids...)
+		}
+	}
+
+	return errors.New("Repo: " + name + " not found")
+}
+
+func (m *Manager) RepoServe(name string, addr string, user string, password string, certPath string, keyPath string, upload bool, maxUpload int64) error {
+	if (certPath == "") != (keyPath == "") {
+		return errors.New("both a tls certificate and key are required")
+	}
+
+	if upload && (user == "" || password == "") {
+		return errors.New("uploads require basic auth credentials")
+	}
+
+	if user != "" && password == "" {
+		return errors.New("basic auth requires a password")
+	}
+
+	if maxUpload <= 0 {
+		return errors.New("max upload size must be positive")
+	}
+
+	for _, repo := range m.config.Repos {
+		if repo.Publish == nil {
+			continue
+		}
+
+		if name == repo.Publish.Name && repo.Publish.Uri != nil {
+			if repo.Publish.Uri.Scheme != "file" {
+				return errors.New("Repo: " + name + " is not a file repository")
+			}
+
+			if _, err := os.Stat(filepath.Join(repo.Publish.Uri.Path, "config.db")); os.IsNotExist(err) {
+				return errors.New("Repo: " + name + " is not initialized")
+			}
+
+			prefix := path.Join("/", PublisherFromUri(repo.Publish.Uri), path.Base(repo.Publish.Uri.Path))
+			server := NewRepoServer(m.Emitter, repo.Publish.Uri.Path, prefix, user, password, upload, maxUpload)
+
+			return server.Serve(addr, certPath, keyPath)
+		}
+	}
+
+	return errors.New("Repo: " + name + " not found")
+}
+
+func (m *Manager) RepoUpdate(name string) error {
+	for _, repo := range m.config.Repos {
+		if repo.Publish == nil {
+			continue
+		}
+
+		if name == repo.Publish.Name && repo.Publish.Uri != nil {
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune, repo.Publish.Expires, repo.Publish.Files)
+
+			return pb.Update()
+		}
+	}
+
+	return errors.New("Repo: " + name + " not found")
+}
+
+func (m *Manager) RepoYank(name string, args []string, undo bool) error {
+	for _, repo := range m.config.Repos {
+		if repo.Publish == nil {
+			continue
+		}
+
+		if name == repo.Publish.Name && repo.Publish.Uri != nil {
+			ids, err := m.repoPackages(repo, args)
+			if err != nil {
+				return err
+			}
+
+			pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), repo.Publish.Uri, repo.Publish.Name, repo.Publish.Prune, repo.Publish.Expires, repo.Publish.Files)
+
+			err = pb.Yank(!undo, ids...)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				if undo {
+					m.Emit("manager.info", fmt.Sprint("unyanked ", id))
+				} else {
+					m.Emit("manager.info", fmt.Sprint("yanked ", id))
+				}
+			}
+
+			return nil
+		}
+	}
+
+	return errors.New("Repo: " + name + " not found")
+}
+
+func (m *Manager) Thaw(args []string) error {
+	err := m.lock.TryLock()
+	if err != nil {
+		return errors.New("zpm: locked by another process")
 	}
 	defer m.lock.Unlock()
 
@@ -1322,6 +2278,140 @@ func (m *Manager) Thaw(args []string) error {
 	return nil
 }
 
+// Searches the cached metadata of enabled repos, keeping the latest version
+// of each package per repo and platform
+func (m *Manager) Search(terms []string, channels []string, osName string, arch string, publisher string, installed bool, available bool) (zps.SearchResults, error) {
+	err := m.lock.TryLock()
+	if err != nil {
+		return nil, errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	image, err := m.image()
+	if err != nil {
+		return nil, err
+	}
+
+	installedIds := make(map[string]string)
+	for _, pkg := range image.Solvables() {
+		installedIds[pkg.Name()] = pkg.Id()
+	}
+
+	search := zps.NewSearch(terms...)
+
+	matches := make(map[string]*zps.SearchResult)
+	versions := make(map[string]*zps.Version)
+
+	for _, r := range m.config.Repos {
+		if !r.Enabled {
+			continue
+		}
+
+		if !m.cache.HasMeta(r.Fetch.Uri.String()) {
+			m.Emit("manager.warn", fmt.Sprintf("missing metadata: %s", SafeURI(r.Fetch.Uri)))
+			continue
+		}
+
+		repoName := SafeURI(r.Fetch.Uri)
+		if repoConfig, _ := m.repoConfig(r.Fetch.Uri.String()); repoConfig != nil && repoConfig["name"] != "" {
+			repoName = repoConfig["name"]
+		}
+
+		for _, osarch := range zps.Platforms() {
+			metadata := NewMetadata(m.cache.GetMeta(osarch.String(), r.Fetch.Uri.String()))
+			if !metadata.Exists() {
+				continue
+			}
+
+			// Validate metadata signature
+			if m.security.Mode() != SecurityModeNone {
+				err := ValidateFileSignature(m.security, m.cache.GetMeta(osarch.String(), r.Fetch.Uri.String()), m.cache.GetMetaSig(osarch.String(), r.Fetch.Uri.String()))
+				if err != nil {
+					m.Emit("manager.error", fmt.Sprintf("invalid metadata signature: %s", SafeURI(r.Fetch.Uri)))
+					continue
+				}
+			}
+
+			err = checkMetadata(m.cache, r.Fetch.Uri, osarch, metadata, false)
+			if err != nil {
+				m.Emit("manager.warn", err.Error())
+
+				if !m.allowStale {
+					continue
+				}
+			}
+
+			meta, err := metadata.All()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pkg := range meta {
+				if pkg.Yanked() || (osName != "" && pkg.Os() != osName) || (arch != "" && pkg.Arch() != arch) {
+					continue
+				}
+
+				if publisher != "" && pkg.Publisher() != publisher {
+					continue
+				}
+
+				if len(channels) > 0 && !inChannels(pkg, channels) {
+					continue
+				}
+
+				installedId := installedIds[pkg.Name()]
+				if (installed && installedId == "") || (available && installedId != "") {
+					continue
+				}
+
+				score := search.Score(pkg)
+				if score == 0 {
+					continue
+				}
+
+				key := strings.Join([]string{repoName, pkg.Os(), pkg.Arch(), pkg.Name()}, "|")
+				if version, ok := versions[key]; ok && !pkg.Version().GT(version) {
+					continue
+				}
+
+				versions[key] = pkg.Version()
+				matches[key] = &zps.SearchResult{
+					Name:      pkg.Name(),
+					Id:        pkg.Id(),
+					Publisher: pkg.Publisher(),
+					Os:        pkg.Os(),
+					Arch:      pkg.Arch(),
+					Summary:   pkg.Summary(),
+					Repo:      repoName,
+					Channels:  pkg.Channels(),
+					Installed: installedId,
+					Score:     score,
+				}
+			}
+		}
+	}
+
+	var results zps.SearchResults
+	for _, match := range matches {
+		results = append(results, match)
+	}
+
+	results.Sort()
+
+	return results, nil
+}
+
+// Packages in any of the channels
+func inChannels(pkg *zps.Pkg, channels []string) bool {
+	for _, channel := range channels {
+		if containsId(pkg.Channels(), channel) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (m *Manager) Status(query string) (string, []string, error) {
 	err := m.lock.TryLock()
 	if err != nil {
@@ -1486,7 +2576,7 @@ func (m *Manager) Update(reqs []string) error {
 		switch op.Operation {
 		case phase.INSTALL:
 			uri, _ := url.ParseRequestURI(pool.Location(op.Package.Location()).Uri)
-			fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider())
+			fe := NewFetcher(uri, m.cache, m.security, m.config.CloudProvider(), m.allowStale)
 			err = fe.Fetch(op.Package.(*zps.Pkg))
 			if err != nil {
 				return err
@@ -1498,77 +2588,179 @@ func (m *Manager) Update(reqs []string) error {
 		}
 	}
 
-	if solution.Noop() {
-		return nil
+	if solution.Noop() {
+		return nil
+	}
+
+	tr := NewTransaction(m.Emitter, m.config.CurrentImage.Path, m.cache, m.state)
+
+	err = tr.Realize(solution)
+
+	return err
+}
+
+func (m *Manager) ZpkgBuild(zpfPath string, targetPath string, workPath string, outputPath string, restrict bool, secure bool, vars map[string]string, sonameDepends string) error {
+	builder := zpkg.NewBuilder()
+
+	builder.Emitter = m.Emitter
+
+	builder.ZpfPath(zpfPath).
+		TargetPath(targetPath).WorkPath(workPath).
+		OutputPath(outputPath).Restrict(restrict).
+		Secure(secure).Vars(vars).
+		SonameDepends(sonameDepends)
+
+	filenames, manifests, err := builder.BuildAll()
+	if err != nil {
+		return err
+	}
+
+	for index, filename := range filenames {
+		manifest := manifests[index]
+
+		signer, err := m.security.Signer(manifest.Zpkg.Publisher)
+		if err != nil {
+			return err
+		}
+
+		if signer == nil {
+			m.Emitter.Emit("manager.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", manifest.Zpkg.Publisher))
+
+			continue
+		}
+
+		err = zpkg.NewSigner(filename, workPath).Sign(signer)
+		if err != nil {
+			return err
+		}
+
+		m.Emitter.Emit("manager.info", fmt.Sprintf("Signed with key: %s", signer.Subject()))
+	}
+
+	return nil
+}
+
+// TODO consider merging with Contents command via file path sniffing
+func (m *Manager) ZpkgContents(path string) ([]string, error) {
+	reader := zpkg.NewReader(path, "")
+
+	err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	contents := reader.Manifest.Section("Dir", "SymLink", "File")
+
+	sort.Sort(contents)
+
+	var output []string
+	for _, fsObject := range contents {
+		output = append(output, fsObject.Columns())
+	}
+
+	return output, nil
+}
+
+// Compares two zpkg manifests, with content adding unified diffs of changed text files
+func (m *Manager) ZpkgDiff(fromPath string, toPath string, workPath string, content bool) (*zpkg.Diff, error) {
+	from := zpkg.NewReader(fromPath, workPath)
+
+	err := from.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	to := zpkg.NewReader(toPath, workPath)
+
+	err = to.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := zpkg.NewDiff(from.Manifest, to.Manifest)
+
+	if !content {
+		return diff, nil
 	}
 
-	tr := NewTransaction(m.Emitter, m.config.CurrentImage.Path, m.cache, m.state)
+	fromSource := payloadSource(from, workPath)
+	toSource := payloadSource(to, workPath)
 
-	err = tr.Realize(solution)
+	for _, change := range diff.ContentChanges() {
+		fromFile, toFile := change.Actions()
 
-	return err
-}
+		fromContent, err := readSource(fromSource, fromFile.(*action.File))
+		if err != nil {
+			return nil, err
+		}
 
-func (m *Manager) ZpkgBuild(zpfPath string, targetPath string, workPath string, outputPath string, restrict bool, secure bool) error {
-	builder := zpkg.NewBuilder()
+		toContent, err := readSource(toSource, toFile.(*action.File))
+		if err != nil {
+			return nil, err
+		}
 
-	builder.Emitter = m.Emitter
+		if !zpkg.IsText(fromContent) || !zpkg.IsText(toContent) {
+			change.Content = fmt.Sprintf("Binary files a/%s and b/%s differ\n", change.Key, change.Key)
+			continue
+		}
 
-	builder.ZpfPath(zpfPath).
-		TargetPath(targetPath).WorkPath(workPath).
-		OutputPath(outputPath).Restrict(restrict).
-		Secure(secure)
+		change.Content = zpkg.UnifiedDiff("a/"+change.Key, "b/"+change.Key, fromContent, toContent)
+	}
+
+	return diff, nil
+}
 
-	filename, manifest, err := builder.Build()
+func (m *Manager) ZpkgExport(path string, outputPath string, workPath string) error {
+	reader := zpkg.NewReader(path, workPath)
+
+	err := reader.Read()
 	if err != nil {
 		return err
 	}
 
-	kp, err := m.security.KeyPair(manifest.Zpkg.Publisher)
+	pkg, err := zps.NewPkgFromManifest(reader.Manifest)
 	if err != nil {
 		return err
 	}
 
-	if kp == nil {
-		m.Emitter.Emit("manager.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", manifest.Zpkg.Publisher))
+	if outputPath == "" {
+		outputPath, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
 
-		return err
+	if workPath == "" {
+		workPath = outputPath
 	}
 
-	signer := zpkg.NewSigner(filename, workPath)
+	source := payloadSource(reader, workPath)
+
+	filename := filepath.Join(outputPath, strings.TrimSuffix(filepath.Base(path), ".zpkg")+".tar.gz")
 
-	rsaKey, err := kp.RSAKey()
+	layerFile, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
+	defer layerFile.Close()
 
-	err = signer.Sign(kp.Fingerprint, rsaKey)
-	if err == nil {
-		m.Emitter.Emit("manager.info", fmt.Sprintf("Signed with keypair: %s", kp.Subject))
-	}
-
-	return err
-}
-
-// TODO consider merging with Contents command via file path sniffing
-func (m *Manager) ZpkgContents(path string) ([]string, error) {
-	reader := zpkg.NewReader(path, "")
+	ids := oci.NewIdMap("")
 
-	err := reader.Read()
+	layer, err := oci.WriteLayer(layerFile, reader.Manifest, source, ids, pkg.Version().Timestamp)
 	if err != nil {
-		return nil, err
+		os.Remove(filename)
+		return err
 	}
 
-	contents := reader.Manifest.Section("Dir", "SymLink", "File")
-
-	sort.Sort(contents)
-
-	var output []string
-	for _, fsObject := range contents {
-		output = append(output, fsObject.Columns())
+	for _, unknown := range ids.Unknown() {
+		m.Emit("manager.warn", fmt.Sprintf("%s not found, mapped to root", unknown))
 	}
 
-	return output, nil
+	m.Emit("manager.info", fmt.Sprint("digest: ", layer.Digest))
+	m.Emit("manager.info", fmt.Sprint("diff_id: ", layer.DiffId))
+	m.Emit("builder.complete", filename)
+
+	return nil
 }
 
 func (m *Manager) ZpkgExtract(filepath string, target string) error {
@@ -1600,6 +2792,44 @@ func (m *Manager) ZpkgExtract(filepath string, target string) error {
 	return nil
 }
 
+func (m *Manager) ZpkgImport(filePath string, format string, name string, version string, publisher string, pkgOs string, arch string, workPath string, outputPath string, secure bool) error {
+	imp := importer.NewImporter()
+
+	imp.Emitter = m.Emitter
+
+	imp.Format(format).Name(name).Version(version).
+		Publisher(publisher).Os(pkgOs).Arch(arch).
+		WorkPath(workPath).OutputPath(outputPath).
+		Secure(secure)
+
+	filename, manifest, report, err := imp.Import(filePath)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range report {
+		m.Emitter.Emit("manager.warn", fmt.Sprint("import: ", message))
+	}
+
+	signer, err := m.security.Signer(manifest.Zpkg.Publisher)
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		m.Emitter.Emit("manager.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", manifest.Zpkg.Publisher))
+
+		return err
+	}
+
+	err = zpkg.NewSigner(filename, workPath).Sign(signer)
+	if err == nil {
+		m.Emitter.Emit("manager.info", fmt.Sprintf("Signed with key: %s", signer.Subject()))
+	}
+
+	return err
+}
+
 // TODO consider merging with Info command utilizing file path sniffing
 func (m *Manager) ZpkgInfo(path string) (string, error) {
 	reader := zpkg.NewReader(path, "")
@@ -1624,9 +2854,63 @@ func (m *Manager) ZpkgInfo(path string) (string, error) {
 		fmt.Sprint("Summary: ", pkg.Summary(), "\n") +
 		fmt.Sprint("Description: ", pkg.Description(), "\n")
 
+	if pkg.License() != "" {
+		info += fmt.Sprint("License: ", pkg.License(), "\n")
+
+		if reader.Manifest.License != nil && len(reader.Manifest.License.Files) > 0 {
+			info += fmt.Sprint("License Files: ", strings.Join(reader.Manifest.License.Files, ", "), "\n")
+		}
+	}
+
+	if prov := pkg.Provenance(); prov != nil {
+		for _, field := range [][]string{{"Source: ", prov.Source()}, {"Builder: ", prov.Builder}, {"Built: ", prov.BuildTime}} {
+			if field[1] != "" {
+				info += fmt.Sprint(field[0], field[1], "\n")
+			}
+		}
+	}
+
 	return info, nil
 }
 
+func (m *Manager) ZpkgLint(path string, workPath string, secure bool, severities map[string]string) (*lint.Report, error) {
+	reader := zpkg.NewReader(path, workPath)
+
+	err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	linter := lint.DefaultLinter()
+
+	if secure {
+		linter.Severity("non-root-owner", lint.SeverityError)
+	}
+
+	for name, level := range severities {
+		severity, err := lint.ParseSeverity(level)
+		if err != nil {
+			return nil, err
+		}
+
+		err = linter.Severity(name, severity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	source := payloadSource(reader, workPath)
+
+	return linter.Lint(&lint.Package{
+		Manifest: reader.Manifest,
+		Source: func(file *action.File) (io.ReadCloser, error) {
+			content, _, err := source(file)
+			return content, err
+		},
+	})
+}
+
 // TODO consider reworking into Manifest command utilizing file path sniffing
 func (m *Manager) ZpkgManifest(path string) (string, error) {
 	reader := zpkg.NewReader(path, "")
@@ -1645,6 +2929,27 @@ func (m *Manager) ZpkgManifest(path string) (string, error) {
 	return manifest.String(), nil
 }
 
+func (m *Manager) ZpkgSbom(path string, format string) (string, error) {
+	reader := zpkg.NewReader(path, "")
+
+	err := reader.Read()
+	if err != nil {
+		return "", err
+	}
+
+	pkg, err := zps.NewPkgFromManifest(reader.Manifest)
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := sbom.Generate(format, pkg.Id(), []*action.Manifest{reader.Manifest})
+	if err != nil {
+		return "", err
+	}
+
+	return string(doc), nil
+}
+
 func (m *Manager) ZpkgSign(path string, workPath string) error {
 	reader := zpkg.NewReader(path, workPath)
 
@@ -1654,27 +2959,20 @@ func (m *Manager) ZpkgSign(path string, workPath string) error {
 	}
 	reader.Close()
 
-	kp, err := m.security.KeyPair(reader.Manifest.Zpkg.Publisher)
+	signer, err := m.security.Signer(reader.Manifest.Zpkg.Publisher)
 	if err != nil {
 		return err
 	}
 
-	if kp == nil {
-		m.Emitter.Emit("manager.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", reader.Manifest.Zpkg.Publisher))
-
-		return err
-	}
-
-	signer := zpkg.NewSigner(path, workPath)
+	if signer == nil {
+		m.Emitter.Emit("manager.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", reader.Manifest.Zpkg.Publisher))
 
-	rsaKey, err := kp.RSAKey()
-	if err != nil {
 		return err
 	}
 
-	err = signer.Sign(kp.Fingerprint, rsaKey)
+	err = zpkg.NewSigner(path, workPath).Sign(signer)
 	if err == nil {
-		m.Emitter.Emit("manager.info", fmt.Sprintf("Signed with keypair: %s", kp.Subject))
+		m.Emitter.Emit("manager.info", fmt.Sprintf("Signed with key: %s", signer.Subject()))
 	}
 
 	return err
@@ -1685,6 +2983,179 @@ func (m *Manager) ZpkgValidate(path string) error {
 	return ValidateZpkg(m.Emitter, m.security, path, false)
 }
 
+// Resolves name@version arguments to the ids of packages published in a repo
+func (m *Manager) repoPackages(r *config.RepoConfig, args []string) ([]string, error) {
+	var reqs []*zps.Requirement
+	for _, arg := range args {
+		if !strings.Contains(arg, "@") {
+			return nil, errors.New("package version required: " + arg)
+		}
+
+		req, err := zps.NewRequirementFromSimpleString(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	pkgs, err := m.publishedPackages(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	found := make(map[int]bool)
+
+	for _, pkg := range pkgs {
+		for index, req := range reqs {
+			if pkg.Name() == req.Name && matchesVersion(pkg.Version(), req.Version) {
+				found[index] = true
+
+				if !containsId(ids, pkg.Id()) {
+					ids = append(ids, pkg.Id())
+				}
+			}
+		}
+	}
+
+	for index, arg := range args {
+		if !found[index] {
+			return nil, errors.New(fmt.Sprint("No packages found for ", arg, " in ", r.Publish.Name))
+		}
+	}
+
+	return ids, nil
+}
+
+// Published packages of a repo across all platforms, fetched to a scratch
+// cache so publishing never replaces the metadata installs resolve against
+func (m *Manager) publishedPackages(r *config.RepoConfig) ([]*zps.Pkg, error) {
+	workPath, err := ioutil.TempDir(m.config.WorkPath(), "publish")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workPath)
+
+	cache := NewCache(workPath)
+
+	fe := NewFetcher(r.Fetch.Uri, cache, m.security, m.config.CloudProvider(), true)
+	err = fe.Refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*zps.Pkg
+
+	for _, osarch := range zps.Platforms() {
+		metadata := NewMetadata(cache.GetMeta(osarch.String(), r.Fetch.Uri.String()))
+		if !metadata.Exists() {
+			continue
+		}
+
+		meta, err := metadata.All()
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, meta...)
+	}
+
+	return pkgs, nil
+}
+
+// A version without a timestamp matches every build of the same semver
+func matchesVersion(version *zps.Version, target *zps.Version) bool {
+	if target.Timestamp.IsZero() {
+		return version.Semver.EQ(target.Semver)
+	}
+
+	return version.EXQ(target)
+}
+
+// Adds packages matching the channel rules of a repo to their channels,
+// packages that stop matching stay until removed with zps channel remove
+func (m *Manager) channelSync(r *config.RepoConfig) error {
+	pkgs, err := m.publishedPackages(r)
+	if err != nil {
+		return err
+	}
+
+	var policies []*config.PolicyConfig
+	for _, policy := range r.Publish.Policies {
+		if len(policy.Channels) > 0 {
+			policies = append(policies, policy)
+		}
+	}
+
+	pb := NewPublisher(m.Emitter, m.security, m.config.WorkPath(), r.Publish.Uri, r.Publish.Name, r.Publish.Prune, r.Publish.Expires, r.Publish.Files)
+
+	for _, rule := range r.Publish.Channels {
+		var ids []string
+
+		for _, pkg := range pkgs {
+			if pkg.Yanked() || containsId(pkg.Channels(), rule.Name) || containsId(ids, pkg.Id()) {
+				continue
+			}
+
+			if !rule.Query.Match(pkg) {
+				continue
+			}
+
+			err = comply(policies, rule.Name, pkg)
+			if err != nil {
+				m.Emit("manager.warn", err.Error())
+				continue
+			}
+
+			ids = append(ids, pkg.Id())
+		}
+
+		if len(ids) == 0 {
+			continue
+		}
+
+		err = pb.Channel(rule.Name, ids...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check a package against the repo metadata before it is added to a channel
+func (m *Manager) channelComply(r *config.RepoConfig, id string, channel string) error {
+	var policies []*config.PolicyConfig
+	for _, policy := range r.Publish.Policies {
+		if len(policy.Channels) > 0 && policy.Applies(channel) {
+			policies = append(policies, policy)
+		}
+	}
+
+	if len(policies) == 0 {
+		return nil
+	}
+
+	pkgs, err := m.publishedPackages(r)
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		if pkg.Id() != id {
+			continue
+		}
+
+		err = comply(policies, channel, pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (m *Manager) image() (*zps.Repo, error) {
 	packages, err := m.state.Packages.All()
 	if err != nil {
@@ -1726,10 +3197,35 @@ func (m *Manager) fileRepos(files ...string) ([]*zps.Repo, error) {
 			return nil, err
 		}
 
+		err = comply(m.config.Policies, "", pkg)
+		if err != nil {
+			return nil, err
+		}
+
 		index[path].Add(pkg)
 	}
 
-	return repos, nil
+	return repos, nil
+}
+
+// Drop repo packages denied by the install policies
+func (m *Manager) compliant(pkgs []*zps.Pkg) []*zps.Pkg {
+	if len(m.config.Policies) == 0 {
+		return pkgs
+	}
+
+	var allowed []*zps.Pkg
+	for _, pkg := range pkgs {
+		err := comply(m.config.Policies, "", pkg)
+		if err != nil {
+			m.Emit("manager.warn", err.Error())
+			continue
+		}
+
+		allowed = append(allowed, pkg)
+	}
+
+	return allowed
 }
 
 func (m *Manager) getContext(phase string, options *provider.Options) context.Context {
@@ -1747,6 +3243,8 @@ func (m *Manager) pool(files ...string) (*zps.Pool, error) {
 		return nil, err
 	}
 
+	m.warnStaleCrls()
+
 	if len(files) > 0 {
 		repos, err = m.fileRepos(files...)
 		if err != nil {
@@ -1781,11 +3279,20 @@ func (m *Manager) pool(files ...string) (*zps.Pool, error) {
 					}
 				}
 
+				err = checkMetadata(m.cache, r.Fetch.Uri, osarch, metadata, true)
+				if err != nil {
+					if !m.allowStale {
+						return nil, errors.New(err.Error() + ", run zps refresh or pass --allow-stale-metadata")
+					}
+
+					m.Emit("manager.warn", err.Error())
+				}
+
 				meta, err := metadata.All()
 				if err != nil && !strings.Contains(err.Error(), "no such file") {
 					return nil, err
 				}
-				repo.Load(meta)
+				repo.Load(m.compliant(meta))
 
 				repos = append(repos, repo)
 			}
@@ -1810,6 +3317,81 @@ func (m *Manager) pool(files ...string) (*zps.Pool, error) {
 	return pool, nil
 }
 
+// Fetching a repo validates its config and metadata signatures, the fetch
+// goes to a scratch cache so a check never replaces cached metadata
+func (m *Manager) checkRepoMetadata(r *config.RepoConfig) error {
+	workPath, err := ioutil.TempDir(m.config.WorkPath(), "check")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workPath)
+
+	cache := NewCache(workPath)
+
+	fe := NewFetcher(r.Fetch.Uri, cache, m.security, m.config.CloudProvider(), true)
+	err = fe.Refresh()
+	if err != nil {
+		return fmt.Errorf("metadata for %s failed validation: %s", SafeURI(r.Fetch.Uri), err)
+	}
+
+	for _, osarch := range zps.Platforms() {
+		metadata := NewMetadata(cache.GetMeta(osarch.String(), r.Fetch.Uri.String()))
+		if !metadata.Exists() {
+			continue
+		}
+
+		err = checkMetadata(m.cache, r.Fetch.Uri, osarch, metadata, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Refuses metadata older than the highest version seen for the repo, or past
+// its expiry. Accepted versions are remembered when record is set
+func checkMetadata(cache *Cache, uri *url.URL, osarch *zps.OsArch, metadata *Metadata, record bool) error {
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
+	seen := seenMetadataVersion(cache, uri, osarch)
+
+	if info.Version < seen {
+		return &StaleMetadataError{Message: fmt.Sprintf("metadata for %s %s is version %d, older than previously seen version %d", SafeURI(uri), osarch.String(), info.Version, seen)}
+	}
+
+	if info.Expired() {
+		return &StaleMetadataError{Message: fmt.Sprintf("metadata for %s %s expired %s", SafeURI(uri), osarch.String(), info.Expires.Format(time.RFC3339)), Expired: true}
+	}
+
+	if record {
+		return recordMetadataVersion(cache, uri, osarch, info.Version)
+	}
+
+	return nil
+}
+
+func seenMetadataVersion(cache *Cache, uri *url.URL, osarch *zps.OsArch) uint64 {
+	var seen uint64
+	if content, err := ioutil.ReadFile(cache.GetMetaVersion(osarch.String(), uri.String())); err == nil {
+		seen, _ = strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	}
+
+	return seen
+}
+
+// Raises the highest version seen for the repo, it is never lowered
+func recordMetadataVersion(cache *Cache, uri *url.URL, osarch *zps.OsArch, version uint64) error {
+	if version <= seenMetadataVersion(cache, uri, osarch) {
+		return nil
+	}
+
+	return ioutil.WriteFile(cache.GetMetaVersion(osarch.String(), uri.String()), []byte(strconv.FormatUint(version, 10)+"\n"), 0640)
+}
+
 func (m *Manager) repoConfig(uri string) (map[string]string, error) {
 	configPath := m.cache.GetConfig(uri)
 
@@ -1968,3 +3550,358 @@ func (m *Manager) EmptyImage(imagePath string) error {
 
 	return nil
 }
+
+func comply(policies []*config.PolicyConfig, channel string, pkg *zps.Pkg) error {
+	for _, policy := range policies {
+		if !policy.Applies(channel) {
+			continue
+		}
+
+		err := zps.NewCompliance(policy.DenyLicenses, policy.AllowLicenses, policy.RequireProvenance).Check(pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Payload content is staged in the work path and verified
+func payloadSource(reader *zpkg.Reader, workPath string) oci.Source {
+	return func(file *action.File) (io.ReadCloser, int64, error) {
+		tmp, err := ioutil.TempFile(workPath, "payload")
+		if err != nil {
+			return nil, 0, err
+		}
+		tmp.Close()
+
+		digest, err := reader.Payload.Get(tmp.Name(), int64(file.Offset), int64(file.Size))
+		if err == nil && digest != file.Digest {
+			err = fmt.Errorf("digest mismatch for %s", file.Path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return nil, 0, err
+		}
+
+		content, err := os.Open(tmp.Name())
+		os.Remove(tmp.Name())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return content, int64(file.Size), nil
+	}
+}
+
+func readSource(source oci.Source, file *action.File) ([]byte, error) {
+	content, _, err := source(file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return ioutil.ReadAll(content)
+}
+
+// Revocation checks keep using a CRL past its next update, warn so a
+// current one gets imported
+func (m *Manager) warnStaleCrls() {
+	if m.security.Mode() == SecurityModeNone {
+		return
+	}
+
+	crls, err := m.pki.Crls.All()
+	if err != nil {
+		m.Emit("manager.warn", fmt.Sprint("unable to read crls: ", err))
+		return
+	}
+
+	for _, entry := range crls {
+		if !entry.NextUpdate.IsZero() && time.Now().After(entry.NextUpdate) {
+			m.Emit("manager.warn", fmt.Sprintf("crl for '%s' is stale since %s, import a current crl", entry.Subject, entry.NextUpdate.Format(time.RFC3339)))
+		}
+	}
+}
+
+// Revoked when any stored CRL from the certificate issuer lists it
+func revocationStatus(entry *CertEntry, crls []*CrlEntry) (string, error) {
+	cert, err := parseCertEntry(entry)
+	if err != nil {
+		return "", err
+	}
+
+	for _, crlEntry := range crls {
+		crl, err := sec.ParseCrl(crlEntry.Crl)
+		if err != nil {
+			return "", err
+		}
+
+		if sec.CrlRevokes(crl, cert) {
+			return "[red]revoked", nil
+		}
+	}
+
+	return "valid", nil
+}
+
+func loadIssuer(certPath string, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	if certPath == "" || keyPath == "" {
+		return nil, nil, errors.New("issuing ca cert and key required")
+	}
+
+	certPem, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPem, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sec.LoadIssuer(certPem, keyPem)
+}
+
+// Writes name.pem when cert is set and name.key, existing files are never replaced
+func writePki(outPath string, name string, certPem []byte, key crypto.Signer) error {
+	keyPem, err := sec.MarshalPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(outPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	if certPem != nil {
+		err = writeNewFile(filepath.Join(outPath, name+".pem"), certPem, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeNewFile(filepath.Join(outPath, name+".key"), keyPem, 0600)
+}
+
+func writeNewFile(path string, content []byte, mode os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (m *Manager) Owns(paths []string) ([]string, error) {
+	err := m.lock.TryLock()
+	if err != nil {
+		return nil, errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	image, err := m.image()
+	if err != nil {
+		return nil, err
+	}
+
+	installedIds := make(map[string]string)
+	for _, pkg := range image.Solvables() {
+		installedIds[pkg.Name()] = pkg.Id()
+	}
+
+	var output []string
+
+	for _, p := range paths {
+		target := imagePath(m.config.CurrentImage.Path, p)
+
+		var entries []*FsEntry
+
+		if action.IsGlob(target) {
+			all, err := m.state.Objects.All()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, entry := range all {
+				if action.MatchGlob(target, entry.Path) {
+					entries = append(entries, entry)
+				}
+			}
+		} else {
+			entries, err = m.state.Objects.Get(target)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(entries) == 0 {
+			m.Emit("manager.warn", fmt.Sprintf("%s not owned by any package", p))
+			continue
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Path == entries[j].Path {
+				return entries[i].Pkg < entries[j].Pkg
+			}
+
+			return entries[i].Path < entries[j].Path
+		})
+
+		for _, entry := range entries {
+			id := installedIds[entry.Pkg]
+			if id == "" {
+				id = entry.Pkg
+			}
+
+			output = append(output, strings.Join([]string{"/" + entry.Path, id, entry.Type}, "|"))
+		}
+	}
+
+	if len(output) == 0 {
+		return nil, errors.New("No installed package owns " + strings.Join(paths, ", "))
+	}
+
+	return output, nil
+}
+
+func (m *Manager) Provides(patterns []string) ([]string, error) {
+	err := m.lock.TryLock()
+	if err != nil {
+		return nil, errors.New("zpm: locked by another process")
+	}
+	defer m.lock.Unlock()
+
+	matches := make(map[string][]string)
+	versions := make(map[string]*zps.Version)
+	listed := false
+
+	for _, r := range m.config.Repos {
+		if !r.Enabled {
+			continue
+		}
+
+		if !m.cache.HasMeta(r.Fetch.Uri.String()) {
+			m.Emit("manager.warn", fmt.Sprintf("missing metadata: %s", SafeURI(r.Fetch.Uri)))
+			continue
+		}
+
+		repoName := SafeURI(r.Fetch.Uri)
+		if repoConfig, _ := m.repoConfig(r.Fetch.Uri.String()); repoConfig != nil && repoConfig["name"] != "" {
+			repoName = repoConfig["name"]
+		}
+
+		for _, osarch := range zps.Platforms() {
+			metadata := NewMetadata(m.cache.GetMeta(osarch.String(), r.Fetch.Uri.String()))
+			if !metadata.Exists() {
+				continue
+			}
+
+			// Validate metadata signature
+			if m.security.Mode() != SecurityModeNone {
+				err := ValidateFileSignature(m.security, m.cache.GetMeta(osarch.String(), r.Fetch.Uri.String()), m.cache.GetMetaSig(osarch.String(), r.Fetch.Uri.String()))
+				if err != nil {
+					m.Emit("manager.error", fmt.Sprintf("invalid metadata signature: %s", SafeURI(r.Fetch.Uri)))
+					continue
+				}
+			}
+
+			err = checkMetadata(m.cache, r.Fetch.Uri, osarch, metadata, false)
+			if err != nil {
+				m.Emit("manager.warn", err.Error())
+
+				if !m.allowStale {
+					continue
+				}
+			}
+
+			meta, err := metadata.All()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, pkg := range meta {
+				if len(pkg.Files()) > 0 {
+					listed = true
+				}
+
+				if pkg.Yanked() {
+					continue
+				}
+
+				var files []string
+				for _, file := range pkg.Files() {
+					if providesMatch(patterns, file) {
+						files = append(files, file)
+					}
+				}
+
+				if len(files) == 0 {
+					continue
+				}
+
+				// Only the latest matching version of a package per repo and platform
+				key := strings.Join([]string{repoName, pkg.Os(), pkg.Arch(), pkg.Name()}, "|")
+				if version, ok := versions[key]; ok && !pkg.Version().GT(version) {
+					continue
+				}
+
+				versions[key] = pkg.Version()
+				matches[key] = nil
+
+				for _, file := range files {
+					matches[key] = append(matches[key], strings.Join([]string{"/" + file, pkg.Id(), pkg.Os() + "-" + pkg.Arch(), repoName}, "|"))
+				}
+			}
+		}
+	}
+
+	if !listed {
+		m.Emit("manager.warn", "no repository metadata carries file lists, enable files = true in repo publish config")
+	}
+
+	var output []string
+	for _, rows := range matches {
+		output = append(output, rows...)
+	}
+
+	sort.Strings(output)
+
+	return output, nil
+}
+
+// Patterns without a slash match file base names, like zpkgignore
+func providesMatch(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "/")
+
+		name := file
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(file)
+		}
+
+		if action.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Image relative manifest path for an absolute or image relative path
+func imagePath(root string, p string) string {
+	p = filepath.Clean(p)
+
+	if root != "" && root != "/" && strings.HasPrefix(p, root+"/") {
+		p = strings.TrimPrefix(p, root)
+	}
+
+	return strings.TrimPrefix(p, "/")
+}
@@ -11,8 +11,13 @@
 package zpm
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/asdine/storm"
@@ -20,6 +25,32 @@ import (
 	bolt "go.etcd.io/bbolt"
 )
 
+// Publishers write each metadata generation under its own name and then
+// switch this pointer, clients never see a db without its signature.
+// The plain metadata.db and metadata.sig copies kept for older clients are
+// two separate writes, an older client fetching between them sees a
+// mismatched pair and fails validation until its next refresh
+const MetadataPointer = "metadata.current"
+
+var metadataGenerationPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}\.[0-9]{9}Z$`)
+
+// Version and expiry signed along with the packages, both are zero for
+// metadata published before they were introduced
+type MetadataInfo struct {
+	Version uint64
+	Expires time.Time
+}
+
+// Metadata rolled back below the version previously seen, or expired
+type StaleMetadataError struct {
+	Message string
+	Expired bool
+}
+
+func (s *StaleMetadataError) Error() string {
+	return s.Message
+}
+
 type Metadata struct {
 	Path     string
 	Packages *MetadataPackages
@@ -79,6 +110,33 @@ func (m *Metadata) All() ([]*zps.Pkg, error) {
 	return packages, nil
 }
 
+func (m *Metadata) Info() (*MetadataInfo, error) {
+	db, err := m.getDb()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	info := &MetadataInfo{}
+
+	err = db.Get("info", "metadata", info)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (m *Metadata) SetInfo(info *MetadataInfo) error {
+	db, err := m.getDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Set("info", "metadata", info)
+}
+
 func (m *Metadata) Empty() error {
 	return os.RemoveAll(m.Path)
 }
@@ -147,9 +205,9 @@ func (m *MetadataChannels) Add(id string, channel string) error {
 	}
 	defer db.Close()
 
-	var entry *zps.PkgEntry
+	entry := &zps.PkgEntry{}
 
-	err = db.One("Id", id, &entry)
+	err = db.One("Id", id, entry)
 	if err != nil {
 		if err == storm.ErrNotFound {
 			return nil
@@ -177,9 +235,9 @@ func (m *MetadataChannels) Remove(id string, channel string) error {
 	}
 	defer db.Close()
 
-	var entry *zps.PkgEntry
+	entry := &zps.PkgEntry{}
 
-	err = db.One("Id", id, &entry)
+	err = db.One("Id", id, entry)
 	if err != nil {
 		if err == storm.ErrNotFound {
 			return nil
@@ -230,3 +288,75 @@ func (m *MetadataChannels) List() ([]string, error) {
 
 	return channels, nil
 }
+
+func (i *MetadataInfo) Expired() bool {
+	return !i.Expires.IsZero() && time.Now().After(i.Expires)
+}
+
+// Generation names sort by publish time
+func NewMetadataGeneration() string {
+	return time.Now().UTC().Format("20060102T150405.000000000Z")
+}
+
+// Metadata db and sig file names for a generation, repos published
+// before generations were introduced use the plain names
+func MetadataFiles(generation string) (string, string) {
+	if generation == "" {
+		return "metadata.db", "metadata.sig"
+	}
+
+	return "metadata-" + generation + ".db", "metadata-" + generation + ".sig"
+}
+
+// Reads the metadata pointer in a local repo dir, empty when missing
+func readMetadataPointer(dir string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, MetadataPointer))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return ParseMetadataPointer(content)
+}
+
+func ParseMetadataPointer(content []byte) (string, error) {
+	generation := strings.TrimSpace(string(content))
+
+	if !metadataGenerationPattern.MatchString(generation) {
+		return "", errors.New("invalid metadata pointer: " + generation)
+	}
+
+	return generation, nil
+}
+
+// Generation files among names that do not belong to the kept generations
+func StaleMetadataFiles(names []string, keep ...string) []string {
+	var stale []string
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "metadata-") {
+			continue
+		}
+
+		generation := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "metadata-"), ".db"), ".sig")
+		if !metadataGenerationPattern.MatchString(generation) {
+			continue
+		}
+
+		kept := false
+		for _, k := range keep {
+			if generation == k {
+				kept = true
+			}
+		}
+
+		if !kept {
+			stale = append(stale, name)
+		}
+	}
+
+	return stale
+}
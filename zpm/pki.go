@@ -11,14 +11,12 @@
 package zpm
 
 import (
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
-	"errors"
+	"crypto"
 	"path/filepath"
 	"time"
 
 	"github.com/asdine/storm"
+	"github.com/fezz-io/zps/sec"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -33,6 +31,7 @@ type Pki struct {
 	Path         string
 	Certificates *PkiCertificates
 	KeyPairs     *PkiKeyPairs
+	Crls         *PkiCrls
 }
 
 type PkiCertificates struct {
@@ -43,6 +42,10 @@ type PkiKeyPairs struct {
 	getDb func() (*storm.DB, error)
 }
 
+type PkiCrls struct {
+	getDb func() (*storm.DB, error)
+}
+
 type CertEntry struct {
 	Fingerprint string `storm:"id"`
 	Subject     string `storm:"index"`
@@ -51,6 +54,16 @@ type CertEntry struct {
 	Cert        []byte
 }
 
+// Latest CRL per issuer, keyed by the issuing certificate fingerprint
+type CrlEntry struct {
+	Issuer     string `storm:"id"`
+	Subject    string
+	Number     string
+	ThisUpdate time.Time
+	NextUpdate time.Time
+	Crl        []byte
+}
+
 type KeyPairEntry struct {
 	Fingerprint string `storm:"id"`
 	Subject     string `storm:"index"`
@@ -67,6 +80,9 @@ func NewPki(path string) *Pki {
 	pki.KeyPairs = &PkiKeyPairs{}
 	pki.KeyPairs.getDb = pki.getDb
 
+	pki.Crls = &PkiCrls{}
+	pki.Crls.getDb = pki.getDb
+
 	return pki
 }
 
@@ -322,11 +338,72 @@ func (p *PkiKeyPairs) Put(fingerprint string, subject string, publisher string,
 	return err
 }
 
-func (k *KeyPairEntry) RSAKey() (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode(k.Key)
-	if block == nil {
-		return nil, errors.New("failed to decode key")
+func (p *PkiCrls) All() ([]*CrlEntry, error) {
+	db, err := p.getDb()
+	if err != nil {
+		return nil, err
 	}
+	defer db.Close()
+
+	var entries []*CrlEntry
+
+	err = db.All(&entries)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return entries, err
+}
+
+func (p *PkiCrls) Get(issuer string) (*CrlEntry, error) {
+	db, err := p.getDb()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var entry CrlEntry
+
+	err = db.One("Issuer", issuer, &entry)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &entry, err
+}
+
+func (p *PkiCrls) Del(issuer string) error {
+	db, err := p.getDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&CrlEntry{Issuer: issuer})
+
+	return err
+}
+
+func (p *PkiCrls) Put(entry *CrlEntry) error {
+	db, err := p.getDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Save(entry)
+	return err
+}
 
-	return x509.ParsePKCS1PrivateKey(block.Bytes)
+// RSA, ECDSA P-256 or Ed25519 private key
+func (k *KeyPairEntry) PrivateKey() (crypto.Signer, error) {
+	return sec.ParsePrivateKey(k.Key)
 }
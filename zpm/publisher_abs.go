@@ -20,6 +20,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/chuckpreslar/emission"
@@ -39,9 +40,11 @@ type ABSPublisher struct {
 
 	workPath string
 
-	uri   *url.URL
-	name  string
-	prune int
+	uri     *url.URL
+	name    string
+	prune   int
+	expires time.Duration
+	files   bool
 
 	account   string
 	container string
@@ -51,7 +54,7 @@ type ABSPublisher struct {
 	containerClient *containers.Client
 }
 
-func NewABSPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int) *ABSPublisher {
+func NewABSPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int, expires time.Duration, files bool) *ABSPublisher {
 	authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource("https://storage.azure.com/")
 	if err != nil {
 		authorizer, err = auth.NewAuthorizerFromCLIWithResource("https://storage.azure.com/")
@@ -73,6 +76,8 @@ func NewABSPublisher(emitter *emission.Emitter, security Security, workPath stri
 		uri,
 		name,
 		prune,
+		expires,
+		files,
 		cloud.AzureStorageAccountFromURL(uri),
 		cloud.AzureBlobContainerFromURL(uri),
 		cloud.AzureBlobObjectPrefixFromURL(uri),
@@ -139,20 +144,15 @@ func (a *ABSPublisher) Init() error {
 	}
 
 	// Sign and upload
-	keyPair, err := a.security.KeyPair(PublisherFromUri(a.uri))
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(a.uri)))
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
@@ -209,50 +209,75 @@ func (a *ABSPublisher) Update() error {
 	}
 
 	// Sign and upload
-	keyPair, err := a.security.KeyPair(PublisherFromUri(a.uri))
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(a.uri)))
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
 
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		configSig, err := os.Open(sigPath)
 		if err != nil {
 			return err
 		}
 
-		configSig, err := os.Open(sigPath)
+		err = a.blobClient.PutBlockBlobFromFile(context.Background(), a.account, a.container, path.Join(a.path, "config.sig"), configSig, blobs.PutBlockBlobInput{})
+		if err != nil {
+			return errors.New(fmt.Sprintf("unable to upload: %s", a.uri.Path))
+		}
+	}
+
+	// Re-sign metadata with a new version, extending its expiry
+	for _, osarch := range zps.Platforms() {
+		err = a.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return true, nil
+		})
 		if err != nil {
 			return err
 		}
+	}
 
-		err = a.blobClient.PutBlockBlobFromFile(context.Background(), a.account, a.container, path.Join(a.path, "config.sig"), configSig, blobs.PutBlockBlobInput{})
+	return nil
+}
+
+func (a *ABSPublisher) Channel(channel string, pkgs ...string) error {
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err := a.channel(osarch, channel, true, pkgs, signer)
 		if err != nil {
-			return errors.New(fmt.Sprintf("unable to upload: %s", a.uri.Path))
+			return err
 		}
 	}
 
-	return err
+	return nil
 }
 
-func (a *ABSPublisher) Channel(pkg string, channel string) error {
-	keyPair, err := a.security.KeyPair(PublisherFromUri(a.uri))
+func (a *ABSPublisher) Unchannel(channel string, pkgs ...string) error {
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(a.uri)))
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
-		err := a.channel(osarch, pkg, channel, keyPair)
+		err := a.channel(osarch, channel, false, pkgs, signer)
 		if err != nil {
 			return err
 		}
@@ -276,22 +301,26 @@ func (a *ABSPublisher) Publish(pkgs ...string) error {
 			return err
 		}
 
+		if a.files {
+			pkg.SetFiles(packageFiles(reader.Manifest)...)
+		}
+
 		zpkgs[file] = pkg
 	}
 
-	keyPair, err := a.security.KeyPair(PublisherFromUri(a.uri))
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(a.uri)))
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
 		pkgFiles, pkgs := FilterPackagesByArch(osarch, zpkgs)
 		if len(pkgFiles) > 0 {
-			err := a.publish(osarch, pkgFiles, pkgs, keyPair)
+			err := a.publish(osarch, pkgFiles, pkgs, signer)
 			if err != nil {
 				return err
 			}
@@ -301,78 +330,206 @@ func (a *ABSPublisher) Publish(pkgs ...string) error {
 	return nil
 }
 
-func (a *ABSPublisher) channel(osarch *zps.OsArch, pkg string, channel string, keyPair *KeyPairEntry) error {
-	tmpDir, err := ioutil.TempDir(a.workPath, "channel")
+func (a *ABSPublisher) Remove(pkgs ...string) error {
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
 	if err != nil {
 		return err
 	}
 
-	defer os.RemoveAll(tmpDir)
-
-	metaPath := filepath.Join(tmpDir, "metadata.db")
-	sigPath := filepath.Join(tmpDir, "metadata.sig")
-
-	// Download metadata db
-	err = a.chunkedGet(path.Join(a.path, osarch.String(), "metadata.db"), metaPath)
-	if err != nil {
-		return errors.New(fmt.Sprintf("unable to download: %s", a.uri.Path))
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
 	}
 
-	// Modify metadata
-	metadata := NewMetadata(metaPath)
-	meta, err := metadata.All()
-	if err != nil {
-		return err
-	}
+	for _, osarch := range zps.Platforms() {
+		var files []string
+
+		err = a.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var err error
 
-	if len(meta) > 0 {
-		err = metadata.Channels.Add(pkg, channel)
+			files, err = removePackages(metadata, pkgs)
+			return len(files) > 0, err
+		})
 		if err != nil {
 			return err
 		}
 
-		a.Emit("publisher.channel", pkg)
+		// Files go once the metadata no longer references them
+		for _, file := range files {
+			_, err = a.blobClient.Delete(context.Background(), a.account, a.container, path.Join(a.path, osarch.String(), file), blobs.DeleteInput{
+				DeleteSnapshots: true,
+			})
+			if err != nil {
+				return err
+			}
+			a.Emit("publisher.remove", file)
+		}
 	}
 
-	// Upload metadata
-	metadataDb, err := os.Create(metaPath)
+	return nil
+}
+
+func (a *ABSPublisher) Yank(yanked bool, pkgs ...string) error {
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
 	if err != nil {
 		return err
 	}
-	defer metadataDb.Close()
 
-	err = a.blobClient.PutBlockBlobFromFile(context.Background(), a.account, a.container, path.Join(a.path, osarch.String(), "metadata.db"), metadataDb, blobs.PutBlockBlobInput{})
-	if err != nil {
-		return err
+	if signer == nil {
+		a.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(a.uri)))
 	}
 
-	// Sign and upload
-	if keyPair != nil {
-		rsaKey, err := keyPair.RSAKey()
+	for _, osarch := range zps.Platforms() {
+		err = a.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return yankPackages(metadata, yanked, pkgs)
+		})
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+func (a *ABSPublisher) Check(fix bool) (int, int, error) {
+	signer, err := a.security.Signer(PublisherFromUri(a.uri))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	problems, unresolved := 0, 0
+
+	for _, osarch := range zps.Platforms() {
+		prefix := path.Join(a.path, osarch.String())
+
+		var check *packageCheck
+
+		err = a.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var files []string
+			var marker *string
+
+			listPrefix := prefix + "/"
+
+			for {
+				objects, err := a.containerClient.ListBlobs(context.Background(), a.account, a.container, containers.ListBlobsInput{
+					Prefix: &listPrefix,
+					Marker: marker,
+				})
+				if err != nil {
+					return false, err
+				}
+
+				for _, obj := range objects.Blobs.Blobs {
+					if path.Dir(obj.Name) == prefix && path.Ext(obj.Name) == ".zpkg" {
+						files = append(files, path.Base(obj.Name))
+					}
+				}
+
+				if objects.NextMarker == nil || *objects.NextMarker == "" {
+					break
+				}
+				marker = objects.NextMarker
+			}
 
-		err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+			var err error
+
+			check, err = checkPackages(a.Emitter, a.security, a.workPath, osarch, metadata, files, func(file string, dest string) error {
+				return a.chunkedGet(path.Join(prefix, file), dest)
+			}, a.files, fix)
+			if err != nil {
+				return false, err
+			}
+
+			return check.changed, nil
+		})
 		if err != nil {
-			return err
+			return 0, 0, err
 		}
 
-		metadataSig, err := os.Open(sigPath)
-		if err != nil {
-			return err
+		if check == nil {
+			continue
+		}
+
+		for _, file := range check.deletes {
+			_, err = a.blobClient.Delete(context.Background(), a.account, a.container, path.Join(prefix, file), blobs.DeleteInput{
+				DeleteSnapshots: true,
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+			a.Emit("publisher.remove", file)
 		}
 
-		err = a.blobClient.PutBlockBlobFromFile(context.Background(), a.account, a.container, path.Join(a.path, osarch.String(), "metadata.sig"), metadataSig, blobs.PutBlockBlobInput{})
+		problems += check.problems
+		unresolved += check.unresolved
+	}
+
+	return problems, unresolved, nil
+}
+
+func (a *ABSPublisher) channel(osarch *zps.OsArch, channel string, add bool, pkgs []string, signer sec.Signer) error {
+	return a.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+		changed, err := channelPackages(metadata, channel, add, pkgs)
 		if err != nil {
-			return err
+			return false, err
+		}
+
+		for _, pkg := range changed {
+			if add {
+				a.Emit("publisher.channel", fmt.Sprint(channel, " ", pkg))
+			} else {
+				a.Emit("publisher.unchannel", fmt.Sprint(channel, " ", pkg))
+			}
 		}
+
+		return len(changed) > 0, nil
+	})
+}
+
+// Applies fn to a downloaded copy of the current metadata, the copy is
+// committed as the next generation when fn reports a change
+func (a *ABSPublisher) modify(osarch *zps.OsArch, signer sec.Signer, fn func(metadata *Metadata) (bool, error)) error {
+	tmpDir, err := ioutil.TempDir(a.workPath, "modify")
+	if err != nil {
+		return err
 	}
 
-	return err
+	defer os.RemoveAll(tmpDir)
+
+	metaPath := filepath.Join(tmpDir, "metadata.db")
+
+	generation, err := absMetadataPointer(a.blobClient, a.account, a.container, path.Join(a.path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+
+	// Download metadata db
+	err = a.chunkedGet(path.Join(a.path, osarch.String(), current), metaPath)
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return nil
+		}
+
+		return errors.New(fmt.Sprintf("unable to download: %s", a.uri.Path))
+	}
+
+	metadata := NewMetadata(metaPath)
+
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
+	changed, err := fn(metadata)
+	if err != nil || !changed {
+		return err
+	}
+
+	return a.commit(osarch, metaPath, generation, info.Version+1, signer)
 }
 
-func (a *ABSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, keyPair *KeyPairEntry) error {
+func (a *ABSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, signer sec.Signer) error {
 	tmpDir, err := ioutil.TempDir(a.workPath, "publish")
 	if err != nil {
 		return err
@@ -381,10 +538,16 @@ func (a *ABSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 	defer os.RemoveAll(tmpDir)
 
 	metaPath := filepath.Join(tmpDir, "metadata.db")
-	sigPath := filepath.Join(tmpDir, "metadata.sig")
+
+	generation, err := absMetadataPointer(a.blobClient, a.account, a.container, path.Join(a.path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
 
 	// Download metadata db
-	err = a.chunkedGet(path.Join(a.path, osarch.String(), "metadata.db"), metaPath)
+	err = a.chunkedGet(path.Join(a.path, osarch.String(), current), metaPath)
 
 	if err != nil {
 		if !strings.Contains(err.Error(), "404") {
@@ -401,6 +564,11 @@ func (a *ABSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 	}
 	repo.Load(meta)
 
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
 	rejects := repo.Add(zpkgs...)
 	rejectIndex := make(map[string]bool)
 
@@ -441,8 +609,7 @@ func (a *ABSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 			}
 		}
 
-		// TODO Rewrite instead of atomic update for now
-
+		// Rebuild the local copy, the repo switches over in commit
 		metadata.Empty()
 
 		for _, pkg := range repo.Solvables() {
@@ -452,43 +619,102 @@ func (a *ABSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 			}
 		}
 
-		// Upload metadata
-		metadataUp, err := os.Open(metaPath)
+		err = a.commit(osarch, metaPath, generation, info.Version+1, signer)
+	}
+
+	return err
+}
+
+// Stamps metaPath and uploads it with its signature as a new generation, then
+// the pointer to it. The plain metadata names are rewritten afterwards for older
+// clients, generations other than the new and previous one are removed
+func (a *ABSPublisher) commit(osarch *zps.OsArch, metaPath string, previous string, version uint64, signer sec.Signer) error {
+	var err error
+
+	prefix := path.Join(a.path, osarch.String())
+	next := NewMetadataGeneration()
+	metaName, sigName := MetadataFiles(next)
+	legacyMeta, legacySig := MetadataFiles("")
+	sigPath := metaPath + ".sig"
+
+	err = stampMetadata(metaPath, version, a.expires)
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = sec.SecuritySignFile(metaPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
-		defer metadataUp.Close()
+	}
+
+	err = a.upload(metaPath, path.Join(prefix, metaName))
+	if err == nil && signer != nil {
+		err = a.upload(sigPath, path.Join(prefix, sigName))
+	}
+
+	if err == nil {
+		pointer := []byte(next + "\n")
 
-		err = a.blobClient.PutBlockBlobFromFile(context.Background(), a.account, a.container, path.Join(a.path, osarch.String(), "metadata.db"), metadataUp, blobs.PutBlockBlobInput{})
+		_, err = a.blobClient.PutBlockBlob(context.Background(), a.account, a.container, path.Join(prefix, MetadataPointer), blobs.PutBlockBlobInput{
+			Content: &pointer,
+		})
+	}
+
+	if err != nil {
+		a.discard(prefix, next)
+		return err
+	}
+
+	err = a.upload(metaPath, path.Join(prefix, legacyMeta))
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = a.upload(sigPath, path.Join(prefix, legacySig))
 		if err != nil {
 			return err
 		}
+	}
 
-		// Sign and upload
-		if keyPair != nil {
-			rsaKey, err := keyPair.RSAKey()
-			if err != nil {
-				return err
-			}
+	stalePrefix := path.Join(prefix, "metadata-")
 
-			err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
-			if err != nil {
-				return err
-			}
+	objects, err := a.containerClient.ListBlobs(context.Background(), a.account, a.container, containers.ListBlobsInput{
+		Prefix: &stalePrefix,
+	})
+	if err != nil {
+		return err
+	}
 
-			metadataSig, err := os.Open(sigPath)
-			if err != nil {
-				return err
-			}
+	var names []string
+	for _, obj := range objects.Blobs.Blobs {
+		names = append(names, path.Base(obj.Name))
+	}
 
-			err = a.blobClient.PutBlockBlobFromFile(context.Background(), a.account, a.container, path.Join(a.path, osarch.String(), "metadata.sig"), metadataSig, blobs.PutBlockBlobInput{})
-			if err != nil {
-				return err
-			}
+	for _, name := range StaleMetadataFiles(names, next, previous) {
+		_, err = a.blobClient.Delete(context.Background(), a.account, a.container, path.Join(prefix, name), blobs.DeleteInput{
+			DeleteSnapshots: true,
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	return err
+	return nil
+}
+
+// Removes a generation the pointer was never switched to, leftovers are
+// also removed by the next commit
+func (a *ABSPublisher) discard(prefix string, generation string) {
+	metaName, sigName := MetadataFiles(generation)
+
+	for _, name := range []string{metaName, sigName} {
+		a.blobClient.Delete(context.Background(), a.account, a.container, path.Join(prefix, name), blobs.DeleteInput{
+			DeleteSnapshots: true,
+		})
+	}
 }
 
 func (a *ABSPublisher) upload(file string, dest string) error {
@@ -11,29 +11,288 @@
 package zpm
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/chuckpreslar/emission"
+	"github.com/fezz-io/zps/action"
+	"github.com/fezz-io/zps/zpkg"
+	"github.com/fezz-io/zps/zps"
 )
 
 type Publisher interface {
 	Init() error
 	Update() error
-	Channel(pkg string, channel string) error
+	Channel(channel string, pkgs ...string) error
+	Unchannel(channel string, pkgs ...string) error
 	Publish(...string) error
+	Remove(pkgs ...string) error
+	Yank(yanked bool, pkgs ...string) error
+
+	// Reports the problems found and those left unresolved
+	Check(fix bool) (int, int, error)
 }
 
-func NewPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int) Publisher {
+func NewPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int, expires time.Duration, files bool) Publisher {
 	switch uri.Scheme {
 	case "file":
-		return NewFilePublisher(emitter, security, uri, name, prune)
+		return NewFilePublisher(emitter, security, uri, name, prune, expires, files)
 	case "abs":
-		return NewABSPublisher(emitter, security, workPath, uri, name, prune)
+		return NewABSPublisher(emitter, security, workPath, uri, name, prune, expires, files)
 	case "gcs":
-		return NewGCSPublisher(emitter, security, workPath, uri, name, prune)
+		return NewGCSPublisher(emitter, security, workPath, uri, name, prune, expires, files)
+	case "https":
+		return NewHttpsPublisher(emitter, security, workPath, uri, name, prune, expires, files)
 	case "s3":
-		return NewS3Publisher(emitter, security, workPath, uri, name, prune)
+		return NewS3Publisher(emitter, security, workPath, uri, name, prune, expires, files)
 	default:
 		return nil
 	}
 }
+
+// Stamps metadata with its version and expiry before it is signed
+func stampMetadata(metaPath string, version uint64, expires time.Duration) error {
+	info := &MetadataInfo{Version: version}
+
+	if expires > 0 {
+		info.Expires = time.Now().UTC().Add(expires)
+	}
+
+	return NewMetadata(metaPath).SetInfo(info)
+}
+
+// Deletes the metadata entries of pkgs, returning the package files to remove
+func removePackages(metadata *Metadata, pkgs []string) ([]string, error) {
+	meta, err := metadata.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, pkg := range meta {
+		if !containsId(pkgs, pkg.Id()) {
+			continue
+		}
+
+		err = metadata.Del(pkg.Id())
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, pkg.FileName())
+	}
+
+	return files, nil
+}
+
+// Marks the metadata entries of pkgs yanked, reporting whether any changed
+func yankPackages(metadata *Metadata, yanked bool, pkgs []string) (bool, error) {
+	meta, err := metadata.All()
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, pkg := range meta {
+		if !containsId(pkgs, pkg.Id()) || pkg.Yanked() == yanked {
+			continue
+		}
+
+		pkg.SetYanked(yanked)
+
+		err = metadata.Put(pkg)
+		if err != nil {
+			return false, err
+		}
+
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// Adds pkgs to or removes them from a channel, returning the ids that changed
+func channelPackages(metadata *Metadata, channel string, add bool, pkgs []string) ([]string, error) {
+	meta, err := metadata.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, pkg := range meta {
+		if !containsId(pkgs, pkg.Id()) || containsId(pkg.Channels(), channel) == add {
+			continue
+		}
+
+		if add {
+			err = metadata.Channels.Add(pkg.Id(), channel)
+		} else {
+			err = metadata.Channels.Remove(pkg.Id(), channel)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		changed = append(changed, pkg.Id())
+	}
+
+	return changed, nil
+}
+
+type packageCheck struct {
+	changed bool
+	deletes []string
+
+	problems   int
+	unresolved int
+}
+
+// Compares the metadata of a platform with the package files present.
+// With fix, entries without a file are dropped and orphaned files are
+// re-added when they validate or deleted when they do not. Referenced
+// packages that fail validation are only reported
+func checkPackages(emitter *emission.Emitter, security Security, workPath string, osarch *zps.OsArch, metadata *Metadata, files []string, fetch func(file string, dest string) error, fileLists bool, fix bool) (*packageCheck, error) {
+	meta, err := metadata.All()
+	if err != nil {
+		return nil, err
+	}
+
+	check := &packageCheck{}
+	entries := make(map[string]*zps.Pkg)
+
+	for _, pkg := range meta {
+		entries[pkg.FileName()] = pkg
+
+		if !containsId(files, pkg.FileName()) {
+			emitter.Emit("publisher.check", fmt.Sprint(osarch, "/", pkg.FileName(), " missing"))
+			check.problems++
+
+			if fix {
+				err = metadata.Del(pkg.Id())
+				if err != nil {
+					return nil, err
+				}
+
+				check.changed = true
+			} else {
+				check.unresolved++
+			}
+
+			continue
+		}
+
+		_, err = checkPackage(security, workPath, pkg.FileName(), fetch, false)
+		if err != nil {
+			emitter.Emit("publisher.check", fmt.Sprint(osarch, "/", pkg.FileName(), " invalid: ", err))
+			check.problems++
+			check.unresolved++
+		}
+	}
+
+	for _, file := range files {
+		if entries[file] != nil {
+			continue
+		}
+
+		check.problems++
+
+		pkg, err := checkPackage(security, workPath, file, fetch, fileLists)
+		if err != nil {
+			emitter.Emit("publisher.check", fmt.Sprint(osarch, "/", file, " orphaned, invalid: ", err))
+		} else {
+			emitter.Emit("publisher.check", fmt.Sprint(osarch, "/", file, " orphaned"))
+		}
+
+		if !fix {
+			check.unresolved++
+			continue
+		}
+
+		if err != nil {
+			check.deletes = append(check.deletes, file)
+			continue
+		}
+
+		err = metadata.Put(pkg)
+		if err != nil {
+			return nil, err
+		}
+
+		emitter.Emit("publisher.restore", file)
+		check.changed = true
+	}
+
+	return check, nil
+}
+
+// Validates the signature and payload of a package file against its name,
+// the returned package carries its file list when fileLists is set
+func checkPackage(security Security, workPath string, file string, fetch func(file string, dest string) error, fileLists bool) (*zps.Pkg, error) {
+	tmp, err := ioutil.TempFile(workPath, "check")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	err = fetch(file, tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateZpkg(&emission.Emitter{}, security, tmp.Name(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := zpkg.NewReader(tmp.Name(), "")
+
+	err = reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	pkg, err := zps.NewPkgFromManifest(reader.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkg.FileName() != file {
+		return nil, errors.New("manifest does not match file name")
+	}
+
+	if fileLists {
+		pkg.SetFiles(packageFiles(reader.Manifest)...)
+	}
+
+	return pkg, nil
+}
+
+// Paths of the files and symlinks a package ships
+func packageFiles(manifest *action.Manifest) []string {
+	var files []string
+
+	for _, object := range manifest.Section("File", "SymLink") {
+		files = append(files, object.Key())
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+func containsId(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -13,9 +13,11 @@ package zpm
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fezz-io/zps/sec"
 
@@ -35,11 +37,13 @@ type FilePublisher struct {
 	uri  *url.URL
 	name string
 
-	prune int
+	prune   int
+	expires time.Duration
+	files   bool
 }
 
-func NewFilePublisher(emitter *emission.Emitter, security Security, uri *url.URL, name string, prune int) *FilePublisher {
-	return &FilePublisher{emitter, security, uri, name, prune}
+func NewFilePublisher(emitter *emission.Emitter, security Security, uri *url.URL, name string, prune int, expires time.Duration, files bool) *FilePublisher {
+	return &FilePublisher{emitter, security, uri, name, prune, expires, files}
 }
 
 func (f *FilePublisher) Init() error {
@@ -60,20 +64,15 @@ func (f *FilePublisher) Init() error {
 		return err
 	}
 
-	keyPair, err := f.security.KeyPair(PublisherFromUri(f.uri))
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(f.uri)))
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
@@ -92,40 +91,65 @@ func (f *FilePublisher) Update() error {
 		return err
 	}
 
-	keyPair, err := f.security.KeyPair(PublisherFromUri(f.uri))
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(f.uri)))
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
+	}
 
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+	// Re-sign metadata with a new version, extending its expiry
+	for _, osarch := range zps.Platforms() {
+		err = f.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return true, nil
+		})
 		if err != nil {
 			return err
 		}
 	}
 
-	return err
+	return nil
+}
+
+func (f *FilePublisher) Channel(channel string, pkgs ...string) error {
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err := f.channel(osarch, channel, true, pkgs, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (f *FilePublisher) Channel(pkg string, channel string) error {
-	keyPair, err := f.security.KeyPair(PublisherFromUri(f.uri))
+func (f *FilePublisher) Unchannel(channel string, pkgs ...string) error {
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(f.uri)))
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
-		err := f.channel(osarch, pkg, channel, keyPair)
+		err := f.channel(osarch, channel, false, pkgs, signer)
 		if err != nil {
 			return err
 		}
@@ -149,23 +173,27 @@ func (f *FilePublisher) Publish(pkgs ...string) error {
 			return err
 		}
 
+		if f.files {
+			pkg.SetFiles(packageFiles(reader.Manifest)...)
+		}
+
 		zpkgs[file] = pkg
 	}
 
-	keyPair, err := f.security.KeyPair(PublisherFromUri(f.uri))
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(f.uri)))
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
 		pkgFiles, pkgs := FilterPackagesByArch(osarch, zpkgs)
 
 		if len(pkgFiles) > 0 {
-			err := f.publish(osarch, pkgFiles, pkgs, keyPair)
+			err := f.publish(osarch, pkgFiles, pkgs, signer)
 			if err != nil {
 				return err
 			}
@@ -175,67 +203,154 @@ func (f *FilePublisher) Publish(pkgs ...string) error {
 	return nil
 }
 
-func (f *FilePublisher) channel(osarch *zps.OsArch, pkg string, channel string, keyPair *KeyPairEntry) error {
-	var err error
-
-	metaPath := filepath.Join(f.uri.Path, osarch.String(), "metadata.db")
-	sigPath := filepath.Join(f.uri.Path, osarch.String(), "metadata.sig")
-
-	os.Mkdir(filepath.Join(f.uri.Path, osarch.String()), 0750)
-	os.Remove(sigPath)
-
-	lock, err := lockfile.New(filepath.Join(f.uri.Path, osarch.String(), ".lock"))
+func (f *FilePublisher) Remove(pkgs ...string) error {
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
 	if err != nil {
 		return err
 	}
 
-	err = lock.TryLock()
-	if err != nil {
-		return errors.New("Repository: " + f.uri.String() + " " + osarch.String() + " is locked by another process")
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
 	}
-	defer lock.Unlock()
 
-	metadata := NewMetadata(metaPath)
-	meta, err := metadata.All()
+	for _, osarch := range zps.Platforms() {
+		var files []string
+
+		err = f.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var err error
+
+			files, err = removePackages(metadata, pkgs)
+			return len(files) > 0, err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Files go once the metadata no longer references them
+		for _, file := range files {
+			err = os.Remove(filepath.Join(f.uri.Path, osarch.String(), file))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			f.Emit("publisher.remove", file)
+		}
+	}
+
+	return nil
+}
+
+func (f *FilePublisher) Yank(yanked bool, pkgs ...string) error {
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
 	if err != nil {
 		return err
 	}
 
-	if len(meta) > 0 {
-		err = metadata.Channels.Add(pkg, channel)
+	if signer == nil {
+		f.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(f.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err = f.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return yankPackages(metadata, yanked, pkgs)
+		})
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+func (f *FilePublisher) Check(fix bool) (int, int, error) {
+	signer, err := f.security.Signer(PublisherFromUri(f.uri))
+	if err != nil {
+		return 0, 0, err
+	}
 
-		if keyPair != nil {
-			rsaKey, err := keyPair.RSAKey()
+	problems, unresolved := 0, 0
+
+	for _, osarch := range zps.Platforms() {
+		osarchPath := filepath.Join(f.uri.Path, osarch.String())
+
+		var check *packageCheck
+
+		// Files are listed under the lock so publishes in flight are not orphans
+		err = f.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			entries, err := ioutil.ReadDir(osarchPath)
 			if err != nil {
-				return err
+				return false, err
+			}
+
+			var files []string
+			for _, entry := range entries {
+				if entry.Mode().IsRegular() && filepath.Ext(entry.Name()) == ".zpkg" {
+					files = append(files, entry.Name())
+				}
 			}
 
-			err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+			check, err = checkPackages(f.Emitter, f.security, "", osarch, metadata, files, func(file string, dest string) error {
+				return f.upload(filepath.Join(osarchPath, file), dest)
+			}, f.files, fix)
 			if err != nil {
-				return err
+				return false, err
 			}
+
+			return check.changed, nil
+		})
+		if err != nil {
+			return 0, 0, err
 		}
 
-		f.Emit("publisher.channel", pkg)
+		if check == nil {
+			continue
+		}
+
+		for _, file := range check.deletes {
+			err = os.Remove(filepath.Join(osarchPath, file))
+			if err != nil && !os.IsNotExist(err) {
+				return 0, 0, err
+			}
+			f.Emit("publisher.remove", file)
+		}
+
+		problems += check.problems
+		unresolved += check.unresolved
 	}
 
-	return nil
+	return problems, unresolved, nil
 }
 
-func (f *FilePublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, keyPair *KeyPairEntry) error {
+func (f *FilePublisher) channel(osarch *zps.OsArch, channel string, add bool, pkgs []string, signer sec.Signer) error {
+	return f.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+		changed, err := channelPackages(metadata, channel, add, pkgs)
+		if err != nil {
+			return false, err
+		}
+
+		for _, pkg := range changed {
+			if add {
+				f.Emit("publisher.channel", fmt.Sprint(channel, " ", pkg))
+			} else {
+				f.Emit("publisher.unchannel", fmt.Sprint(channel, " ", pkg))
+			}
+		}
+
+		return len(changed) > 0, nil
+	})
+}
+
+// Applies fn to a copy of the current metadata, the copy is committed as the
+// next generation when fn reports a change
+func (f *FilePublisher) modify(osarch *zps.OsArch, signer sec.Signer, fn func(metadata *Metadata) (bool, error)) error {
 	var err error
 
-	metaPath := filepath.Join(f.uri.Path, osarch.String(), "metadata.db")
-	sigPath := filepath.Join(f.uri.Path, osarch.String(), "metadata.sig")
-	repo := &zps.Repo{}
+	osarchPath := filepath.Join(f.uri.Path, osarch.String())
 
-	os.Mkdir(filepath.Join(f.uri.Path, osarch.String()), 0750)
-	os.Remove(sigPath)
+	if _, err = os.Stat(osarchPath); os.IsNotExist(err) {
+		return nil
+	}
 
-	lock, err := lockfile.New(filepath.Join(f.uri.Path, osarch.String(), ".lock"))
+	lock, err := lockfile.New(filepath.Join(osarchPath, ".lock"))
 	if err != nil {
 		return err
 	}
@@ -246,13 +361,81 @@ func (f *FilePublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*
 	}
 	defer lock.Unlock()
 
-	metadata := NewMetadata(metaPath)
+	generation, err := readMetadataPointer(osarchPath)
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+	if !NewMetadata(filepath.Join(osarchPath, current)).Exists() {
+		return nil
+	}
 
-	meta, err := metadata.All()
+	// Modify a copy, the current generation stays untouched until the switch
+	next := NewMetadataGeneration()
+	metaName, _ := MetadataFiles(next)
+	metadata := NewMetadata(filepath.Join(osarchPath, metaName))
+
+	err = f.upload(filepath.Join(osarchPath, current), metadata.Path)
 	if err != nil {
+		metadata.Empty()
 		return err
 	}
-	repo.Load(meta)
+
+	info, err := metadata.Info()
+	if err != nil {
+		metadata.Empty()
+		return err
+	}
+
+	changed, err := fn(metadata)
+	if err != nil || !changed {
+		metadata.Empty()
+		return err
+	}
+
+	return f.commit(osarchPath, generation, next, info.Version+1, signer)
+}
+
+func (f *FilePublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, signer sec.Signer) error {
+	var err error
+
+	osarchPath := filepath.Join(f.uri.Path, osarch.String())
+	repo := &zps.Repo{}
+
+	os.Mkdir(osarchPath, 0750)
+
+	lock, err := lockfile.New(filepath.Join(osarchPath, ".lock"))
+	if err != nil {
+		return err
+	}
+
+	err = lock.TryLock()
+	if err != nil {
+		return errors.New("Repository: " + f.uri.String() + " " + osarch.String() + " is locked by another process")
+	}
+	defer lock.Unlock()
+
+	generation, err := readMetadataPointer(osarchPath)
+	if err != nil {
+		return err
+	}
+
+	info := &MetadataInfo{}
+
+	current, _ := MetadataFiles(generation)
+	if metadata := NewMetadata(filepath.Join(osarchPath, current)); metadata.Exists() {
+		meta, err := metadata.All()
+		if err != nil {
+			return err
+		}
+		repo.Load(meta)
+
+		info, err = metadata.Info()
+		if err != nil {
+			return err
+		}
+	}
 
 	rejects := repo.Add(zpkgs...)
 	rejectIndex := make(map[string]bool)
@@ -274,7 +457,7 @@ func (f *FilePublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*
 		for _, file := range pkgFiles {
 			if !rejectIndex[filepath.Base(file)] {
 				f.Emit("publisher.publish", file)
-				err = f.upload(file, filepath.Join(f.uri.Path, osarch.String(), filepath.Base(file)))
+				err = f.upload(file, filepath.Join(osarchPath, filepath.Base(file)))
 				if err != nil {
 					return err
 				}
@@ -282,35 +465,106 @@ func (f *FilePublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*
 		}
 
 		for _, pkg := range rmFiles {
-			os.Remove(filepath.Join(f.uri.Path, osarch.String(), pkg.FileName()))
+			os.Remove(filepath.Join(osarchPath, pkg.FileName()))
 		}
 
-		// TODO Rewrite instead of atomic update for now
-
-		metadata.Empty()
+		next := NewMetadataGeneration()
+		metaName, _ := MetadataFiles(next)
+		metadata := NewMetadata(filepath.Join(osarchPath, metaName))
 
 		for _, pkg := range repo.Solvables() {
-			metadata.Put(pkg.(*zps.Pkg))
-		}
-
-		if keyPair != nil {
-			rsaKey, err := keyPair.RSAKey()
+			err = metadata.Put(pkg.(*zps.Pkg))
 			if err != nil {
+				metadata.Empty()
 				return err
 			}
+		}
 
-			err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
-			if err != nil {
-				return err
-			}
+		err = f.commit(osarchPath, generation, next, info.Version+1, signer)
+		if err != nil {
+			return err
 		}
 	} else {
-		os.RemoveAll(filepath.Join(f.uri.Path, osarch.String()))
+		os.RemoveAll(osarchPath)
+	}
+
+	return nil
+}
+
+// Stamps and signs the next metadata generation and renames the pointer over
+// the current one. The plain metadata names are rewritten afterwards for older
+// clients, generations other than next and previous are removed
+func (f *FilePublisher) commit(osarchPath string, previous string, next string, version uint64, signer sec.Signer) error {
+	var err error
+
+	metaName, sigName := MetadataFiles(next)
+	legacyMeta, legacySig := MetadataFiles("")
+
+	pointer := filepath.Join(osarchPath, MetadataPointer)
+
+	err = stampMetadata(filepath.Join(osarchPath, metaName), version, f.expires)
+	if err == nil && signer != nil {
+		err = sec.SecuritySignFile(filepath.Join(osarchPath, metaName), filepath.Join(osarchPath, sigName), signer, sec.DefaultDigestMethod)
+	}
+
+	if err == nil {
+		err = ioutil.WriteFile(pointer+".tmp", []byte(next+"\n"), 0640)
+	}
+
+	if err == nil {
+		err = os.Rename(pointer+".tmp", pointer)
+	}
+
+	if err != nil {
+		// The pointer still names the previous generation, drop the new one
+		os.Remove(pointer + ".tmp")
+		os.Remove(filepath.Join(osarchPath, metaName))
+		os.Remove(filepath.Join(osarchPath, sigName))
+
+		return err
+	}
+
+	err = f.replace(filepath.Join(osarchPath, metaName), filepath.Join(osarchPath, legacyMeta))
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = f.replace(filepath.Join(osarchPath, sigName), filepath.Join(osarchPath, legacySig))
+		if err != nil {
+			return err
+		}
+	} else {
+		os.Remove(filepath.Join(osarchPath, legacySig))
+	}
+
+	entries, err := ioutil.ReadDir(osarchPath)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	for _, name := range StaleMetadataFiles(names, next, previous) {
+		os.Remove(filepath.Join(osarchPath, name))
 	}
 
 	return nil
 }
 
+// Copies file over dest with a rename, readers see the old or new content
+func (f *FilePublisher) replace(file string, dest string) error {
+	err := f.upload(file, dest+".tmp")
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(dest+".tmp", dest)
+}
+
 func (f *FilePublisher) upload(file string, dest string) error {
 	s, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0640)
 	if err != nil {
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
@@ -23,6 +24,7 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/chuckpreslar/emission"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 
 	"github.com/fezz-io/zps/sec"
@@ -37,13 +39,15 @@ type GCSPublisher struct {
 
 	workPath string
 
-	uri   *url.URL
-	name  string
-	prune int
+	uri     *url.URL
+	name    string
+	prune   int
+	expires time.Duration
+	files   bool
 }
 
-func NewGCSPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int) *GCSPublisher {
-	return &GCSPublisher{emitter, security, workPath, uri, name, prune}
+func NewGCSPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int, expires time.Duration, files bool) *GCSPublisher {
+	return &GCSPublisher{emitter, security, workPath, uri, name, prune, expires, files}
 }
 
 func (g *GCSPublisher) Init() error {
@@ -124,20 +128,15 @@ func (g *GCSPublisher) Init() error {
 	cancel()
 
 	// Sign and upload
-	keyPair, err := g.security.KeyPair(PublisherFromUri(g.uri))
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
@@ -230,20 +229,15 @@ func (g *GCSPublisher) Update() error {
 	cancel()
 
 	// Sign and upload
-	keyPair, err := g.security.KeyPair(PublisherFromUri(g.uri))
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
@@ -267,21 +261,51 @@ func (g *GCSPublisher) Update() error {
 		cancel()
 	}
 
-	return err
+	// Re-sign metadata with a new version, extending its expiry
+	for _, osarch := range zps.Platforms() {
+		err = g.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (g *GCSPublisher) Channel(pkg string, channel string) error {
-	keyPair, err := g.security.KeyPair(PublisherFromUri(g.uri))
+func (g *GCSPublisher) Channel(channel string, pkgs ...string) error {
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
-		err := g.channel(osarch, pkg, channel, keyPair)
+		err := g.channel(osarch, channel, true, pkgs, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GCSPublisher) Unchannel(channel string, pkgs ...string) error {
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err := g.channel(osarch, channel, false, pkgs, signer)
 		if err != nil {
 			return err
 		}
@@ -305,22 +329,26 @@ func (g *GCSPublisher) Publish(pkgs ...string) error {
 			return err
 		}
 
+		if g.files {
+			pkg.SetFiles(packageFiles(reader.Manifest)...)
+		}
+
 		zpkgs[file] = pkg
 	}
 
-	keyPair, err := g.security.KeyPair(PublisherFromUri(g.uri))
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
 		pkgFiles, pkgs := FilterPackagesByArch(osarch, zpkgs)
 		if len(pkgFiles) > 0 {
-			err := g.publish(osarch, pkgFiles, pkgs, keyPair)
+			err := g.publish(osarch, pkgFiles, pkgs, signer)
 			if err != nil {
 				return err
 			}
@@ -330,8 +358,186 @@ func (g *GCSPublisher) Publish(pkgs ...string) error {
 	return nil
 }
 
-func (g *GCSPublisher) channel(osarch *zps.OsArch, pkg string, channel string, keyPair *KeyPairEntry) error {
-	tmpDir, err := ioutil.TempDir(g.workPath, "channel")
+func (g *GCSPublisher) Remove(pkgs ...string) error {
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		var files []string
+
+		err = g.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var err error
+
+			files, err = removePackages(metadata, pkgs)
+			return len(files) > 0, err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Files go once the metadata no longer references them
+		for _, file := range files {
+			delCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+
+			err = client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), file)).Delete(delCtx)
+			cancel()
+			if err != nil && err != storage.ErrObjectNotExist {
+				return fmt.Errorf("Object(%q).Delete: %v", path.Join(g.uri.Path, osarch.String(), file), err)
+			}
+			g.Emit("publisher.remove", file)
+		}
+	}
+
+	return nil
+}
+
+func (g *GCSPublisher) Yank(yanked bool, pkgs ...string) error {
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		g.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(g.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err = g.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return yankPackages(metadata, yanked, pkgs)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GCSPublisher) Check(fix bool) (int, int, error) {
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	signer, err := g.security.Signer(PublisherFromUri(g.uri))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	problems, unresolved := 0, 0
+
+	for _, osarch := range zps.Platforms() {
+		prefix := path.Join(g.uri.Path, osarch.String())
+
+		var check *packageCheck
+
+		err = g.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var files []string
+
+			it := client.Bucket(g.uri.Host).Objects(ctx, &storage.Query{Prefix: prefix + "/"})
+			for {
+				attrs, err := it.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					return false, fmt.Errorf("Bucket(%q).Objects(): %v", g.uri.Host, err)
+				}
+
+				if path.Dir(attrs.Name) == prefix && path.Ext(attrs.Name) == ".zpkg" {
+					files = append(files, path.Base(attrs.Name))
+				}
+			}
+
+			var err error
+
+			check, err = checkPackages(g.Emitter, g.security, g.workPath, osarch, metadata, files, func(file string, dest string) error {
+				dst, err := os.Create(dest)
+				if err != nil {
+					return err
+				}
+				defer dst.Close()
+
+				rdCtx, cancel := context.WithTimeout(ctx, time.Second*900)
+				defer cancel()
+
+				rd, err := client.Bucket(g.uri.Host).Object(path.Join(prefix, file)).NewReader(rdCtx)
+				if err != nil {
+					return fmt.Errorf("unable to download: %s", path.Join(prefix, file))
+				}
+				defer rd.Close()
+
+				_, err = io.Copy(dst, rd)
+				return err
+			}, g.files, fix)
+			if err != nil {
+				return false, err
+			}
+
+			return check.changed, nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if check == nil {
+			continue
+		}
+
+		for _, file := range check.deletes {
+			delCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+
+			err = client.Bucket(g.uri.Host).Object(path.Join(prefix, file)).Delete(delCtx)
+			cancel()
+			if err != nil && err != storage.ErrObjectNotExist {
+				return 0, 0, fmt.Errorf("Object(%q).Delete: %v", path.Join(prefix, file), err)
+			}
+			g.Emit("publisher.remove", file)
+		}
+
+		problems += check.problems
+		unresolved += check.unresolved
+	}
+
+	return problems, unresolved, nil
+}
+
+func (g *GCSPublisher) channel(osarch *zps.OsArch, channel string, add bool, pkgs []string, signer sec.Signer) error {
+	return g.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+		changed, err := channelPackages(metadata, channel, add, pkgs)
+		if err != nil {
+			return false, err
+		}
+
+		for _, pkg := range changed {
+			if add {
+				g.Emit("publisher.channel", fmt.Sprint(channel, " ", pkg))
+			} else {
+				g.Emit("publisher.unchannel", fmt.Sprint(channel, " ", pkg))
+			}
+		}
+
+		return len(changed) > 0, nil
+	})
+}
+
+// Applies fn to a downloaded copy of the current metadata, the copy is
+// committed as the next generation when fn reports a change
+func (g *GCSPublisher) modify(osarch *zps.OsArch, signer sec.Signer, fn func(metadata *Metadata) (bool, error)) error {
+	tmpDir, err := ioutil.TempDir(g.workPath, "modify")
 	if err != nil {
 		return err
 	}
@@ -339,7 +545,6 @@ func (g *GCSPublisher) channel(osarch *zps.OsArch, pkg string, channel string, k
 	defer os.RemoveAll(tmpDir)
 
 	metaPath := filepath.Join(tmpDir, "metadata.db")
-	sigPath := filepath.Join(tmpDir, "metadata.sig")
 
 	metadataDb, err := os.Create(metaPath)
 	if err != nil {
@@ -353,9 +558,20 @@ func (g *GCSPublisher) channel(osarch *zps.OsArch, pkg string, channel string, k
 		return err
 	}
 
+	generation, pointerGen, err := gcsMetadataPointer(ctx, client, g.uri.Host, path.Join(g.uri.Path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+
 	// Download metadata db
 	cdCtx, cancel := context.WithTimeout(ctx, time.Second*60)
-	cd, err := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), "metadata.db")).NewReader(cdCtx)
+	cd, err := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), current)).NewReader(cdCtx)
+	if err == storage.ErrObjectNotExist {
+		cancel()
+		return nil
+	}
 	if err != nil {
 		cancel()
 		return fmt.Errorf("unable to download: %s", g.uri.Path)
@@ -371,73 +587,22 @@ func (g *GCSPublisher) channel(osarch *zps.OsArch, pkg string, channel string, k
 	}
 	cancel()
 
-	// Modify metadata
 	metadata := NewMetadata(metaPath)
-	meta, err := metadata.All()
+
+	info, err := metadata.Info()
 	if err != nil {
 		return err
 	}
 
-	if len(meta) > 0 {
-		err = metadata.Channels.Add(pkg, channel)
-		if err != nil {
-			return err
-		}
-
-		g.Emit("publisher.channel", pkg)
-	}
-
-	// Upload metadata
-	metadataDb.Seek(0, io.SeekStart)
-
-	cuCtx, cancel := context.WithTimeout(ctx, time.Second*60)
-
-	cu := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), "metadata.db")).NewWriter(cuCtx)
-	if _, err = io.Copy(cu, metadataDb); err != nil {
-		cancel()
-		return fmt.Errorf("io.Copy: %v", err)
-	}
-	if err := cu.Close(); err != nil {
-		cancel()
-		return fmt.Errorf("Writer.Close: %v", err)
-	}
-	cancel()
-
-	// Sign and upload
-	if keyPair != nil {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
-		if err != nil {
-			return err
-		}
-
-		metadataSig, err := os.Open(sigPath)
-		if err != nil {
-			return err
-		}
-
-		cuCtx, cancel := context.WithTimeout(ctx, time.Second*60)
-
-		cu := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), "metadata.sig")).NewWriter(cuCtx)
-		if _, err = io.Copy(cu, metadataSig); err != nil {
-			cancel()
-			return fmt.Errorf("io.Copy: %v", err)
-		}
-		if err := cu.Close(); err != nil {
-			cancel()
-			return fmt.Errorf("Writer.Close: %v", err)
-		}
-		cancel()
+	changed, err := fn(metadata)
+	if err != nil || !changed {
+		return err
 	}
 
-	return err
+	return g.commit(ctx, client, osarch, metaPath, generation, pointerGen, info.Version+1, signer)
 }
 
-func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, keyPair *KeyPairEntry) error {
+func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, signer sec.Signer) error {
 	tmpDir, err := ioutil.TempDir(g.workPath, "publish")
 	if err != nil {
 		return err
@@ -446,7 +611,6 @@ func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 	defer os.RemoveAll(tmpDir)
 
 	metaPath := filepath.Join(tmpDir, "metadata.db")
-	sigPath := filepath.Join(tmpDir, "metadata.sig")
 
 	metadataDb, err := os.Create(metaPath)
 	if err != nil {
@@ -460,10 +624,17 @@ func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 		return err
 	}
 
+	generation, pointerGen, err := gcsMetadataPointer(ctx, client, g.uri.Host, path.Join(g.uri.Path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+
 	// Download metadata db
 	cdCtx, cancel := context.WithTimeout(ctx, time.Second*60)
 
-	cd, err := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), "metadata.db")).NewReader(cdCtx)
+	cd, err := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), current)).NewReader(cdCtx)
 	if cd != nil {
 		if _, err = io.Copy(metadataDb, cd); err != nil {
 			cancel()
@@ -485,6 +656,11 @@ func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 	}
 	repo.Load(meta)
 
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
 	rejects := repo.Add(zpkgs...)
 	rejectIndex := make(map[string]bool)
 
@@ -527,8 +703,8 @@ func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 			cancel()
 		}
 
-		// TODO Rewrite instead of atomic update for now
-
+		// Rebuild the local copy, the repo switches over in commit
+		metadataDb.Close()
 		metadata.Empty()
 
 		for _, pkg := range repo.Solvables() {
@@ -538,68 +714,137 @@ func (g *GCSPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*z
 			}
 		}
 
-		// Upload metadata
-		metadataUp, err := os.Open(metaPath)
+		err = g.commit(ctx, client, osarch, metaPath, generation, pointerGen, info.Version+1, signer)
+	} else {
+		delCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+
+		o := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String()) + "/")
+		if err := o.Delete(delCtx); err != nil {
+			cancel()
+			return fmt.Errorf("Object(%q).Delete: %v", path.Join(g.uri.Path, osarch.String()) + "/", err)
+		}
+		cancel()
+	}
+
+	return err
+}
+
+// Stamps metaPath and uploads it with its signature as a new generation, then
+// the pointer to it. The pointer write is conditional on the object generation read
+// before, a concurrent publish fails instead of being overwritten. The plain
+// metadata names are rewritten afterwards for older clients, generations
+// other than the new and previous one are removed
+func (g *GCSPublisher) commit(ctx context.Context, client *storage.Client, osarch *zps.OsArch, metaPath string, previous string, pointerGen int64, version uint64, signer sec.Signer) error {
+	var err error
+
+	prefix := path.Join(g.uri.Path, osarch.String())
+	next := NewMetadataGeneration()
+	metaName, sigName := MetadataFiles(next)
+	legacyMeta, legacySig := MetadataFiles("")
+	sigPath := metaPath + ".sig"
+
+	err = stampMetadata(metaPath, version, g.expires)
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = sec.SecuritySignFile(metaPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
-		defer metadataUp.Close()
+	}
 
-		cuCtx, cancel := context.WithTimeout(ctx, time.Second*60)
+	err = g.upload(metaPath, path.Join(prefix, metaName))
+	if err == nil && signer != nil {
+		err = g.upload(sigPath, path.Join(prefix, sigName))
+	}
 
-		cu := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), "metadata.db")).NewWriter(cuCtx)
-		if _, err = io.Copy(cu, metadataUp); err != nil {
-			cancel()
-			return fmt.Errorf("io.Copy: %v", err)
-		}
-		if err := cu.Close(); err != nil {
-			cancel()
-			return fmt.Errorf("Writer.Close: %v", err)
-		}
-		cancel()
+	if err == nil {
+		err = g.switchPointer(ctx, client, osarch, next, pointerGen)
+	}
 
-		// Sign and upload
-		if keyPair != nil {
-			rsaKey, err := keyPair.RSAKey()
-			if err != nil {
-				return err
-			}
+	if err != nil {
+		g.discard(ctx, client, prefix, next)
+		return err
+	}
 
-			err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
-			if err != nil {
-				return err
-			}
+	err = g.upload(metaPath, path.Join(prefix, legacyMeta))
+	if err != nil {
+		return err
+	}
 
-			metadataSig, err := os.Open(sigPath)
-			if err != nil {
-				return err
-			}
+	if signer != nil {
+		err = g.upload(sigPath, path.Join(prefix, legacySig))
+		if err != nil {
+			return err
+		}
+	}
 
-			cuCtx, cancel := context.WithTimeout(ctx, time.Second*50)
+	var names []string
 
-			cu := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), "metadata.sig")).NewWriter(cuCtx)
-			if _, err = io.Copy(cu, metadataSig); err != nil {
-				cancel()
-				return fmt.Errorf("io.Copy: %v", err)
-			}
-			if err := cu.Close(); err != nil {
-				cancel()
-				return fmt.Errorf("Writer.Close: %v", err)
-			}
-			cancel()
+	it := client.Bucket(g.uri.Host).Objects(ctx, &storage.Query{Prefix: path.Join(prefix, "metadata-")})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
 		}
-	} else {
+		if err != nil {
+			return err
+		}
+
+		names = append(names, path.Base(attrs.Name))
+	}
+
+	for _, name := range StaleMetadataFiles(names, next, previous) {
 		delCtx, cancel := context.WithTimeout(ctx, time.Second*10)
 
-		o := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String()) + "/")
-		if err := o.Delete(delCtx); err != nil {
-			cancel()
-			return fmt.Errorf("Object(%q).Delete: %v", path.Join(g.uri.Path, osarch.String()) + "/", err)
+		err = client.Bucket(g.uri.Host).Object(path.Join(prefix, name)).Delete(delCtx)
+		cancel()
+		if err != nil && err != storage.ErrObjectNotExist {
+			return fmt.Errorf("Object(%q).Delete: %v", path.Join(prefix, name), err)
 		}
+	}
+
+	return nil
+}
+
+// Removes a generation the pointer was never switched to, leftovers are
+// also removed by the next commit
+func (g *GCSPublisher) discard(ctx context.Context, client *storage.Client, prefix string, generation string) {
+	metaName, sigName := MetadataFiles(generation)
+
+	for _, name := range []string{metaName, sigName} {
+		delCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+		client.Bucket(g.uri.Host).Object(path.Join(prefix, name)).Delete(delCtx)
 		cancel()
 	}
+}
 
-	return err
+// Writes the pointer if its object generation is still pointerGen
+func (g *GCSPublisher) switchPointer(ctx context.Context, client *storage.Client, osarch *zps.OsArch, generation string, pointerGen int64) error {
+	conditions := storage.Conditions{GenerationMatch: pointerGen}
+	if pointerGen == 0 {
+		conditions = storage.Conditions{DoesNotExist: true}
+	}
+
+	cuCtx, cancel := context.WithTimeout(ctx, time.Second*60)
+	defer cancel()
+
+	cu := client.Bucket(g.uri.Host).Object(path.Join(g.uri.Path, osarch.String(), MetadataPointer)).If(conditions).NewWriter(cuCtx)
+	if _, err := io.WriteString(cu, generation+"\n"); err != nil {
+		return fmt.Errorf("io.Copy: %v", err)
+	}
+
+	if err := cu.Close(); err != nil {
+		if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusPreconditionFailed {
+			return fmt.Errorf("Repository: %s %s was published concurrently, retry", g.uri.String(), osarch.String())
+		}
+
+		return fmt.Errorf("Writer.Close: %v", err)
+	}
+
+	return nil
 }
 
 func (g *GCSPublisher) upload(file string, dest string) error {
@@ -0,0 +1,822 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zpm
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chuckpreslar/emission"
+	"gopkg.in/resty.v1"
+
+	"github.com/fezz-io/zps/sec"
+	"github.com/fezz-io/zps/zpkg"
+	"github.com/fezz-io/zps/zps"
+)
+
+// Name of the lock a publisher holds on an osarch while modifying it
+const PublishLock = "publish.lock"
+
+// Publishes to a server implementing the upload protocol of zps repo serve,
+// see doc/Repo HTTP Protocol.md
+type HttpsPublisher struct {
+	*emission.Emitter
+
+	security Security
+
+	workPath string
+
+	uri     *url.URL
+	name    string
+	prune   int
+	expires time.Duration
+	files   bool
+
+	client *resty.Client
+}
+
+func NewHttpsPublisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int, expires time.Duration, files bool) *HttpsPublisher {
+	client := resty.New()
+	client.SetTimeout(time.Duration(900) * time.Second)
+
+	user := uri.User.Username()
+	password, _ := uri.User.Password()
+
+	if user != "" || password != "" {
+		client.SetBasicAuth(user, password)
+	}
+
+	return &HttpsPublisher{emitter, security, workPath, uri, name, prune, expires, files, client}
+}
+
+func (h *HttpsPublisher) Init() error {
+	// Empty the repo
+	for _, osarch := range zps.Platforms() {
+		err := h.empty(osarch.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := h.empty("")
+	if err != nil {
+		return err
+	}
+
+	// Create the config db
+	tmpDir, err := ioutil.TempDir(h.workPath, "init")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	configPath := filepath.Join(tmpDir, "config.db")
+	config := NewConfig(configPath)
+
+	err = config.Set("name", h.name)
+	if err != nil {
+		return err
+	}
+
+	return h.config(configPath)
+}
+
+func (h *HttpsPublisher) Update() error {
+	tmpDir, err := ioutil.TempDir(h.workPath, "update")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	configPath := filepath.Join(tmpDir, "config.db")
+
+	// Download the config db
+	found, err := h.download("config.db", configPath)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New(fmt.Sprintf("unable to download: %s", h.uri.Path))
+	}
+
+	// Modify config db
+	config := NewConfig(configPath)
+
+	err = config.Set("name", h.name)
+	if err != nil {
+		return err
+	}
+
+	err = h.config(configPath)
+	if err != nil {
+		return err
+	}
+
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	// Re-sign metadata with a new version, extending its expiry
+	for _, osarch := range zps.Platforms() {
+		err = h.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) Channel(channel string, pkgs ...string) error {
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		h.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(h.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err := h.channel(osarch, channel, true, pkgs, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) Unchannel(channel string, pkgs ...string) error {
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		h.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(h.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err := h.channel(osarch, channel, false, pkgs, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) Publish(pkgs ...string) error {
+	zpkgs := make(map[string]*zps.Pkg)
+	for _, file := range pkgs {
+		reader := zpkg.NewReader(file, "")
+
+		err := reader.Read()
+		if err != nil {
+			return err
+		}
+
+		pkg, err := zps.NewPkgFromManifest(reader.Manifest)
+		if err != nil {
+			return err
+		}
+
+		if h.files {
+			pkg.SetFiles(packageFiles(reader.Manifest)...)
+		}
+
+		zpkgs[file] = pkg
+	}
+
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		h.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(h.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		pkgFiles, pkgs := FilterPackagesByArch(osarch, zpkgs)
+		if len(pkgFiles) > 0 {
+			err := h.publish(osarch, pkgFiles, pkgs, signer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) Remove(pkgs ...string) error {
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		h.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(h.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		var files []string
+
+		err = h.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var err error
+
+			files, err = removePackages(metadata, pkgs)
+			return len(files) > 0, err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Files go once the metadata no longer references them
+		for _, file := range files {
+			err = h.delete(path.Join(osarch.String(), file))
+			if err != nil {
+				return err
+			}
+			h.Emit("publisher.remove", file)
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) Yank(yanked bool, pkgs ...string) error {
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		h.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(h.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err = h.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return yankPackages(metadata, yanked, pkgs)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) Check(fix bool) (int, int, error) {
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	problems, unresolved := 0, 0
+
+	for _, osarch := range zps.Platforms() {
+		var check *packageCheck
+
+		// Files are listed under the lock so publishes in flight are not orphans
+		err = h.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			names, err := h.list(osarch.String())
+			if err != nil {
+				return false, err
+			}
+
+			var files []string
+			for _, name := range names {
+				if path.Ext(name) == ".zpkg" {
+					files = append(files, name)
+				}
+			}
+
+			check, err = checkPackages(h.Emitter, h.security, h.workPath, osarch, metadata, files, func(file string, dest string) error {
+				found, err := h.download(path.Join(osarch.String(), file), dest)
+				if err == nil && !found {
+					err = errors.New("not found")
+				}
+
+				return err
+			}, h.files, fix)
+			if err != nil {
+				return false, err
+			}
+
+			return check.changed, nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if check == nil {
+			continue
+		}
+
+		for _, file := range check.deletes {
+			err = h.delete(path.Join(osarch.String(), file))
+			if err != nil {
+				return 0, 0, err
+			}
+			h.Emit("publisher.remove", file)
+		}
+
+		problems += check.problems
+		unresolved += check.unresolved
+	}
+
+	return problems, unresolved, nil
+}
+
+func (h *HttpsPublisher) channel(osarch *zps.OsArch, channel string, add bool, pkgs []string, signer sec.Signer) error {
+	return h.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+		changed, err := channelPackages(metadata, channel, add, pkgs)
+		if err != nil {
+			return false, err
+		}
+
+		for _, pkg := range changed {
+			if add {
+				h.Emit("publisher.channel", fmt.Sprint(channel, " ", pkg))
+			} else {
+				h.Emit("publisher.unchannel", fmt.Sprint(channel, " ", pkg))
+			}
+		}
+
+		return len(changed) > 0, nil
+	})
+}
+
+// Applies fn to a downloaded copy of the current metadata, the copy is
+// committed as the next generation when fn reports a change
+func (h *HttpsPublisher) modify(osarch *zps.OsArch, signer sec.Signer, fn func(metadata *Metadata) (bool, error)) error {
+	tmpDir, err := ioutil.TempDir(h.workPath, "modify")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	// Skip osarches never published to, taking the lock would create them
+	names, err := h.list(osarch.String())
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	unlock, err := h.lock(osarch)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	generation, etag, err := h.pointer(osarch)
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+	metaPath := filepath.Join(tmpDir, "metadata.db")
+
+	// Download metadata db
+	found, err := h.download(path.Join(osarch.String(), current), metaPath)
+	if err != nil || !found {
+		return err
+	}
+
+	metadata := NewMetadata(metaPath)
+
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
+	changed, err := fn(metadata)
+	if err != nil || !changed {
+		return err
+	}
+
+	return h.commit(osarch, metaPath, generation, etag, info.Version+1, signer)
+}
+
+func (h *HttpsPublisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, signer sec.Signer) error {
+	tmpDir, err := ioutil.TempDir(h.workPath, "publish")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	unlock, err := h.lock(osarch)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	generation, etag, err := h.pointer(osarch)
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+	metaPath := filepath.Join(tmpDir, "metadata.db")
+
+	// Download metadata db
+	_, err = h.download(path.Join(osarch.String(), current), metaPath)
+	if err != nil {
+		return err
+	}
+
+	metadata := NewMetadata(metaPath)
+	repo := &zps.Repo{}
+
+	meta, err := metadata.All()
+	if err != nil {
+		return err
+	}
+	repo.Load(meta)
+
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
+	rejects := repo.Add(zpkgs...)
+	rejectIndex := make(map[string]bool)
+
+	for _, r := range rejects {
+		rejectIndex[r.FileName()] = true
+	}
+
+	rmFiles, err := repo.Prune(h.prune)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rmFiles {
+		rejectIndex[r.FileName()] = true
+	}
+
+	if len(repo.Solvables()) > 0 {
+		for _, file := range pkgFiles {
+			if !rejectIndex[filepath.Base(file)] {
+				h.Emit("spin.start", fmt.Sprintf("publishing: %s", file))
+
+				err = h.upload(file, path.Join(osarch.String(), filepath.Base(file)))
+				if err != nil {
+					h.Emit("spin.error", fmt.Sprintf("failed: %s", file))
+					return err
+				}
+
+				h.Emit("spin.success", fmt.Sprintf("published: %s", file))
+			}
+		}
+
+		for _, pkg := range rmFiles {
+			err = h.delete(path.Join(osarch.String(), pkg.FileName()))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Rebuild the local copy, the repo switches over in commit
+		metadata.Empty()
+
+		for _, pkg := range repo.Solvables() {
+			err := metadata.Put(pkg.(*zps.Pkg))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = h.commit(osarch, metaPath, generation, etag, info.Version+1, signer)
+	}
+
+	return err
+}
+
+// Stamps metaPath and uploads it with its signature as a new generation, then
+// the pointer to it. The pointer write is conditional on the ETag read before,
+// a concurrent publish fails instead of being overwritten. The plain metadata
+// names are rewritten afterwards for older clients, generations other than the
+// new and previous one are removed
+func (h *HttpsPublisher) commit(osarch *zps.OsArch, metaPath string, previous string, etag string, version uint64, signer sec.Signer) error {
+	var err error
+
+	prefix := osarch.String()
+	next := NewMetadataGeneration()
+	metaName, sigName := MetadataFiles(next)
+	legacyMeta, legacySig := MetadataFiles("")
+	sigPath := metaPath + ".sig"
+
+	err = stampMetadata(metaPath, version, h.expires)
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = sec.SecuritySignFile(metaPath, sigPath, signer, sec.DefaultDigestMethod)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = h.upload(metaPath, path.Join(prefix, metaName))
+	if err == nil && signer != nil {
+		err = h.upload(sigPath, path.Join(prefix, sigName))
+	}
+
+	if err == nil {
+		err = h.switchPointer(osarch, next, etag)
+	}
+
+	if err != nil {
+		h.discard(prefix, next)
+		return err
+	}
+
+	err = h.upload(metaPath, path.Join(prefix, legacyMeta))
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = h.upload(sigPath, path.Join(prefix, legacySig))
+		if err != nil {
+			return err
+		}
+	} else {
+		err = h.delete(path.Join(prefix, legacySig))
+		if err != nil {
+			return err
+		}
+	}
+
+	names, err := h.list(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range StaleMetadataFiles(names, next, previous) {
+		err = h.delete(path.Join(prefix, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Removes a generation the pointer was never switched to, leftovers are
+// also removed by the next commit
+func (h *HttpsPublisher) discard(prefix string, generation string) {
+	metaName, sigName := MetadataFiles(generation)
+
+	h.delete(path.Join(prefix, metaName))
+	h.delete(path.Join(prefix, sigName))
+}
+
+// Writes the pointer if it is unchanged since it was read with etag
+func (h *HttpsPublisher) switchPointer(osarch *zps.OsArch, generation string, etag string) error {
+	req := h.client.R().SetBody(generation + "\n")
+	if etag == "" {
+		req.SetHeader("If-None-Match", "*")
+	} else {
+		req.SetHeader("If-Match", etag)
+	}
+
+	pointerUri := h.url(osarch.String(), MetadataPointer)
+
+	resp, err := req.Put(pointerUri)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.StatusCode() == http.StatusPreconditionFailed {
+		return fmt.Errorf("Repository: %s %s was published concurrently, retry", SafeURI(h.uri), osarch.String())
+	}
+
+	if resp.IsError() {
+		return h.error(resp, pointerUri)
+	}
+
+	return nil
+}
+
+// Uploads and signs the config db
+func (h *HttpsPublisher) config(configPath string) error {
+	err := h.upload(configPath, "config.db")
+	if err != nil {
+		return err
+	}
+
+	signer, err := h.security.Signer(PublisherFromUri(h.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		h.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(h.uri)))
+		return nil
+	}
+
+	sigPath := filepath.Join(filepath.Dir(configPath), "config.sig")
+
+	err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
+	if err != nil {
+		return err
+	}
+
+	return h.upload(sigPath, "config.sig")
+}
+
+// Takes the publish lock of an osarch, the server only creates it when absent
+func (h *HttpsPublisher) lock(osarch *zps.OsArch) (func(), error) {
+	hostname, _ := os.Hostname()
+	lockUri := h.url(osarch.String(), PublishLock)
+
+	resp, err := h.client.R().
+		SetHeader("If-None-Match", "*").
+		SetBody(fmt.Sprintf("%s %d %s\n", hostname, os.Getpid(), time.Now().UTC().Format(time.RFC3339))).
+		Put(lockUri)
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.StatusCode() == http.StatusPreconditionFailed || resp.StatusCode() == http.StatusConflict {
+		return nil, errors.New("Repository: " + SafeURI(h.uri) + " " + osarch.String() + " is locked by another process, remove " + PublishLock + " if stale")
+	}
+
+	if resp.IsError() {
+		return nil, h.error(resp, lockUri)
+	}
+
+	return func() {
+		h.delete(path.Join(osarch.String(), PublishLock))
+	}, nil
+}
+
+// Reads the metadata pointer and its ETag, both empty for repos without one
+func (h *HttpsPublisher) pointer(osarch *zps.OsArch) (string, string, error) {
+	pointerUri := h.url(osarch.String(), MetadataPointer)
+
+	resp, err := h.client.R().Get(pointerUri)
+	if err != nil {
+		return "", "", errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return "", "", nil
+	}
+
+	if resp.IsError() {
+		return "", "", h.error(resp, pointerUri)
+	}
+
+	generation, err := ParseMetadataPointer(resp.Body())
+	if err != nil {
+		return "", "", err
+	}
+
+	return generation, resp.Header().Get("ETag"), nil
+}
+
+func (h *HttpsPublisher) download(source string, dest string) (bool, error) {
+	sourceUri := h.url(source)
+
+	resp, err := h.client.R().SetOutput(dest).Get(sourceUri)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.IsError() {
+		os.Remove(dest)
+
+		if resp.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, h.error(resp, sourceUri)
+	}
+
+	return true, nil
+}
+
+func (h *HttpsPublisher) upload(file string, dest string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	destUri := h.url(dest)
+
+	resp, err := h.client.R().SetBody(src).Put(destUri)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.IsError() {
+		return h.error(resp, destUri)
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) delete(dest string) error {
+	destUri := h.url(dest)
+
+	resp, err := h.client.R().Delete(destUri)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.IsError() && resp.StatusCode() != http.StatusNotFound {
+		return h.error(resp, destUri)
+	}
+
+	return nil
+}
+
+// Lists the file names in a repo directory
+func (h *HttpsPublisher) list(dir string) ([]string, error) {
+	dirUri := h.url(dir) + "/"
+
+	resp, err := h.client.R().Get(dirUri)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error connecting to: %s", h.uri.Host))
+	}
+
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.IsError() {
+		return nil, h.error(resp, dirUri)
+	}
+
+	return strings.Fields(string(resp.Body())), nil
+}
+
+// Removes the files in a repo directory
+func (h *HttpsPublisher) empty(dir string) error {
+	names, err := h.list(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		err = h.delete(path.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *HttpsPublisher) url(parts ...string) string {
+	uri, _ := url.Parse(h.uri.String())
+	uri.User = nil
+	uri.Path = path.Join(append([]string{"/", uri.Path}, parts...)...)
+
+	return uri.String()
+}
+
+func (h *HttpsPublisher) error(resp *resty.Response, uri string) error {
+	switch resp.StatusCode() {
+	case http.StatusNotFound:
+		return errors.New(fmt.Sprintf("not found: %s", uri))
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.New(fmt.Sprintf("access denied: %s", uri))
+	case http.StatusMethodNotAllowed:
+		return errors.New(fmt.Sprintf("uploads not accepted: %s", uri))
+	default:
+		return errors.New(fmt.Sprintf("server error %d: %s", resp.StatusCode(), uri))
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zpm
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chuckpreslar/emission"
+
+	"github.com/fezz-io/zps/action"
+	"github.com/fezz-io/zps/zpkg"
+	"github.com/fezz-io/zps/zpkg/payload"
+	"github.com/fezz-io/zps/zps"
+)
+
+// Writes an empty linux-x86_64 foo package of the given version
+func writeTestZpkg(t *testing.T, dir string, version string) string {
+	manifest := action.NewManifest()
+
+	zp := action.NewZpkg()
+	zp.Name = "foo"
+	zp.Publisher = "fezz"
+	zp.Version = version + ":" + time.Now().UTC().Format("20060102T150405Z")
+	zp.Os = "linux"
+	zp.Arch = "x86_64"
+	manifest.Zpkg = zp
+
+	pkg, err := zps.NewPkgFromManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, pkg.FileName())
+
+	err = zpkg.NewWriter().Write(filename, zpkg.NewHeader(zpkg.Version, zpkg.Compression), manifest, payload.NewWriter(dir, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+// Generation of the metadata pointer and the names in a served osarch dir
+func servedGeneration(t *testing.T, dir string) (string, []string) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, MetadataPointer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generation, err := ParseMetadataPointer(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return generation, names
+}
+
+func TestHttpsPublisher(t *testing.T) {
+	root, err := ioutil.TempDir("", "publisher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	repoPath := filepath.Join(root, "repo")
+	workPath := filepath.Join(root, "work")
+
+	for _, dir := range []string{repoPath, workPath} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	emitter := emission.NewEmitter()
+
+	server := httptest.NewServer(NewRepoServer(emitter, repoPath, "/fezz/repo", "", "", true, 1<<20))
+	defer server.Close()
+
+	uri, err := url.Parse(server.URL + "/fezz/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pb := NewHttpsPublisher(emitter, &SecurityNone{}, workPath, uri, "repo", 2, time.Hour, false)
+
+	err = pb.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewConfig(filepath.Join(repoPath, "config.db")).All()
+	if err != nil || config["name"] != "repo" {
+		t.Fatalf("expected config name repo, got %v %v", config, err)
+	}
+
+	osarchPath := filepath.Join(repoPath, "linux-x86_64")
+
+	var generations []string
+	var ids []string
+
+	// Each step commits a generation, the pointer names it and only it and
+	// the previous one are kept
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"publish 1.0.0", func() error { return pb.Publish(writeTestZpkg(t, workPath, "1.0.0")) }},
+		{"publish 1.0.1", func() error { return pb.Publish(writeTestZpkg(t, workPath, "1.0.1")) }},
+		{"channel", func() error { return pb.Channel("stable", ids[1]) }},
+		{"publish 1.0.2", func() error { return pb.Publish(writeTestZpkg(t, workPath, "1.0.2")) }},
+	}
+
+	for index, step := range steps {
+		err = step.run()
+		if err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+
+		generation, names := servedGeneration(t, osarchPath)
+
+		if len(generations) > 0 && generation == generations[len(generations)-1] {
+			t.Fatalf("%s: pointer not switched from %s", step.name, generation)
+		}
+		generations = append(generations, generation)
+
+		var kept []string
+		for _, g := range generations {
+			kept = append(kept, "metadata-"+g+".db")
+		}
+		if len(kept) > 2 {
+			kept = kept[len(kept)-2:]
+		}
+
+		var served []string
+		for _, name := range names {
+			if strings.HasPrefix(name, "metadata-") {
+				served = append(served, name)
+			}
+		}
+
+		if strings.Join(served, " ") != strings.Join(kept, " ") {
+			t.Errorf("%s: expected generations %v, got %v", step.name, kept, served)
+		}
+
+		current, _ := MetadataFiles(generation)
+		metadata := NewMetadata(filepath.Join(osarchPath, current))
+
+		info, err := metadata.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version != uint64(index+1) {
+			t.Errorf("%s: expected metadata version %d, got %d", step.name, index+1, info.Version)
+		}
+
+		pkgs, err := metadata.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = ids[:0]
+		for _, pkg := range pkgs {
+			ids = append(ids, pkg.Id())
+		}
+		sort.Strings(ids)
+
+		if step.name == "channel" {
+			for _, pkg := range pkgs {
+				if (pkg.Id() == ids[1]) != containsId(pkg.Channels(), "stable") {
+					t.Errorf("unexpected channels %v for %s", pkg.Channels(), pkg.Id())
+				}
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(osarchPath, PublishLock)); err == nil {
+			t.Errorf("%s: publish lock left behind", step.name)
+		}
+	}
+
+	// Prune keeps the two newest packages, 1.0.0 and its file are gone
+	_, names := servedGeneration(t, osarchPath)
+
+	var zpkgs []string
+	for _, name := range names {
+		if filepath.Ext(name) == ".zpkg" {
+			zpkgs = append(zpkgs, name)
+		}
+	}
+
+	if len(ids) != 2 || len(zpkgs) != 2 {
+		t.Fatalf("expected 2 packages after prune, got %v %v", ids, zpkgs)
+	}
+
+	for _, name := range zpkgs {
+		if strings.Contains(name, "1.0.0") {
+			t.Errorf("expected %s to be pruned", name)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -42,14 +43,16 @@ type S3Publisher struct {
 
 	workPath string
 
-	uri   *url.URL
-	name  string
-	prune int
+	uri     *url.URL
+	name    string
+	prune   int
+	expires time.Duration
+	files   bool
 
 	session *session.Session
 }
 
-func NewS3Publisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int) *S3Publisher {
+func NewS3Publisher(emitter *emission.Emitter, security Security, workPath string, uri *url.URL, name string, prune int, expires time.Duration, files bool) *S3Publisher {
 	sess := session.Must(session.NewSession())
 
 	user := uri.User.Username()
@@ -70,7 +73,7 @@ func NewS3Publisher(emitter *emission.Emitter, security Security, workPath strin
 
 	sess.Config.Region = aws.String(region)
 
-	return &S3Publisher{emitter, security, workPath, uri, name, prune, sess}
+	return &S3Publisher{emitter, security, workPath, uri, name, prune, expires, files, sess}
 }
 
 func (s *S3Publisher) Init() error {
@@ -131,20 +134,15 @@ func (s *S3Publisher) Init() error {
 	})
 
 	// Sign and upload
-	keyPair, err := s.security.KeyPair(PublisherFromUri(s.uri))
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
@@ -214,20 +212,15 @@ func (s *S3Publisher) Update() error {
 	})
 
 	// Sign and upload
-	keyPair, err := s.security.KeyPair(PublisherFromUri(s.uri))
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
 	} else {
-		rsaKey, err := keyPair.RSAKey()
-		if err != nil {
-			return err
-		}
-
-		err = sec.SecuritySignFile(configPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
+		err = sec.SecuritySignFile(configPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
@@ -242,23 +235,56 @@ func (s *S3Publisher) Update() error {
 			Key:    aws.String(path.Join(s.uri.Path, "config.sig")),
 			Body:   configSig,
 		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return err
+	// Re-sign metadata with a new version, extending its expiry
+	for _, osarch := range zps.Platforms() {
+		err = s.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Publisher) Channel(channel string, pkgs ...string) error {
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
+	if err != nil {
+		return err
+	}
+
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err := s.channel(osarch, channel, true, pkgs, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (s *S3Publisher) Channel(pkg string, channel string) error {
-	keyPair, err := s.security.KeyPair(PublisherFromUri(s.uri))
+func (s *S3Publisher) Unchannel(channel string, pkgs ...string) error {
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
-		err := s.channel(osarch, pkg, channel, keyPair)
+		err := s.channel(osarch, channel, false, pkgs, signer)
 		if err != nil {
 			return err
 		}
@@ -282,22 +308,26 @@ func (s *S3Publisher) Publish(pkgs ...string) error {
 			return err
 		}
 
+		if s.files {
+			pkg.SetFiles(packageFiles(reader.Manifest)...)
+		}
+
 		zpkgs[file] = pkg
 	}
 
-	keyPair, err := s.security.KeyPair(PublisherFromUri(s.uri))
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
 	if err != nil {
 		return err
 	}
 
-	if keyPair == nil {
-		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No keypair found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
 	}
 
 	for _, osarch := range zps.Platforms() {
 		pkgFiles, pkgs := FilterPackagesByArch(osarch, zpkgs)
 		if len(pkgFiles) > 0 {
-			err := s.publish(osarch, pkgFiles, pkgs, keyPair)
+			err := s.publish(osarch, pkgFiles, pkgs, signer)
 			if err != nil {
 				return err
 			}
@@ -307,91 +337,226 @@ func (s *S3Publisher) Publish(pkgs ...string) error {
 	return nil
 }
 
-func (s *S3Publisher) channel(osarch *zps.OsArch, pkg string, channel string, keyPair *KeyPairEntry) error {
-	tmpDir, err := ioutil.TempDir(s.workPath, "channel")
+func (s *S3Publisher) Remove(pkgs ...string) error {
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
 	if err != nil {
 		return err
 	}
 
-	defer os.RemoveAll(tmpDir)
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	}
 
-	metaPath := filepath.Join(tmpDir, "metadata.db")
-	sigPath := filepath.Join(tmpDir, "metadata.sig")
+	for _, osarch := range zps.Platforms() {
+		var files []string
 
-	metadataDb, err := os.Create(metaPath)
-	if err != nil {
-		return err
-	}
-	defer metadataDb.Close()
+		err = s.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var err error
 
-	// Download metadata db
-	client := s3manager.NewDownloader(s.session)
+			files, err = removePackages(metadata, pkgs)
+			return len(files) > 0, err
+		})
+		if err != nil {
+			return err
+		}
 
-	_, err = client.Download(metadataDb, &s3.GetObjectInput{
-		Bucket: aws.String(s.uri.Host),
-		Key:    aws.String(path.Join(s.uri.Path, osarch.String(), "metadata.db")),
-	})
-	if err != nil {
-		return errors.New(fmt.Sprintf("unable to download: %s", s.uri.Path))
+		// Files go once the metadata no longer references them
+		for _, file := range files {
+			_, err = s3.New(s.session).DeleteObject(&s3.DeleteObjectInput{
+				Bucket: aws.String(s.uri.Host),
+				Key:    aws.String(path.Join(s.uri.Path, osarch.String(), file)),
+			})
+			if err != nil {
+				return err
+			}
+			s.Emit("publisher.remove", file)
+		}
 	}
 
-	// Modify metadata
-	metadata := NewMetadata(metaPath)
-	meta, err := metadata.All()
+	return nil
+}
+
+func (s *S3Publisher) Yank(yanked bool, pkgs ...string) error {
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
 	if err != nil {
 		return err
 	}
 
-	if len(meta) > 0 {
-		err = metadata.Channels.Add(pkg, channel)
+	if signer == nil {
+		s.Emitter.Emit("publisher.warn", fmt.Sprintf("No signer found for publisher %s, not signing.", PublisherFromUri(s.uri)))
+	}
+
+	for _, osarch := range zps.Platforms() {
+		err = s.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			return yankPackages(metadata, yanked, pkgs)
+		})
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
 
-		s.Emit("publisher.channel", pkg)
+func (s *S3Publisher) Check(fix bool) (int, int, error) {
+	signer, err := s.security.Signer(PublisherFromUri(s.uri))
+	if err != nil {
+		return 0, 0, err
 	}
 
-	// Upload metadata
-	metadataDb.Seek(0, io.SeekStart)
+	svc := s3.New(s.session)
+	problems, unresolved := 0, 0
 
-	uploader := s3manager.NewUploader(s.session, func(u *s3manager.Uploader) {
-		u.Concurrency = 3
-	})
+	for _, osarch := range zps.Platforms() {
+		prefix := path.Join(s.uri.Path, osarch.String())
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s.uri.Host),
-		Key:    aws.String(path.Join(s.uri.Path, osarch.String(), "metadata.db")),
-		Body:   metadataDb,
-	})
+		var check *packageCheck
 
-	// Sign and upload
-	if keyPair != nil {
-		rsaKey, err := keyPair.RSAKey()
+		err = s.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+			var files []string
+
+			err := svc.ListObjectsPages(&s3.ListObjectsInput{
+				Bucket: aws.String(s.uri.Host),
+				Prefix: aws.String(strings.TrimPrefix(prefix, "/") + "/"),
+			}, func(page *s3.ListObjectsOutput, last bool) bool {
+				for _, obj := range page.Contents {
+					key := aws.StringValue(obj.Key)
+
+					if path.Dir(key) == strings.TrimPrefix(prefix, "/") && path.Ext(key) == ".zpkg" {
+						files = append(files, path.Base(key))
+					}
+				}
+
+				return true
+			})
+			if err != nil {
+				return false, err
+			}
+
+			check, err = checkPackages(s.Emitter, s.security, s.workPath, osarch, metadata, files, func(file string, dest string) error {
+				dst, err := os.Create(dest)
+				if err != nil {
+					return err
+				}
+				defer dst.Close()
+
+				_, err = s3manager.NewDownloader(s.session).Download(dst, &s3.GetObjectInput{
+					Bucket: aws.String(s.uri.Host),
+					Key:    aws.String(path.Join(prefix, file)),
+				})
+				if err != nil {
+					return fmt.Errorf("unable to download: %s", path.Join(prefix, file))
+				}
+
+				return nil
+			}, s.files, fix)
+			if err != nil {
+				return false, err
+			}
+
+			return check.changed, nil
+		})
 		if err != nil {
-			return err
+			return 0, 0, err
 		}
 
-		err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
-		if err != nil {
-			return err
+		if check == nil {
+			continue
 		}
 
-		metadataSig, err := os.Open(sigPath)
+		for _, file := range check.deletes {
+			_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+				Bucket: aws.String(s.uri.Host),
+				Key:    aws.String(path.Join(prefix, file)),
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+			s.Emit("publisher.remove", file)
+		}
+
+		problems += check.problems
+		unresolved += check.unresolved
+	}
+
+	return problems, unresolved, nil
+}
+
+func (s *S3Publisher) channel(osarch *zps.OsArch, channel string, add bool, pkgs []string, signer sec.Signer) error {
+	return s.modify(osarch, signer, func(metadata *Metadata) (bool, error) {
+		changed, err := channelPackages(metadata, channel, add, pkgs)
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		_, err = uploader.Upload(&s3manager.UploadInput{
-			Bucket: aws.String(s.uri.Host),
-			Key:    aws.String(path.Join(s.uri.Path, osarch.String(), "metadata.sig")),
-			Body:   metadataSig,
-		})
+		for _, pkg := range changed {
+			if add {
+				s.Emit("publisher.channel", fmt.Sprint(channel, " ", pkg))
+			} else {
+				s.Emit("publisher.unchannel", fmt.Sprint(channel, " ", pkg))
+			}
+		}
+
+		return len(changed) > 0, nil
+	})
+}
+
+// Applies fn to a downloaded copy of the current metadata, the copy is
+// committed as the next generation when fn reports a change
+func (s *S3Publisher) modify(osarch *zps.OsArch, signer sec.Signer, fn func(metadata *Metadata) (bool, error)) error {
+	tmpDir, err := ioutil.TempDir(s.workPath, "modify")
+	if err != nil {
+		return err
 	}
 
-	return err
+	defer os.RemoveAll(tmpDir)
+
+	metaPath := filepath.Join(tmpDir, "metadata.db")
+
+	metadataDb, err := os.Create(metaPath)
+	if err != nil {
+		return err
+	}
+	defer metadataDb.Close()
+
+	// Download metadata db
+	client := s3manager.NewDownloader(s.session)
+
+	generation, err := s3MetadataPointer(client, s.uri.Host, path.Join(s.uri.Path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+
+	_, err = client.Download(metadataDb, &s3.GetObjectInput{
+		Bucket: aws.String(s.uri.Host),
+		Key:    aws.String(path.Join(s.uri.Path, osarch.String(), current)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchKey" {
+			return nil
+		}
+
+		return errors.New(fmt.Sprintf("unable to download: %s", s.uri.Path))
+	}
+
+	metadata := NewMetadata(metaPath)
+
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
+	changed, err := fn(metadata)
+	if err != nil || !changed {
+		return err
+	}
+
+	return s.commit(osarch, metaPath, generation, info.Version+1, signer)
 }
 
-func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, keyPair *KeyPairEntry) error {
+func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zps.Pkg, signer sec.Signer) error {
 	tmpDir, err := ioutil.TempDir(s.workPath, "publish")
 	if err != nil {
 		return err
@@ -400,7 +565,6 @@ func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zp
 	defer os.RemoveAll(tmpDir)
 
 	metaPath := filepath.Join(tmpDir, "metadata.db")
-	sigPath := filepath.Join(tmpDir, "metadata.sig")
 
 	metadataDb, err := os.Create(metaPath)
 	if err != nil {
@@ -411,9 +575,16 @@ func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zp
 	// Download metadata db
 	client := s3manager.NewDownloader(s.session)
 
+	generation, err := s3MetadataPointer(client, s.uri.Host, path.Join(s.uri.Path, osarch.String()))
+	if err != nil {
+		return err
+	}
+
+	current, _ := MetadataFiles(generation)
+
 	_, err = client.Download(metadataDb, &s3.GetObjectInput{
 		Bucket: aws.String(s.uri.Host),
-		Key:    aws.String(path.Join(s.uri.Path, osarch.String(), "metadata.db")),
+		Key:    aws.String(path.Join(s.uri.Path, osarch.String(), current)),
 	})
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() != "NoSuchKey" {
@@ -430,6 +601,11 @@ func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zp
 	}
 	repo.Load(meta)
 
+	info, err := metadata.Info()
+	if err != nil {
+		return err
+	}
+
 	rejects := repo.Add(zpkgs...)
 	rejectIndex := make(map[string]bool)
 
@@ -473,8 +649,8 @@ func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zp
 			}
 		}
 
-		// TODO Rewrite instead of atomic update for now
-
+		// Rebuild the local copy, the repo switches over in commit
+		metadataDb.Close()
 		metadata.Empty()
 
 		for _, pkg := range repo.Solvables() {
@@ -484,57 +660,116 @@ func (s *S3Publisher) publish(osarch *zps.OsArch, pkgFiles []string, zpkgs []*zp
 			}
 		}
 
-		// Upload metadata
-		metadataUp, err := os.Open(metaPath)
+		err = s.commit(osarch, metaPath, generation, info.Version+1, signer)
+	} else {
+		_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.uri.Host),
+			Key:    aws.String(path.Join(s.uri.Path, osarch.String()) + "/"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Stamps metaPath and uploads it with its signature as a new generation, then
+// the pointer to it. The plain metadata names are rewritten afterwards for older
+// clients, generations other than the new and previous one are removed
+func (s *S3Publisher) commit(osarch *zps.OsArch, metaPath string, previous string, version uint64, signer sec.Signer) error {
+	var err error
+
+	prefix := path.Join(s.uri.Path, osarch.String())
+	next := NewMetadataGeneration()
+	metaName, sigName := MetadataFiles(next)
+	legacyMeta, legacySig := MetadataFiles("")
+	sigPath := metaPath + ".sig"
+
+	err = stampMetadata(metaPath, version, s.expires)
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		err = sec.SecuritySignFile(metaPath, sigPath, signer, sec.DefaultDigestMethod)
 		if err != nil {
 			return err
 		}
-		defer metadataUp.Close()
+	}
 
-		uploader := s3manager.NewUploader(s.session, func(u *s3manager.Uploader) {
-			u.Concurrency = 3
-		})
+	err = s.upload(metaPath, path.Join(prefix, metaName))
+	if err == nil && signer != nil {
+		err = s.upload(sigPath, path.Join(prefix, sigName))
+	}
+
+	if err == nil {
+		uploader := s3manager.NewUploader(s.session)
 
 		_, err = uploader.Upload(&s3manager.UploadInput{
 			Bucket: aws.String(s.uri.Host),
-			Key:    aws.String(path.Join(s.uri.Path, osarch.String(), "metadata.db")),
-			Body:   metadataUp,
+			Key:    aws.String(path.Join(prefix, MetadataPointer)),
+			Body:   strings.NewReader(next + "\n"),
 		})
+	}
 
-		// Sign and upload
-		if keyPair != nil {
-			rsaKey, err := keyPair.RSAKey()
-			if err != nil {
-				return err
-			}
-
-			err = sec.SecuritySignFile(metaPath, sigPath, keyPair.Fingerprint, rsaKey, sec.DefaultDigestMethod)
-			if err != nil {
-				return err
-			}
+	if err != nil {
+		s.discard(prefix, next)
+		return err
+	}
 
-			configSig, err := os.Open(sigPath)
-			if err != nil {
-				return err
-			}
+	err = s.upload(metaPath, path.Join(prefix, legacyMeta))
+	if err != nil {
+		return err
+	}
 
-			_, err = uploader.Upload(&s3manager.UploadInput{
-				Bucket: aws.String(s.uri.Host),
-				Key:    aws.String(path.Join(s.uri.Path, osarch.String(), "metadata.sig")),
-				Body:   configSig,
-			})
+	if signer != nil {
+		err = s.upload(sigPath, path.Join(prefix, legacySig))
+		if err != nil {
+			return err
 		}
-	} else {
+	}
+
+	svc := s3.New(s.session)
+
+	objects, err := svc.ListObjects(&s3.ListObjectsInput{
+		Bucket: aws.String(s.uri.Host),
+		Prefix: aws.String(strings.TrimPrefix(path.Join(prefix, "metadata-"), "/")),
+	})
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, obj := range objects.Contents {
+		names = append(names, path.Base(aws.StringValue(obj.Key)))
+	}
+
+	for _, name := range StaleMetadataFiles(names, next, previous) {
 		_, err = svc.DeleteObject(&s3.DeleteObjectInput{
 			Bucket: aws.String(s.uri.Host),
-			Key:    aws.String(path.Join(s.uri.Path, osarch.String()) + "/"),
+			Key:    aws.String(path.Join(prefix, name)),
 		})
 		if err != nil {
 			return err
 		}
 	}
 
-	return err
+	return nil
+}
+
+// Removes a generation the pointer was never switched to, leftovers are
+// also removed by the next commit
+func (s *S3Publisher) discard(prefix string, generation string) {
+	metaName, sigName := MetadataFiles(generation)
+	svc := s3.New(s.session)
+
+	for _, name := range []string{metaName, sigName} {
+		svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.uri.Host),
+			Key:    aws.String(path.Join(prefix, name)),
+		})
+	}
 }
 
 func (s *S3Publisher) upload(file string, dest string) error {
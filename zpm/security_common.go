@@ -4,18 +4,23 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 
 	"github.com/fezz-io/zps/action"
+	"github.com/fezz-io/zps/config"
+	"github.com/fezz-io/zps/sec"
 )
 
 type Security interface {
 	Mode() string
 	Verify(content *[]byte, signatures []*action.Signature) (*action.Signature, error)
-	KeyPair(publisher string) (*KeyPairEntry, error)
+	Signer(publisher string) (sec.Signer, error)
 	Trust(content *[]byte, typ string) (string, string, error)
+	Crl(content *[]byte) (string, bool, error)
 }
 
-func NewSecurity(mode string, pki *Pki) (Security, error) {
+func NewSecurity(mode string, pki *Pki, signers []*config.SignerConfig) (Security, error) {
 	// Short circuit for none
 	if mode == SecurityModeNone {
 		return &SecurityNone{}, nil
@@ -51,8 +56,39 @@ func NewSecurity(mode string, pki *Pki) (Security, error) {
 
 	switch mode {
 	case SecurityModeOffline:
-		return &SecurityOffline{pki, cas, intermediates}, nil
+		return &SecurityOffline{pki, signers, cas, intermediates}, nil
 	default:
 		return nil, errors.New("security mode does not exist")
 	}
 }
+
+func NewConfiguredSigner(cfg *config.SignerConfig) (sec.Signer, error) {
+	certPem, err := ioutil.ReadFile(cfg.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "external":
+		return sec.NewExternalSigner(certPem, cfg.Command)
+	case "pkcs11":
+		signer, err := sec.NewPkcs11Signer(certPem, cfg.Module, cfg.KeyId)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Tool != "" {
+			signer.Tool = cfg.Tool
+		}
+
+		signer.Slot = cfg.Slot
+
+		if cfg.PinEnv != "" {
+			signer.Pin = os.Getenv(cfg.PinEnv)
+		}
+
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("signer type %s does not exist", cfg.Type)
+	}
+}
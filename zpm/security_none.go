@@ -2,6 +2,7 @@ package zpm
 
 import (
 	"github.com/fezz-io/zps/action"
+	"github.com/fezz-io/zps/sec"
 )
 
 const (
@@ -14,7 +15,7 @@ func (s *SecurityNone) Mode() string {
 	return SecurityModeNone
 }
 
-func (s *SecurityNone) KeyPair(publisher string) (*KeyPairEntry, error) {
+func (s *SecurityNone) Signer(publisher string) (sec.Signer, error) {
 	return nil, nil
 }
 
@@ -22,6 +23,10 @@ func (s *SecurityNone) Trust(content *[]byte, typ string) (string, string, error
 	return "", "", nil
 }
 
+func (s *SecurityNone) Crl(content *[]byte) (string, bool, error) {
+	return "", false, nil
+}
+
 // TODO warn on the presence of invalid signatures
 func (s *SecurityNone) Verify(content *[]byte, signatures []*action.Signature) (*action.Signature, error) {
 	return nil, nil
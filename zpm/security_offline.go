@@ -1,12 +1,15 @@
 package zpm
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 
+	"github.com/fezz-io/zps/config"
 	"github.com/fezz-io/zps/sec"
 
 	"github.com/fezz-io/zps/action"
@@ -16,8 +19,19 @@ const (
 	SecurityModeOffline = "offline"
 )
 
+var ErrCrlIssuerUntrusted = errors.New("crl issuer is not a trusted ca or intermediate")
+
+type RevokedError struct {
+	Subject string
+}
+
+func (r *RevokedError) Error() string {
+	return fmt.Sprintf("certificate %s has been revoked", r.Subject)
+}
+
 type SecurityOffline struct {
-	pki *Pki
+	pki     *Pki
+	signers []*config.SignerConfig
 
 	caCache           *x509.CertPool
 	intermediateCache *x509.CertPool
@@ -27,14 +41,26 @@ func (s *SecurityOffline) Mode() string {
 	return SecurityModeOffline
 }
 
-func (s *SecurityOffline) KeyPair(publisher string) (*KeyPairEntry, error) {
+// Configured external signers take precedence over the pki keypair store
+func (s *SecurityOffline) Signer(publisher string) (sec.Signer, error) {
+	for _, signer := range s.signers {
+		if signer.Publisher == publisher {
+			return NewConfiguredSigner(signer)
+		}
+	}
+
 	pairs, err := s.pki.KeyPairs.GetByPublisher(publisher)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(pairs) > 0 {
-		return pairs[0], nil
+		key, err := pairs[0].PrivateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return sec.NewKeySigner(pairs[0].Fingerprint, pairs[0].Subject, key), nil
 	}
 
 	return nil, nil
@@ -77,6 +103,8 @@ func (s *SecurityOffline) Verify(content *[]byte, signatures []*action.Signature
 		Intermediates: s.intermediateCache,
 	}
 
+	var revoked error
+
 	for _, sig := range signatures {
 		// Load cert if found
 		certEntry, err := s.pki.Certificates.Get(sig.FingerPrint)
@@ -94,19 +122,160 @@ func (s *SecurityOffline) Verify(content *[]byte, signatures []*action.Signature
 			return nil, fmt.Errorf("failed to parse asn for cert entry: %s", certEntry.Fingerprint)
 		}
 
-		// TODO Check CRL, if found
+		err = s.validateChain(opts, cert)
+		if _, ok := err.(*RevokedError); ok {
+			revoked = err
+		}
 
 		// TODO for now return on first successful validation
-		if s.validateChain(opts, cert) == nil && sec.SecurityValidateBytes(content, cert, *sig) == nil {
+		if err == nil && sec.SecurityValidateBytes(content, cert, *sig) == nil {
 			return sig, nil
 		}
 	}
 
+	if revoked != nil {
+		return nil, revoked
+	}
+
 	return nil, errors.New("no trusted certificates found for signatures")
 }
 
+// Imports a CRL signed by a trusted CA or intermediate, returns the issuer
+// subject and whether it replaced the stored CRL. CRLs from unknown
+// issuers are refused, older CRLs never replace newer ones
+func (s *SecurityOffline) Crl(content *[]byte) (string, bool, error) {
+	crl, err := sec.ParseCrl(*content)
+	if err != nil {
+		return "", false, err
+	}
+
+	issuer, fingerprint, err := s.crlIssuer(crl)
+	if err != nil {
+		return "", false, err
+	}
+
+	if issuer == nil {
+		return "", false, ErrCrlIssuerUntrusted
+	}
+
+	current, err := s.pki.Crls.Get(fingerprint)
+	if err != nil {
+		return "", false, err
+	}
+
+	if current != nil && !newerCrl(crl, current) {
+		return issuer.Subject.CommonName, false, nil
+	}
+
+	var number string
+	if crl.Number != nil {
+		number = crl.Number.String()
+	}
+
+	err = s.pki.Crls.Put(&CrlEntry{
+		Issuer:     fingerprint,
+		Subject:    issuer.Subject.CommonName,
+		Number:     number,
+		ThisUpdate: crl.ThisUpdate,
+		NextUpdate: crl.NextUpdate,
+		Crl:        *content,
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return issuer.Subject.CommonName, true, nil
+}
+
+// Compares CRL numbers when both carry one, otherwise issue times
+func newerCrl(crl *x509.RevocationList, current *CrlEntry) bool {
+	if crl.Number != nil && current.Number != "" {
+		number, ok := new(big.Int).SetString(current.Number, 10)
+		if ok {
+			return crl.Number.Cmp(number) > 0
+		}
+	}
+
+	return crl.ThisUpdate.After(current.ThisUpdate)
+}
+
+func (s *SecurityOffline) crlIssuer(crl *x509.RevocationList) (*x509.Certificate, string, error) {
+	cas, err := s.pki.Certificates.GetByType(PKICertCA)
+	if err != nil {
+		return nil, "", err
+	}
+
+	intermediates, err := s.pki.Certificates.GetByType(PKICertIntermediate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, entry := range append(cas, intermediates...) {
+		cert, err := parseCertEntry(entry)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if bytes.Equal(cert.RawSubject, crl.RawIssuer) && crl.CheckSignatureFrom(cert) == nil {
+			return cert, entry.Fingerprint, nil
+		}
+	}
+
+	return nil, "", nil
+}
+
+// Verifies the chain, at least one chain must be free of revoked certificates
 func (s *SecurityOffline) validateChain(opts x509.VerifyOptions, certificate *x509.Certificate) error {
-	_, err := certificate.Verify(opts)
+	chains, err := certificate.Verify(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, chain := range chains {
+		err = s.revoked(chain)
+		if err == nil {
+			return nil
+		}
+	}
 
 	return err
 }
+
+// Checks each certificate against the CRL of the certificate that issued it
+func (s *SecurityOffline) revoked(chain []*x509.Certificate) error {
+	for index := 0; index < len(chain)-1; index++ {
+		entry, err := s.pki.Crls.Get(sec.SpkiFingerprint(chain[index+1]).String())
+		if err != nil {
+			return err
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		crl, err := sec.ParseCrl(entry.Crl)
+		if err != nil {
+			return err
+		}
+
+		if sec.CrlRevokes(crl, chain[index]) {
+			return &RevokedError{chain[index].Subject.CommonName}
+		}
+	}
+
+	return nil
+}
+
+func parseCertEntry(entry *CertEntry) (*x509.Certificate, error) {
+	asn, _ := pem.Decode(entry.Cert)
+	if asn == nil {
+		return nil, fmt.Errorf("failed to parse pem for cert entry: %s", entry.Fingerprint)
+	}
+
+	cert, err := x509.ParseCertificate(asn.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse asn for cert entry: %s", entry.Fingerprint)
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,357 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zpm
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chuckpreslar/emission"
+	"github.com/nightlyone/lockfile"
+)
+
+// Serves a file repo over HTTP(S) in the layout written by FilePublisher,
+// below a prefix naming the publisher and repo as HttpsPublisher expects.
+//
+// Files are served with Range and ETag support, directories as a plain list
+// of file names. With uploads enabled PUT and DELETE write into the repo,
+// If-Match and If-None-Match make writes conditional on the current ETag.
+// Request bodies are capped at maxUpload bytes
+type RepoServer struct {
+	*emission.Emitter
+
+	root   string
+	prefix string
+
+	user     string
+	password string
+
+	upload    bool
+	maxUpload int64
+
+	mutex sync.Mutex
+}
+
+func NewRepoServer(emitter *emission.Emitter, root string, prefix string, user string, password string, upload bool, maxUpload int64) *RepoServer {
+	return &RepoServer{Emitter: emitter, root: root, prefix: strings.TrimSuffix(prefix, "/"), user: user, password: password, upload: upload, maxUpload: maxUpload}
+}
+
+func (s *RepoServer) Serve(addr string, certPath string, keyPath string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	if certPath != "" {
+		s.Emit("manager.info", fmt.Sprintf("serving %s on https://%s%s/", s.root, addr, s.prefix))
+		return server.ListenAndServeTLS(certPath, keyPath)
+	}
+
+	s.Emit("manager.info", fmt.Sprintf("serving %s on http://%s%s/", s.root, addr, s.prefix))
+	return server.ListenAndServe()
+}
+
+func (s *RepoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &serverResponse{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		s.Emit("manager.out", fmt.Sprintf("%s %s %s %d", r.RemoteAddr, r.Method, r.URL.Path, rw.status))
+	}()
+
+	if !s.authorized(r) {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="zps"`)
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, s.maxUpload)
+
+	if r.URL.Path != s.prefix && !strings.HasPrefix(r.URL.Path, s.prefix+"/") {
+		http.NotFound(rw, r)
+		return
+	}
+
+	name, ok := s.resolve(strings.TrimPrefix(r.URL.Path, s.prefix))
+	if !ok {
+		http.NotFound(rw, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.get(rw, r, name)
+	case http.MethodPut:
+		if !s.upload {
+			http.Error(rw, "uploads disabled", http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.put(rw, r, name)
+	case http.MethodDelete:
+		if !s.upload {
+			http.Error(rw, "uploads disabled", http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.delete(rw, r, name)
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *RepoServer) get(w http.ResponseWriter, r *http.Request, name string) {
+	info, err := os.Stat(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if info.IsDir() {
+		s.list(w, r, name)
+		return
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		http.Error(w, "unable to read file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", serverETag(info))
+
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// Lists the files in a directory one per line, publishers use this to find
+// stale metadata generations
+func (s *RepoServer) list(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := ioutil.ReadDir(name)
+	if err != nil {
+		http.Error(w, "unable to read directory", http.StatusInternalServerError)
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	for _, n := range names {
+		io.WriteString(w, n+"\n")
+	}
+}
+
+func (s *RepoServer) put(w http.ResponseWriter, r *http.Request, name string) {
+	if strings.HasSuffix(r.URL.Path, "/") {
+		http.Error(w, "file name required", http.StatusBadRequest)
+		return
+	}
+
+	if r.ContentLength > s.maxUpload {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	dir := filepath.Dir(name)
+
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		http.Error(w, "unable to create directory", http.StatusInternalServerError)
+		return
+	}
+
+	// Receive into a temp file, the rename below is the only visible change
+	tmp, err := ioutil.TempFile(dir, ".upload")
+	if err != nil {
+		http.Error(w, "unable to create file", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r.Body)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil && written >= s.maxUpload {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "unable to write file", http.StatusInternalServerError)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lock, err := s.lock(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer lock.Unlock()
+
+	current, err := os.Stat(name)
+	exists := err == nil
+
+	if !serverPreconditions(r, current, exists) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	err = os.Rename(tmp.Name(), name)
+	if err != nil {
+		http.Error(w, "unable to write file", http.StatusInternalServerError)
+		return
+	}
+
+	if info, err := os.Stat(name); err == nil {
+		w.Header().Set("ETag", serverETag(info))
+	}
+
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (s *RepoServer) delete(w http.ResponseWriter, r *http.Request, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lock, err := s.lock(filepath.Dir(name))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer lock.Unlock()
+
+	current, err := os.Stat(name)
+	if err != nil || current.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !serverPreconditions(r, current, true) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	err = os.Remove(name)
+	if err != nil {
+		http.Error(w, "unable to remove file", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Takes the lock FilePublisher holds while modifying a directory, callers
+// hold the mutex as the lock is reentrant within a process
+func (s *RepoServer) lock(dir string) (lockfile.Lockfile, error) {
+	lock, err := lockfile.New(filepath.Join(dir, ".lock"))
+	if err != nil {
+		return lock, err
+	}
+
+	err = lock.TryLock()
+	if err != nil {
+		return lock, fmt.Errorf("%s is locked by another process", strings.TrimPrefix(dir, s.root))
+	}
+
+	return lock, nil
+}
+
+func (s *RepoServer) authorized(r *http.Request) bool {
+	if s.user == "" && s.password == "" {
+		return true
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(s.user))
+	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(s.password))
+
+	return userMatch&passwordMatch == 1
+}
+
+// Maps a request path into the repo root, hidden files such as locks and
+// partial uploads are never exposed
+func (s *RepoServer) resolve(urlPath string) (string, bool) {
+	clean := path.Clean("/" + urlPath)
+
+	for _, segment := range strings.Split(clean, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return "", false
+		}
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(clean)), true
+}
+
+func serverETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func serverPreconditions(r *http.Request, current os.FileInfo, exists bool) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !exists || (match != "*" && match != serverETag(current)) {
+			return false
+		}
+	}
+
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" {
+		if exists && (noneMatch == "*" || noneMatch == serverETag(current)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type serverResponse struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *serverResponse) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,246 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zpm
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chuckpreslar/emission"
+)
+
+func testRepoServer(t *testing.T, user string, password string, maxUpload int64) (*RepoServer, string) {
+	root, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "linux-x86_64")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"metadata.db", ".lock", ".upload123"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewRepoServer(emission.NewEmitter(), root, "/fezz/repo", user, password, true, maxUpload), root
+}
+
+func serve(s *RepoServer, method string, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRepoServerAuth(t *testing.T) {
+	s, root := testRepoServer(t, "zps", "secret", 1024)
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		status   int
+	}{
+		{"missing", "", "", http.StatusUnauthorized},
+		{"wrong password", "zps", "wrong", http.StatusUnauthorized},
+		{"wrong user", "other", "secret", http.StatusUnauthorized},
+		{"valid", "zps", "secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/fezz/repo/linux-x86_64/metadata.db", nil)
+			if test.user != "" {
+				r.SetBasicAuth(test.user, test.password)
+			}
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+			if test.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRepoServerResolve(t *testing.T) {
+	s, root := testRepoServer(t, "", "", 1024)
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodGet, "/fezz/repo/linux-x86_64/metadata.db", http.StatusOK},
+		{http.MethodGet, "/fezz/repo/linux-x86_64/.lock", http.StatusNotFound},
+		{http.MethodGet, "/fezz/repo/linux-x86_64/.upload123", http.StatusNotFound},
+		{http.MethodGet, "/fezz/repo/linux-x86_64/sub/../.lock", http.StatusNotFound},
+		{http.MethodGet, "/fezz/repo/../../etc/passwd", http.StatusNotFound},
+		{http.MethodGet, "/fezz/other/linux-x86_64/metadata.db", http.StatusNotFound},
+		{http.MethodGet, "/fezz/repository/linux-x86_64/metadata.db", http.StatusNotFound},
+		{http.MethodPut, "/fezz/repo/linux-x86_64/.lock", http.StatusNotFound},
+		{http.MethodDelete, "/fezz/repo/linux-x86_64/.lock", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.target, func(t *testing.T) {
+			w := serve(s, test.method, test.target, strings.NewReader("x"), nil)
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+
+	if content, _ := ioutil.ReadFile(filepath.Join(root, "linux-x86_64", ".lock")); string(content) != ".lock" {
+		t.Errorf("hidden file modified: %q", content)
+	}
+}
+
+func TestRepoServerList(t *testing.T) {
+	s, root := testRepoServer(t, "", "", 1024)
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, "linux-x86_64", "metadata.1.db"), nil, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(s, http.MethodGet, "/fezz/repo/linux-x86_64/", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	expected := "metadata.1.db\nmetadata.db\n"
+	if w.Body.String() != expected {
+		t.Errorf("expected listing %q, got %q", expected, w.Body.String())
+	}
+
+	w = serve(s, http.MethodHead, "/fezz/repo/linux-x86_64/", nil, nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected empty 200 for HEAD, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRepoServerMaxUpload(t *testing.T) {
+	s, root := testRepoServer(t, "", "", 8)
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		name   string
+		body   io.Reader
+		status int
+	}{
+		{"within limit", strings.NewReader("12345678"), http.StatusCreated},
+		{"content length", strings.NewReader("123456789"), http.StatusRequestEntityTooLarge},
+		{"chunked", ioutil.NopCloser(strings.NewReader("123456789")), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := "/fezz/repo/linux-x86_64/" + strings.Replace(test.name, " ", "-", -1)
+
+			w := serve(s, http.MethodPut, target, test.body, nil)
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+
+			_, err := os.Stat(filepath.Join(root, filepath.FromSlash(target[len("/fezz/repo"):])))
+			if (err == nil) != (test.status == http.StatusCreated) {
+				t.Errorf("unexpected upload state: %v", err)
+			}
+		})
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(root, "linux-x86_64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".upload") && entry.Name() != ".upload123" {
+			t.Errorf("partial upload left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestRepoServerPreconditions(t *testing.T) {
+	s, root := testRepoServer(t, "", "", 1024)
+	defer os.RemoveAll(root)
+
+	target := "/fezz/repo/linux-x86_64/metadata.db"
+
+	w := serve(s, http.MethodHead, target, nil, nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		status  int
+	}{
+		{"create existing", http.MethodPut, target, map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed},
+		{"replace stale", http.MethodPut, target, map[string]string{"If-Match": `"stale"`}, http.StatusPreconditionFailed},
+		{"replace missing", http.MethodPut, "/fezz/repo/linux-x86_64/missing", map[string]string{"If-Match": "*"}, http.StatusPreconditionFailed},
+		{"delete stale", http.MethodDelete, target, map[string]string{"If-Match": `"stale"`}, http.StatusPreconditionFailed},
+		{"create new", http.MethodPut, "/fezz/repo/linux-x86_64/new", map[string]string{"If-None-Match": "*"}, http.StatusCreated},
+		{"replace current", http.MethodPut, target, map[string]string{"If-Match": etag}, http.StatusNoContent},
+		{"replace previous", http.MethodPut, target, map[string]string{"If-Match": etag}, http.StatusPreconditionFailed},
+		{"delete new", http.MethodDelete, "/fezz/repo/linux-x86_64/new", map[string]string{"If-Match": "*"}, http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(s, test.method, test.target, strings.NewReader(test.name), test.headers)
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(root, "linux-x86_64", "metadata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "replace current" {
+		t.Errorf("expected only the current etag write to land, got %q", content)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2018 Zachary Schneider
+ */
+
+package zps
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/fezz-io/zps/action"
+)
+
+// License and provenance rules a package must meet to be
+// installed, published or added to a channel
+type Compliance struct {
+	deny  []string
+	allow []string
+
+	requireProvenance bool
+}
+
+// License patterns are case insensitive globs, eg AGPL-*
+func NewCompliance(deny []string, allow []string, requireProvenance bool) *Compliance {
+	return &Compliance{deny, allow, requireProvenance}
+}
+
+func (c *Compliance) Check(pkg *Pkg) error {
+	if c.requireProvenance {
+		prov := pkg.Provenance()
+		if prov == nil || prov.VcsUri == "" || prov.Commit == "" {
+			return errors.New(fmt.Sprint("policy: ", pkg.Id(), " has no provenance"))
+		}
+	}
+
+	if pkg.License() == "" {
+		if len(c.allow) > 0 {
+			return errors.New(fmt.Sprint("policy: ", pkg.Id(), " has no license"))
+		}
+
+		return nil
+	}
+
+	expr, err := action.ParseLicense(pkg.License())
+	if err != nil {
+		return errors.New(fmt.Sprint("policy: ", pkg.Id(), " ", err.Error()))
+	}
+
+	if !expr.Satisfiable(c.permitted) {
+		return errors.New(fmt.Sprint("policy: ", pkg.Id(), " license ", pkg.License(), " is not permitted"))
+	}
+
+	return nil
+}
+
+func (c *Compliance) permitted(id string) bool {
+	if licenseMatch(c.deny, id) {
+		return false
+	}
+
+	return len(c.allow) == 0 || licenseMatch(c.allow, id)
+}
+
+func licenseMatch(patterns []string, id string) bool {
+	id = strings.ToUpper(id)
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), id); ok {
+			return true
+		}
+	}
+
+	return false
+}
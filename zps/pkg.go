@@ -30,7 +30,14 @@ type Pkg struct {
 	summary     string
 	description string
 
+	license    string
+	provenance *action.Provenance
+
 	channels []string
+	yanked   bool
+
+	tags  map[string]string
+	files []string
 
 	location int
 	priority int
@@ -50,7 +57,14 @@ type PkgEntry struct {
 	Summary     string
 	Description string
 
+	License    string
+	Provenance *action.Provenance
+
 	Channels []string
+	Yanked   bool
+
+	Tags  map[string]string
+	Files []string `json:",omitempty"`
 }
 
 func NewPkg(name string, version string, publisher string, reqs []*Requirement, arch string, os string, summary string, description string) (*Pkg, error) {
@@ -59,7 +73,7 @@ func NewPkg(name string, version string, publisher string, reqs []*Requirement,
 	if err != nil {
 		return nil, err
 	}
-	return &Pkg{reqs, name, ver, publisher, arch, os, summary, description, nil, 0, 0}, nil
+	return &Pkg{reqs, name, ver, publisher, arch, os, summary, description, "", nil, nil, false, nil, nil, 0, 0}, nil
 }
 
 func NewPkgFromManifest(manifest *action.Manifest) (*Pkg, error) {
@@ -81,6 +95,19 @@ func NewPkgFromManifest(manifest *action.Manifest) (*Pkg, error) {
 	pkg.summary = zpkg.Summary
 	pkg.description = zpkg.Description
 
+	if manifest.License != nil {
+		pkg.license = manifest.License.Expression
+	}
+	pkg.provenance = manifest.Provenance
+
+	for _, tag := range manifest.Tags {
+		if pkg.tags == nil {
+			pkg.tags = make(map[string]string)
+		}
+
+		pkg.tags[tag.Name] = tag.Value
+	}
+
 	for _, raction := range manifest.Section("Requirement") {
 		req := NewRequirement(raction.(*action.Requirement).Name, nil)
 
@@ -129,6 +156,14 @@ func (p *Pkg) Description() string {
 	return p.description
 }
 
+func (p *Pkg) License() string {
+	return p.license
+}
+
+func (p *Pkg) Provenance() *action.Provenance {
+	return p.provenance
+}
+
 func (p *Pkg) Version() *Version {
 	return p.version
 }
@@ -196,6 +231,34 @@ func (p *Pkg) Channels() []string {
 	return p.channels
 }
 
+// Yanked versions stay published but are not picked for new installs
+func (p *Pkg) Yanked() bool {
+	return p.yanked
+}
+
+func (p *Pkg) SetYanked(yanked bool) {
+	p.yanked = yanked
+}
+
+// Tag values from the package manifest, queried by channel rules
+func (p *Pkg) Tag(name string) string {
+	return p.tags[name]
+}
+
+func (p *Pkg) Tags() map[string]string {
+	return p.tags
+}
+
+// Paths shipped by the package, only present in metadata of repos
+// publishing file lists
+func (p *Pkg) Files() []string {
+	return p.files
+}
+
+func (p *Pkg) SetFiles(files ...string) {
+	p.files = files
+}
+
 func (p *Pkg) FileName() string {
 	return fmt.Sprintf("%s@%s-%s-%s.zpkg", p.Name(), p.Version().String(), p.Os(), p.Arch())
 }
@@ -219,7 +282,12 @@ func (p *Pkg) ToEntry() *PkgEntry {
 		Os:           p.Os(),
 		Summary:      p.Summary(),
 		Description:  p.Description(),
+		License:      p.License(),
+		Provenance:   p.Provenance(),
 		Channels:     p.Channels(),
+		Yanked:       p.Yanked(),
+		Tags:         p.Tags(),
+		Files:        p.Files(),
 	}
 }
 
@@ -233,6 +301,11 @@ func (p *PkgEntry) ToPkg() *Pkg {
 		os:          p.Os,
 		summary:     p.Summary,
 		description: p.Description,
+		license:     p.License,
+		provenance:  p.Provenance,
 		channels:    p.Channels,
+		yanked:      p.Yanked,
+		tags:        p.Tags,
+		files:       p.Files,
 	}
 }
@@ -128,12 +128,26 @@ func (p *Pool) WhatProvides(req *Requirement) Solvables {
 }
 
 func (p *Pool) populate() {
+	installed := make(map[string]bool)
+	for _, repo := range p.repos {
+		if repo.Priority == -1 {
+			for _, solvable := range repo.Solvables() {
+				installed[solvable.Id()] = true
+			}
+		}
+	}
+
 	for index, repo := range p.repos {
 		if repo.Enabled == false {
 			continue
 		}
 
 		for _, solvable := range repo.Solvables() {
+			// Yanked versions are only kept where already installed
+			if solvable.Yanked() && !installed[solvable.Id()] {
+				continue
+			}
+
 			solvable.SetPriority(repo.Priority)
 			solvable.SetLocation(index)
 
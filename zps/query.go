@@ -0,0 +1,357 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zps
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Parsed metadata query, eg tag.zps.qa == 'passed' && age > 72h
+//
+// Comparisons take a field: name, publisher, arch, os, license, tag.<name>,
+// version or age. Versions and ages are ordered, the other fields only
+// support == and !=. && binds tighter than ||, ! negates and parentheses group
+type Query struct {
+	Op string
+
+	Field string
+	Value string
+
+	Left  *Query
+	Right *Query
+
+	age     time.Duration
+	version *Version
+}
+
+var queryFields = []string{"name", "publisher", "arch", "os", "license", "version", "age"}
+
+func ParseQuery(expression string) (*Query, error) {
+	tokens, err := tokenizeQuery(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+
+	query, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("zps.Query: unexpected %q", p.tokens[p.pos].text)
+	}
+
+	return query, nil
+}
+
+// Age is the time since the package version was built
+func (q *Query) Match(pkg *Pkg) bool {
+	switch q.Op {
+	case "&&":
+		return q.Left.Match(pkg) && q.Right.Match(pkg)
+	case "||":
+		return q.Left.Match(pkg) || q.Right.Match(pkg)
+	case "!":
+		return !q.Left.Match(pkg)
+	}
+
+	switch q.Field {
+	case "age":
+		age := time.Since(pkg.Version().Timestamp)
+
+		switch {
+		case age < q.age:
+			return compareQuery(q.Op, -1)
+		case age > q.age:
+			return compareQuery(q.Op, 1)
+		default:
+			return compareQuery(q.Op, 0)
+		}
+	case "version":
+		// A version without a timestamp matches every build of the semver
+		if q.version.Timestamp.IsZero() {
+			return compareQuery(q.Op, pkg.Version().Semver.Compare(q.version.Semver))
+		}
+
+		cmp := pkg.Version().Compare(q.version)
+		if cmp == 2 {
+			cmp = 0
+		}
+
+		return compareQuery(q.Op, cmp)
+	}
+
+	var value string
+
+	switch q.Field {
+	case "name":
+		value = pkg.Name()
+	case "publisher":
+		value = pkg.Publisher()
+	case "arch":
+		value = pkg.Arch()
+	case "os":
+		value = pkg.Os()
+	case "license":
+		value = pkg.License()
+	default:
+		value = pkg.Tag(strings.TrimPrefix(q.Field, "tag."))
+	}
+
+	if q.Op == "!=" {
+		return value != q.Value
+	}
+
+	return value == q.Value
+}
+
+func compareQuery(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	return false
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+type queryParser struct {
+	tokens []*queryToken
+	pos    int
+}
+
+func tokenizeQuery(expression string) ([]*queryToken, error) {
+	var tokens []*queryToken
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, &queryToken{text: string(r)})
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+
+			if end == len(runes) {
+				return nil, errors.New("zps.Query: unterminated string")
+			}
+
+			tokens = append(tokens, &queryToken{text: string(runes[i+1 : end]), quoted: true})
+			i = end + 1
+		case strings.ContainsRune("&|=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && strings.ContainsRune("&|=", runes[i+1]) {
+				op += string(runes[i+1])
+			}
+
+			if !isQueryOperator(op) {
+				return nil, fmt.Errorf("zps.Query: invalid operator %q", op)
+			}
+
+			tokens = append(tokens, &queryToken{text: op})
+			i += len(op)
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("()'\"&|=!<>", runes[end]) {
+				end++
+			}
+
+			tokens = append(tokens, &queryToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+func isQueryOperator(op string) bool {
+	switch op {
+	case "&&", "||", "!", "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+
+	return false
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted {
+		return p.tokens[p.pos].text
+	}
+
+	return ""
+}
+
+func (p *queryParser) next() *queryToken {
+	if p.pos < len(p.tokens) {
+		p.pos++
+		return p.tokens[p.pos-1]
+	}
+
+	p.pos++
+	return nil
+}
+
+func (p *queryParser) or() (*Query, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "||" {
+		p.next()
+
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Query{Op: "||", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) and() (*Query, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "&&" {
+		p.next()
+
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Query{Op: "&&", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) term() (*Query, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+
+		query, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Query{Op: "!", Left: query}, nil
+	case "(":
+		p.next()
+
+		query, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.peek() != ")" {
+			return nil, errors.New("zps.Query: missing )")
+		}
+		p.next()
+
+		return query, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *queryParser) comparison() (*Query, error) {
+	field := p.next()
+	if field == nil {
+		return nil, errors.New("zps.Query: incomplete query")
+	}
+
+	if field.quoted || !isQueryField(field.text) {
+		return nil, fmt.Errorf("zps.Query: unknown field %q", field.text)
+	}
+
+	op := p.next()
+	if op == nil || op.quoted || !isQueryOperator(op.text) || op.text == "&&" || op.text == "||" || op.text == "!" {
+		return nil, fmt.Errorf("zps.Query: comparison required after %s", field.text)
+	}
+
+	value := p.next()
+	if value == nil || (!value.quoted && (isQueryOperator(value.text) || value.text == "(" || value.text == ")")) {
+		return nil, fmt.Errorf("zps.Query: value required after %s %s", field.text, op.text)
+	}
+
+	query := &Query{Op: op.text, Field: field.text, Value: value.text}
+
+	switch field.text {
+	case "age":
+		age, err := time.ParseDuration(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("zps.Query: invalid age %q", value.text)
+		}
+
+		query.age = age
+	case "version":
+		query.version = &Version{}
+
+		err := query.version.Parse(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("zps.Query: invalid version %q", value.text)
+		}
+	default:
+		if op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("zps.Query: %s only supports == and !=", field.text)
+		}
+	}
+
+	return query, nil
+}
+
+func isQueryField(field string) bool {
+	if strings.HasPrefix(field, "tag.") {
+		return len(field) > len("tag.")
+	}
+
+	for _, name := range queryFields {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,157 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fezz-io/zps/action"
+)
+
+func queryPkg(t *testing.T, semver string, age time.Duration, qa string) *Pkg {
+	manifest := action.NewManifest()
+
+	zp := action.NewZpkg()
+	zp.Name = "foo"
+	zp.Publisher = "fezz"
+	zp.Version = semver + ":" + time.Now().Add(-age).UTC().Format("20060102T150405Z")
+	zp.Os = "linux"
+	zp.Arch = "x86_64"
+	manifest.Zpkg = zp
+
+	license := action.NewLicense()
+	license.Expression = "MPL-2.0"
+	manifest.Add(license)
+
+	if qa != "" {
+		tag := action.NewTag()
+		tag.Name = "zps.qa"
+		tag.Value = qa
+		manifest.Add(tag)
+	}
+
+	pkg, err := NewPkgFromManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pkg
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		expression string
+		tree       string
+		err        string
+	}{
+		{"name == foo", "name == foo", ""},
+		{"tag.zps.qa == 'passed' && age > 72h", "(tag.zps.qa == passed && age > 72h)", ""},
+		{"a.b", "", `zps.Query: unknown field "a.b"`},
+		{"name == 'a' || name == 'b' && os == linux", "(name == a || (name == b && os == linux))", ""},
+		{"(name == 'a' || name == 'b') && os == linux", "((name == a || name == b) && os == linux)", ""},
+		{"!(arch == arm64) && !version < 1.0.0", "(!(arch == arm64) && !(version < 1.0.0))", ""},
+		{`license != "MPL 2.0"`, "license != MPL 2.0", ""},
+		{"name=='foo'&&version>=1.2.0", "(name == foo && version >= 1.2.0)", ""},
+		{"", "", "zps.Query: incomplete query"},
+		{"name", "", "zps.Query: comparison required after name"},
+		{"name ==", "", "zps.Query: value required after name =="},
+		{"name == &&", "", "zps.Query: value required after name =="},
+		{"size > 10", "", `zps.Query: unknown field "size"`},
+		{"'name' == foo", "", `zps.Query: unknown field "name"`},
+		{"tag. == x", "", `zps.Query: unknown field "tag."`},
+		{"name < foo", "", "zps.Query: name only supports == and !="},
+		{"age > 3 days", "", `zps.Query: invalid age "3"`},
+		{"version > 1.x", "", `zps.Query: invalid version "1.x"`},
+		{"name == 'foo", "", "zps.Query: unterminated string"},
+		{"name = foo", "", `zps.Query: invalid operator "="`},
+		{"name == foo & os == linux", "", `zps.Query: invalid operator "&"`},
+		{"(name == foo", "", "zps.Query: missing )"},
+		{"name == foo)", "", `zps.Query: unexpected ")"`},
+		{"name == foo os == linux", "", `zps.Query: unexpected "os"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			query, err := ParseQuery(test.expression)
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tree := queryTree(query); tree != test.tree {
+				t.Errorf("expected %s, got %s", test.tree, tree)
+			}
+		})
+	}
+}
+
+func queryTree(q *Query) string {
+	switch q.Op {
+	case "&&", "||":
+		return "(" + queryTree(q.Left) + " " + q.Op + " " + queryTree(q.Right) + ")"
+	case "!":
+		return "!(" + queryTree(q.Left) + ")"
+	}
+
+	return q.Field + " " + q.Op + " " + q.Value
+}
+
+func TestQueryMatch(t *testing.T) {
+	passed := queryPkg(t, "1.2.3", 100*time.Hour, "passed")
+	fresh := queryPkg(t, "1.2.3", time.Hour, "passed")
+	untested := queryPkg(t, "2.0.0", 100*time.Hour, "")
+
+	tests := []struct {
+		expression string
+		pkg        *Pkg
+		match      bool
+	}{
+		{"tag.zps.qa == 'passed' && age > 72h", passed, true},
+		{"tag.zps.qa == 'passed' && age > 72h", fresh, false},
+		{"tag.zps.qa == 'passed' && age > 72h", untested, false},
+		{"tag.zps.qa != 'passed'", untested, true},
+		{"tag.missing == ''", passed, true},
+		{"age <= 2h", fresh, true},
+		{"age < 2h || version >= 2.0.0", untested, true},
+		{"name == foo && publisher == fezz && os == linux && arch == x86_64", passed, true},
+		{"license == 'MPL-2.0'", passed, true},
+		{"!(license == 'MPL-2.0')", passed, false},
+		{"name != foo || (arch == arm64 || os == darwin)", passed, false},
+		{"version == 1.2.3", passed, true},
+		{"version != 1.2.3", untested, true},
+		{"version < 2.0.0", passed, true},
+		{"version >= 2.0.0", passed, false},
+		{"version > 1.2.2 && version <= 1.2.3", passed, true},
+		{"version == 1.2.3:" + passed.Version().Timestamp.Format("20060102T150405Z"), passed, true},
+		{"version == 1.2.3:" + passed.Version().Timestamp.Format("20060102T150405Z"), fresh, false},
+		{"version > 1.2.3:" + passed.Version().Timestamp.Format("20060102T150405Z"), fresh, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			query, err := ParseQuery(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if query.Match(test.pkg) != test.match {
+				t.Errorf("expected %v for %s", test.match, test.pkg.Version().String())
+			}
+		})
+	}
+}
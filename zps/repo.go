@@ -11,6 +11,7 @@
 package zps
 
 import (
+	"errors"
 	"sort"
 	"time"
 )
@@ -65,7 +66,16 @@ func (r *Repo) Add(pkgs ...*Pkg) Solvables {
 }
 
 func (r *Repo) Remove(pkg *Pkg) error {
-	return nil
+	for index, solvable := range r.solvables {
+		if solvable.Name() == pkg.Name() && solvable.Version().EXQ(pkg.Version()) {
+			r.solvables = append(r.solvables[:index], r.solvables[index+1:]...)
+			r.Index()
+
+			return nil
+		}
+	}
+
+	return errors.New("zps.Repo: " + pkg.Id() + " not found")
 }
 
 func (r *Repo) Contains(pkg *Pkg) bool {
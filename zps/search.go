@@ -0,0 +1,128 @@
+/*
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ */
+
+/*
+ * Copyright 2019 Zachary Schneider
+ */
+
+package zps
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Full text search over package metadata, every term has to match
+type Search struct {
+	terms []string
+}
+
+type SearchResult struct {
+	Name      string   `json:"name"`
+	Id        string   `json:"id"`
+	Publisher string   `json:"publisher"`
+	Os        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	Summary   string   `json:"summary"`
+	Repo      string   `json:"repo"`
+	Channels  []string `json:"channels,omitempty"`
+	Installed string   `json:"installed,omitempty"`
+	Score     int      `json:"score"`
+}
+
+type SearchResults []*SearchResult
+
+func NewSearch(terms ...string) *Search {
+	search := &Search{}
+
+	for _, term := range terms {
+		for _, field := range strings.Fields(strings.ToLower(term)) {
+			search.terms = append(search.terms, field)
+		}
+	}
+
+	return search
+}
+
+// Ranks a package against the terms, zero when a term is not found.
+// Name matches rank above tags and summary, then description and publisher
+func (s *Search) Score(pkg *Pkg) int {
+	if len(s.terms) == 0 {
+		return 0
+	}
+
+	name := strings.ToLower(pkg.Name())
+	summary := strings.ToLower(pkg.Summary())
+	description := strings.ToLower(pkg.Description())
+	publisher := strings.ToLower(pkg.Publisher())
+
+	score := 0
+
+	for _, term := range s.terms {
+		best := 0
+
+		switch {
+		case name == term:
+			best = 100
+		case strings.HasPrefix(name, term):
+			best = 60
+		case strings.Contains(name, term):
+			best = 40
+		}
+
+		for key, value := range pkg.Tags() {
+			if best < 20 && (strings.Contains(strings.ToLower(key), term) || strings.Contains(strings.ToLower(value), term)) {
+				best = 20
+			}
+		}
+
+		if best < 20 && strings.Contains(summary, term) {
+			best = 20
+		}
+
+		if best < 10 && (strings.Contains(description, term) || strings.Contains(publisher, term)) {
+			best = 10
+		}
+
+		if best == 0 {
+			return 0
+		}
+
+		score += best
+	}
+
+	return score
+}
+
+// Orders results by score, then name, repo and platform
+func (r SearchResults) Sort() {
+	sort.SliceStable(r, func(i, j int) bool {
+		if r[i].Score != r[j].Score {
+			return r[i].Score > r[j].Score
+		}
+
+		if r[i].Name != r[j].Name {
+			return r[i].Name < r[j].Name
+		}
+
+		if r[i].Repo != r[j].Repo {
+			return r[i].Repo < r[j].Repo
+		}
+
+		return r[i].Os+r[i].Arch < r[j].Os+r[j].Arch
+	})
+}
+
+func (r SearchResults) ToJson() string {
+	if r == nil {
+		r = SearchResults{}
+	}
+
+	out, _ := json.MarshalIndent(r, "", "    ")
+
+	return string(out)
+}
@@ -29,6 +29,8 @@ type Solvable interface {
 	SetChannels(...string)
 	Channels() []string
 
+	Yanked() bool
+
 	Satisfies(*Requirement) bool
 }
 